@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+type Source string
+
+const (
+	SourceRaw    Source = "raw"
+	SourceHourly Source = "hour"
+	SourceDaily  Source = "day"
+)
+
+type ExportFormat string
+
+const (
+	FormatCSV    ExportFormat = "csv"
+	FormatNDJSON ExportFormat = "ndjson"
+)
+
+// rows are read from the database a page at a time so exports never hold the full history
+const exportPageSize = 1000
+
+type ExportRow struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Members   int            `json:"members"`
+	Messages  int            `json:"messages"`
+	Commands  map[string]int `json:"commands"`
+}
+
+func ParseSource(value string) (Source, error) {
+	switch Source(value) {
+	case SourceRaw, SourceHourly, SourceDaily:
+		return Source(value), nil
+	}
+
+	return "", errors.New("source must be one of raw, hour or day")
+}
+
+func ParseExportFormat(value string) (ExportFormat, error) {
+	switch ExportFormat(value) {
+	case FormatCSV, FormatNDJSON:
+		return ExportFormat(value), nil
+	}
+
+	return "", errors.New("format must be one of csv or ndjson")
+}
+
+func (s Source) table() (string, string) {
+	switch s {
+	case SourceHourly:
+		return Hourly.Table(), "bucket"
+	case SourceDaily:
+		return Daily.Table(), "bucket"
+	default:
+		return "bot_analytics", "timestamp"
+	}
+}
+
+// PageRows calls fn with every page of rows of a bot in [from, to), oldest first
+func PageRows(database *supabase.Client, bot string, source Source, from time.Time, to time.Time, columns string, fn func([]ExportRow) error) error {
+	table, timeColumn := source.table()
+
+	for offset := 0; ; offset += exportPageSize {
+		query := database.DB.From(table).Select(columns).LimitWithOffset(exportPageSize, offset).
+			Eq("bot", bot).Gte(timeColumn, from.UTC().Format(time.RFC3339)).Lt(timeColumn, to.UTC().Format(time.RFC3339))
+
+		// postgrest-go has no ordering helper, but filters are plain query parameters
+		query.Filter("order", timeColumn, "asc")
+
+		var rows []ExportRow
+
+		if source == SourceRaw {
+			var samples []utils.IBotAnalytics
+
+			if err := query.Execute(&samples); err != nil {
+				return err
+			}
+
+			for _, sample := range samples {
+				// rows written before ingestion was validated may hold malformed commands
+				commands, _ := ParseCommands(sample.Commands)
+				rows = append(rows, ExportRow{Timestamp: sample.Timestamp, Members: sample.Members, Messages: sample.Messages, Commands: commands})
+			}
+		} else {
+			var rollups []utils.IBotAnalyticsRollup
+
+			if err := query.Execute(&rollups); err != nil {
+				return err
+			}
+
+			for _, rollup := range rollups {
+				rows = append(rows, ExportRow{Timestamp: rollup.Bucket, Members: rollup.Members, Messages: rollup.Messages, Commands: rollup.Commands})
+			}
+		}
+
+		if len(rows) > 0 {
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+
+		if len(rows) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// ExportCommands returns every command name used in [from, to), so CSV exports can write their header up front
+func ExportCommands(database *supabase.Client, bot string, source Source, from time.Time, to time.Time) ([]string, error) {
+	seen := map[string]bool{}
+
+	err := PageRows(database, bot, source, from, to, "commands", func(rows []ExportRow) error {
+		for _, row := range rows {
+			for command := range row.Commands {
+				seen[command] = true
+			}
+		}
+		return nil
+	})
+
+	commands := make([]string, 0, len(seen))
+
+	for command := range seen {
+		commands = append(commands, command)
+	}
+
+	sort.Strings(commands)
+
+	return commands, err
+}
+
+// Export streams the rows of a bot in [from, to) to w, flattening commands into one CSV column per command
+func Export(w io.Writer, database *supabase.Client, bot string, source Source, format ExportFormat, from time.Time, to time.Time, commands []string) error {
+	columns := "commands, timestamp, members, messages"
+
+	if source != SourceRaw {
+		columns = "commands, bucket, members, messages"
+	}
+
+	if format == FormatNDJSON {
+		encoder := json.NewEncoder(w)
+
+		return PageRows(database, bot, source, from, to, columns, func(rows []ExportRow) error {
+			for _, row := range rows {
+				if err := encoder.Encode(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	writer := csv.NewWriter(w)
+
+	header := []string{"timestamp", "members", "messages"}
+
+	for _, command := range commands {
+		header = append(header, "command:"+command)
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	err := PageRows(database, bot, source, from, to, columns, func(rows []ExportRow) error {
+		for _, row := range rows {
+			record := []string{row.Timestamp.UTC().Format(time.RFC3339), strconv.Itoa(row.Members), strconv.Itoa(row.Messages)}
+
+			for _, command := range commands {
+				record = append(record, strconv.Itoa(row.Commands[command]))
+			}
+
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	})
+
+	writer.Flush()
+
+	if err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+// the largest batch a runner may send in a single request
+const MaxBatchSize = 500
+
+type IngestResult struct {
+	Accepted int `json:"accepted"`
+	// samples that had already been stored, such as from a retried batch
+	Duplicates int `json:"duplicates"`
+	// the rollup buckets the batch touched
+	Hourly int `json:"hourly"`
+	Daily  int `json:"daily"`
+}
+
+// ValidateBatch validates every sample of a batch and returns their command counts
+func ValidateBatch(samples []utils.IBotAnalytics) ([]map[string]int, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("at least one sample is required")
+	}
+
+	if len(samples) > MaxBatchSize {
+		return nil, fmt.Errorf("batches are limited to %d samples", MaxBatchSize)
+	}
+
+	now := time.Now().UTC()
+	commands := make([]map[string]int, len(samples))
+
+	for i, sample := range samples {
+		counts, err := ValidateSample(sample, now)
+
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %w", i, err)
+		}
+
+		commands[i] = counts
+	}
+
+	return commands, nil
+}
+
+// Ingest stores a batch that has passed ValidateBatch and folds it into the hourly and daily rollups. Both happen
+// in one transaction in the ingest_bot_analytics database function, and samples are unique per bot and timestamp,
+// so a batch retried after a failure is not counted twice.
+func Ingest(database *supabase.Client, bot string, samples []utils.IBotAnalytics, commands []map[string]int) (IngestResult, error) {
+	rows := make([]map[string]interface{}, 0, len(samples))
+
+	for i, sample := range samples {
+		rows = append(rows, map[string]interface{}{
+			"commands":  commands[i],
+			"timestamp": sample.Timestamp.UTC(),
+			"members":   sample.Members,
+			"messages":  sample.Messages,
+		})
+	}
+
+	var results []IngestResult
+
+	err := utils.Rpc(database, "ingest_bot_analytics", map[string]interface{}{
+		"p_bot":     bot,
+		"p_samples": rows,
+	}, &results)
+
+	if err != nil || len(results) == 0 {
+		return IngestResult{}, err
+	}
+
+	result := results[0]
+	result.Duplicates = len(samples) - result.Accepted
+
+	return result, nil
+}
+
+// GetRollups returns the rollups of a bot with a bucket in [from, to), oldest first. They are read a page at a
+// time, a single read is cut off at PostgREST's row limit.
+func GetRollups(database *supabase.Client, bot string, granularity Granularity, from time.Time, to time.Time) ([]utils.IBotAnalyticsRollup, error) {
+	rollups := []utils.IBotAnalyticsRollup{}
+
+	for offset := 0; ; offset += exportPageSize {
+		var page []utils.IBotAnalyticsRollup
+
+		query := database.DB.From(granularity.Table()).Select("*").LimitWithOffset(exportPageSize, offset).
+			Eq("bot", bot).Gte("bucket", from.UTC().Format(time.RFC3339)).Lt("bucket", to.UTC().Format(time.RFC3339))
+
+		query.Filter("order", "bucket", "asc")
+
+		if err := query.Execute(&page); err != nil {
+			return rollups, err
+		}
+
+		rollups = append(rollups, page...)
+
+		if len(page) < exportPageSize {
+			return rollups, nil
+		}
+	}
+}
+
+// SaveRollups upserts complete rollup rows, replacing any stored row for the same bucket
+func SaveRollups(database *supabase.Client, granularity Granularity, rollups []utils.IBotAnalyticsRollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	return database.DB.From(granularity.Table()).Upsert(rollups).Execute(nil)
+}
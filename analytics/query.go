@@ -0,0 +1,359 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astralservices/api/utils"
+)
+
+type Interval string
+
+const (
+	IntervalHour Interval = "hour"
+	IntervalDay  Interval = "day"
+	IntervalWeek Interval = "week"
+)
+
+type Metric string
+
+const (
+	MetricMembers  Metric = "members"
+	MetricMessages Metric = "messages"
+	MetricCommands Metric = "commands"
+)
+
+const (
+	// the most buckets a single query may return
+	MaxBuckets = 2000
+	// the most commands a top-N breakdown may return
+	MaxTopCommands = 50
+)
+
+type Query struct {
+	From     time.Time
+	To       time.Time
+	Interval Interval
+	Location *time.Location
+	Metrics  map[Metric]bool
+	Top      int
+}
+
+type Bucket struct {
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Members      *int           `json:"members,omitempty"`
+	MemberGrowth *int           `json:"memberGrowth,omitempty"`
+	Messages     *int           `json:"messages,omitempty"`
+	MessageRate  *float64       `json:"messageRate,omitempty"`
+	Commands     *int           `json:"commands,omitempty"`
+	CommandUsage map[string]int `json:"commandUsage,omitempty"`
+}
+
+type CommandUsage struct {
+	Command string  `json:"command"`
+	Count   int     `json:"count"`
+	Share   float64 `json:"share"`
+}
+
+type Totals struct {
+	Members      *int     `json:"members,omitempty"`
+	MemberGrowth *int     `json:"memberGrowth,omitempty"`
+	Messages     *int     `json:"messages,omitempty"`
+	MessageRate  *float64 `json:"messageRate,omitempty"`
+	Commands     *int     `json:"commands,omitempty"`
+}
+
+type Result struct {
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Interval    Interval       `json:"interval"`
+	Timezone    string         `json:"timezone"`
+	Buckets     []Bucket       `json:"buckets"`
+	Totals      Totals         `json:"totals"`
+	TopCommands []CommandUsage `json:"topCommands,omitempty"`
+}
+
+// ParseQuery reads the analytics query parameters through a getter such as fiber's ctx.Query
+func ParseQuery(param func(key string, defaultValue ...string) string, now time.Time) (Query, error) {
+	query := Query{
+		Interval: Interval(param("interval", string(IntervalDay))),
+		Metrics:  map[Metric]bool{},
+		Top:      10,
+	}
+
+	switch query.Interval {
+	case IntervalHour, IntervalDay, IntervalWeek:
+	default:
+		return query, errors.New("interval must be one of hour, day or week")
+	}
+
+	location, err := time.LoadLocation(param("tz", "UTC"))
+
+	if err != nil {
+		return query, fmt.Errorf("unknown timezone %q", param("tz"))
+	}
+
+	query.Location = location
+
+	query.To = now
+
+	if to := param("to"); to != "" {
+		if query.To, err = parseTime(to, location); err != nil {
+			return query, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+
+	if query.Interval == IntervalHour {
+		query.From = query.To.Add(-48 * time.Hour)
+	} else {
+		query.From = query.To.AddDate(0, 0, -30)
+	}
+
+	if from := param("from"); from != "" {
+		if query.From, err = parseTime(from, location); err != nil {
+			return query, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+
+	if !query.From.Before(query.To) {
+		return query, errors.New("from must be before to")
+	}
+
+	for _, name := range strings.Split(param("metrics", "members,messages,commands"), ",") {
+		metric := Metric(strings.TrimSpace(name))
+
+		switch metric {
+		case MetricMembers, MetricMessages, MetricCommands:
+			query.Metrics[metric] = true
+		default:
+			return query, fmt.Errorf("unknown metric %q", name)
+		}
+	}
+
+	if top := param("top"); top != "" {
+		if query.Top, err = strconv.Atoi(top); err != nil || query.Top < 0 || query.Top > MaxTopCommands {
+			return query, fmt.Errorf("top must be between 0 and %d", MaxTopCommands)
+		}
+	}
+
+	if len(query.Starts()) > MaxBuckets {
+		return query, fmt.Errorf("the query would return more than %d buckets, use a larger interval", MaxBuckets)
+	}
+
+	return query, nil
+}
+
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation("2006-01-02", value, location)
+}
+
+// Source returns the rollup granularity that can answer the query, daily rollups are only usable for UTC days.
+// Hourly rollups are bucketed by their UTC hour, so zones with a fractional offset are approximated to the hour.
+func (q Query) Source() Granularity {
+	if q.Interval != IntervalHour && q.Location == time.UTC {
+		return Daily
+	}
+
+	return Hourly
+}
+
+// CheckRetention rejects queries that need hourly rollups the retention jobs have already folded into UTC days.
+// Hourly intervals, and days or weeks outside UTC, can only be answered for the policy's DailyAfter window.
+func (q Query) CheckRetention(policy RetentionPolicy, now time.Time) error {
+	if q.Source() != Hourly {
+		return nil
+	}
+
+	if q.Truncate(q.From).Before(Daily.Truncate(now.Add(-policy.DailyAfter))) {
+		return fmt.Errorf("hourly analytics are kept for %d days, older ranges can only be queried by day or week in UTC", int(policy.DailyAfter.Hours()/24))
+	}
+
+	return nil
+}
+
+// Truncate returns the start of the bucket in the query's timezone that t falls into
+func (q Query) Truncate(t time.Time) time.Time {
+	t = t.In(q.Location)
+
+	switch q.Interval {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, q.Location)
+	case IntervalWeek:
+		// weeks start on monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, q.Location)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.Location)
+	}
+}
+
+func (q Query) next(start time.Time) time.Time {
+	switch q.Interval {
+	case IntervalHour:
+		return start.Add(time.Hour)
+	case IntervalWeek:
+		return time.Date(start.Year(), start.Month(), start.Day()+7, 0, 0, 0, 0, q.Location)
+	default:
+		return time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, q.Location)
+	}
+}
+
+// Starts returns the start of every bucket the query covers
+func (q Query) Starts() []time.Time {
+	var starts []time.Time
+
+	for start := q.Truncate(q.From); start.Before(q.To); start = q.next(start) {
+		starts = append(starts, start)
+
+		if len(starts) > MaxBuckets {
+			break
+		}
+	}
+
+	return starts
+}
+
+// Aggregate buckets rollups into the query's interval and timezone and computes the requested metrics
+func Aggregate(q Query, rollups []utils.IBotAnalyticsRollup) Result {
+	sort.Slice(rollups, func(i, j int) bool {
+		return rollups[i].Bucket.Before(rollups[j].Bucket)
+	})
+
+	merged := map[time.Time]*utils.IBotAnalyticsRollup{}
+	totalCommands := map[string]int{}
+
+	for _, rollup := range rollups {
+		start := q.Truncate(rollup.Bucket)
+
+		if merged[start] == nil {
+			merged[start] = &utils.IBotAnalyticsRollup{Bucket: start}
+		}
+
+		Merge(merged[start], rollup)
+
+		for command, count := range rollup.Commands {
+			totalCommands[command] += count
+		}
+	}
+
+	result := Result{
+		From:     q.From,
+		To:       q.To,
+		Interval: q.Interval,
+		Timezone: q.Location.String(),
+		Buckets:  []Bucket{},
+	}
+
+	top := topCommands(totalCommands, q.Top)
+	topSet := map[string]bool{}
+
+	for _, usage := range top {
+		topSet[usage.Command] = true
+	}
+
+	var firstMembers, lastMembers, previousMembers *int
+	totalMessages, commandCount := 0, 0
+
+	for _, start := range q.Starts() {
+		bucket := Bucket{Start: start, End: q.next(start)}
+		rollup := merged[start]
+
+		if rollup == nil {
+			rollup = &utils.IBotAnalyticsRollup{}
+		}
+
+		if q.Metrics[MetricMembers] && rollup.Samples > 0 {
+			members := rollup.Members
+			bucket.Members = &members
+
+			if previousMembers != nil {
+				growth := members - *previousMembers
+				bucket.MemberGrowth = &growth
+			}
+
+			if firstMembers == nil {
+				firstMembers = &members
+			}
+
+			previousMembers, lastMembers = &members, &members
+		}
+
+		if q.Metrics[MetricMessages] {
+			messages := rollup.Messages
+			rate := float64(messages) / bucket.End.Sub(bucket.Start).Hours()
+			bucket.Messages, bucket.MessageRate = &messages, &rate
+			totalMessages += messages
+		}
+
+		if q.Metrics[MetricCommands] {
+			count := 0
+			usage := map[string]int{}
+
+			for command, n := range rollup.Commands {
+				count += n
+
+				if topSet[command] {
+					usage[command] = n
+				}
+			}
+
+			bucket.Commands, bucket.CommandUsage = &count, usage
+			commandCount += count
+		}
+
+		result.Buckets = append(result.Buckets, bucket)
+	}
+
+	if q.Metrics[MetricMembers] && lastMembers != nil {
+		growth := *lastMembers - *firstMembers
+		result.Totals.Members, result.Totals.MemberGrowth = lastMembers, &growth
+	}
+
+	if q.Metrics[MetricMessages] {
+		rate := float64(totalMessages) / q.To.Sub(q.From).Hours()
+		result.Totals.Messages, result.Totals.MessageRate = &totalMessages, &rate
+	}
+
+	if q.Metrics[MetricCommands] {
+		result.Totals.Commands = &commandCount
+		result.TopCommands = top
+	}
+
+	return result
+}
+
+func topCommands(counts map[string]int, n int) []CommandUsage {
+	total := 0
+	usage := make([]CommandUsage, 0, len(counts))
+
+	for command, count := range counts {
+		total += count
+		usage = append(usage, CommandUsage{Command: command, Count: count})
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Count == usage[j].Count {
+			return usage[i].Command < usage[j].Command
+		}
+		return usage[i].Count > usage[j].Count
+	})
+
+	if len(usage) > n {
+		usage = usage[:n]
+	}
+
+	for i := range usage {
+		usage[i].Share = float64(usage[i].Count) / float64(total)
+	}
+
+	return usage
+}
@@ -0,0 +1,227 @@
+package analytics
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+type RetentionPolicy struct {
+	// raw samples older than this are folded into hourly rollups
+	HourlyAfter time.Duration `json:"hourlyAfter"`
+	// hourly rollups older than this are folded into daily rollups
+	DailyAfter time.Duration `json:"dailyAfter"`
+	// everything older than this many days is purged, 0 keeps everything
+	RetentionDays int `json:"retentionDays"`
+}
+
+type RetentionStep struct {
+	Stage   string    `json:"stage"`
+	Table   string    `json:"table"`
+	Before  time.Time `json:"before"`
+	Deleted int       `json:"deleted"`
+	Written int       `json:"written"`
+}
+
+type RetentionReport struct {
+	Bot    string          `json:"bot"`
+	DryRun bool            `json:"dryRun"`
+	RanAt  time.Time       `json:"ranAt"`
+	Policy RetentionPolicy `json:"policy"`
+	Steps  []RetentionStep `json:"steps"`
+}
+
+func envDays(key string, fallback int) time.Duration {
+	days, err := strconv.Atoi(os.Getenv(key))
+
+	if err != nil || days <= 0 {
+		days = fallback
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PolicyForPlan builds the retention policy of a plan, the downsampling windows come from the environment
+func PolicyForPlan(plan utils.IPlan) RetentionPolicy {
+	return RetentionPolicy{
+		HourlyAfter:   envDays("ANALYTICS_HOURLY_AFTER_DAYS", 7),
+		DailyAfter:    envDays("ANALYTICS_DAILY_AFTER_DAYS", 90),
+		RetentionDays: plan.AnalyticsRetention,
+	}
+}
+
+// ApplyRetention purges and downsamples the analytics of a bot. Samples are folded into both rollups as they are
+// ingested, so downsampling deletes what is older than its window and only folds samples stored before that. Rows
+// that the purge removes are not counted again by the downsampling steps.
+func ApplyRetention(database *supabase.Client, bot string, policy RetentionPolicy, now time.Time, dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{Bot: bot, DryRun: dryRun, RanAt: now, Policy: policy, Steps: []RetentionStep{}}
+
+	from := time.Unix(0, 0)
+
+	if policy.RetentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.RetentionDays)
+
+		for _, source := range []Source{SourceRaw, SourceHourly, SourceDaily} {
+			step, err := remove(database, bot, source, "purge", from, cutoff, dryRun)
+
+			if err != nil {
+				return report, err
+			}
+
+			report.Steps = append(report.Steps, step)
+		}
+
+		from = cutoff
+	}
+
+	step, err := downsample(database, bot, from, Hourly.Truncate(now.Add(-policy.HourlyAfter)), dryRun)
+
+	if err != nil {
+		return report, err
+	}
+
+	report.Steps = append(report.Steps, step)
+
+	// every hourly rollup is already part of the daily rollup of its day
+	step, err = remove(database, bot, SourceHourly, "downsample:"+string(Daily), from, Daily.Truncate(now.Add(-policy.DailyAfter)), dryRun)
+
+	if err != nil {
+		return report, err
+	}
+
+	report.Steps = append(report.Steps, step)
+
+	return report, nil
+}
+
+// purge deletes the rows of a bot in [from, to) in one statement and returns how many there were, a dry run only
+// counts them
+func purge(database *supabase.Client, bot string, source Source, from time.Time, to time.Time, dryRun bool) (int, error) {
+	table, _ := source.table()
+
+	var count int
+
+	err := utils.Rpc(database, "purge_bot_analytics", map[string]interface{}{
+		"p_table":   table,
+		"p_bot":     bot,
+		"p_from":    from.UTC(),
+		"p_to":      to.UTC(),
+		"p_dry_run": dryRun,
+	}, &count)
+
+	return count, err
+}
+
+// remove deletes the rows of a bot in [from, to)
+func remove(database *supabase.Client, bot string, source Source, stage string, from time.Time, to time.Time, dryRun bool) (RetentionStep, error) {
+	table, _ := source.table()
+	step := RetentionStep{Stage: stage, Table: table, Before: to}
+
+	if !from.Before(to) {
+		return step, nil
+	}
+
+	var err error
+
+	step.Deleted, err = purge(database, bot, source, from, to, dryRun)
+
+	return step, err
+}
+
+// oldest returns the time of the oldest row of a bot in [from, to)
+func oldest(database *supabase.Client, bot string, source Source, from time.Time, to time.Time) (*time.Time, error) {
+	table, timeColumn := source.table()
+
+	query := database.DB.From(table).Select(timeColumn).Limit(1).
+		Eq("bot", bot).Gte(timeColumn, from.UTC().Format(time.RFC3339)).Lt(timeColumn, to.UTC().Format(time.RFC3339))
+
+	query.Filter("order", timeColumn, "asc")
+
+	var rows []map[string]time.Time
+
+	if err := query.Execute(&rows); err != nil || len(rows) == 0 {
+		return nil, err
+	}
+
+	t := rows[0][timeColumn]
+
+	return &t, nil
+}
+
+// unrolledBuckets counts the hours of the raw samples in [from, to) that were stored before ingestion rolled them up
+func unrolledBuckets(database *supabase.Client, bot string, from time.Time, to time.Time) (int, error) {
+	var count int
+
+	err := utils.Rpc(database, "count_unrolled_buckets", map[string]interface{}{
+		"p_bot":  bot,
+		"p_from": from.UTC(),
+		"p_to":   to.UTC(),
+	}, &count)
+
+	return count, err
+}
+
+// downsample deletes the raw samples in [from, cutoff) a day at a time, folding the ones that were never rolled up.
+// The database function deletes and folds in one statement, so samples ingested meanwhile are left alone.
+func downsample(database *supabase.Client, bot string, from time.Time, cutoff time.Time, dryRun bool) (RetentionStep, error) {
+	table, _ := SourceRaw.table()
+	step := RetentionStep{Stage: "downsample:" + string(Hourly), Table: table, Before: cutoff}
+
+	if !from.Before(cutoff) {
+		return step, nil
+	}
+
+	if dryRun {
+		var err error
+
+		if step.Deleted, err = purge(database, bot, SourceRaw, from, cutoff, true); err != nil {
+			return step, err
+		}
+
+		step.Written, err = unrolledBuckets(database, bot, from, cutoff)
+
+		return step, err
+	}
+
+	start := from
+
+	for {
+		first, err := oldest(database, bot, SourceRaw, start, cutoff)
+
+		if err != nil || first == nil {
+			return step, err
+		}
+
+		start = *first
+		end := start.Add(24 * time.Hour)
+
+		if end.After(cutoff) {
+			end = cutoff
+		}
+
+		var results []struct {
+			Deleted int `json:"deleted"`
+			Written int `json:"written"`
+		}
+
+		err = utils.Rpc(database, "downsample_bot_analytics", map[string]interface{}{
+			"p_bot":  bot,
+			"p_from": start.UTC(),
+			"p_to":   end.UTC(),
+		}, &results)
+
+		if err != nil {
+			return step, err
+		}
+
+		for _, result := range results {
+			step.Deleted += result.Deleted
+			step.Written += result.Written
+		}
+
+		start = end
+	}
+}
@@ -0,0 +1,121 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/astralservices/api/utils"
+)
+
+type Granularity string
+
+const (
+	Hourly Granularity = "hour"
+	Daily  Granularity = "day"
+)
+
+// the rollup tables are keyed on (bot, bucket) so upserts merge into the existing row
+var rollupTables = map[Granularity]string{
+	Hourly: "bot_analytics_hourly",
+	Daily:  "bot_analytics_daily",
+}
+
+func (g Granularity) Table() string {
+	return rollupTables[g]
+}
+
+// Truncate returns the start of the UTC bucket that t falls into
+func (g Granularity) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+
+	if g == Daily {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	return t.Truncate(time.Hour)
+}
+
+func (g Granularity) Duration() time.Duration {
+	if g == Daily {
+		return 24 * time.Hour
+	}
+
+	return time.Hour
+}
+
+// ParseCommands converts the untyped commands blob of a sample into command counts
+func ParseCommands(commands any) (map[string]int, error) {
+	counts := map[string]int{}
+
+	if commands == nil {
+		return counts, nil
+	}
+
+	raw, ok := commands.(map[string]any)
+
+	if !ok {
+		return nil, errors.New("commands must be an object of command counts")
+	}
+
+	for command, value := range raw {
+		if command == "" {
+			return nil, errors.New("command names must not be empty")
+		}
+
+		count, ok := value.(float64)
+
+		if !ok || count < 0 || count != math.Trunc(count) {
+			return nil, fmt.Errorf("command %q must have a non-negative whole count", command)
+		}
+
+		counts[command] = int(count)
+	}
+
+	return counts, nil
+}
+
+// ValidateSample checks a sample against the IBotAnalytics shape and returns its command counts
+func ValidateSample(sample utils.IBotAnalytics, now time.Time) (map[string]int, error) {
+	if sample.Timestamp.IsZero() {
+		return nil, errors.New("timestamp is required")
+	}
+
+	if sample.Timestamp.After(now.Add(5 * time.Minute)) {
+		return nil, errors.New("timestamp must not be in the future")
+	}
+
+	if sample.Members < 0 {
+		return nil, errors.New("members must not be negative")
+	}
+
+	if sample.Messages < 0 {
+		return nil, errors.New("messages must not be negative")
+	}
+
+	return ParseCommands(sample.Commands)
+}
+
+// Merge folds other into rollup, keeping the most recent member count
+func Merge(rollup *utils.IBotAnalyticsRollup, other utils.IBotAnalyticsRollup) {
+	if rollup.Commands == nil {
+		rollup.Commands = map[string]int{}
+	}
+
+	if other.Samples == 0 {
+		return
+	}
+
+	if rollup.Samples == 0 || !other.MembersAt.Before(rollup.MembersAt) {
+		rollup.Members = other.Members
+		rollup.MembersAt = other.MembersAt
+	}
+
+	rollup.Messages += other.Messages
+	rollup.Samples += other.Samples
+
+	for command, count := range other.Commands {
+		rollup.Commands[command] += count
+	}
+}
@@ -0,0 +1,254 @@
+package announcements
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	RunPending   = "pending"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// CommandType is the runner command announcements are sent with
+const CommandType = "message.send"
+
+const (
+	// Discord's limit for message content, embeds are limited by utils.Embed
+	MaxContentLength = 2000
+	MaxNameLength    = 100
+	// announcements a bot can have
+	MaxAnnouncements = 50
+)
+
+var snowflake = regexp.MustCompile(`^\d{17,20}$`)
+
+// Payload is what the runner posts, with the announcement it is for so acknowledgements can be traced back
+type Payload struct {
+	Announcement string                   `json:"announcement"`
+	Channel      string                   `json:"channel"`
+	Message      discordapi.MessageCreate `json:"message"`
+}
+
+// Validate checks an announcement before it is saved, returning its schedule
+func Validate(announcement utils.IBotAnnouncement) (Schedule, error) {
+	if strings.TrimSpace(announcement.Name) == "" || len(announcement.Name) > MaxNameLength {
+		return nil, fmt.Errorf("names must be 1 to %d characters", MaxNameLength)
+	}
+
+	if !snowflake.MatchString(announcement.Channel) {
+		return nil, errors.New("the channel must be a Discord ID")
+	}
+
+	if len(announcement.Content) > MaxContentLength {
+		return nil, fmt.Errorf("messages must be at most %d characters", MaxContentLength)
+	}
+
+	if announcement.Embed != nil {
+		if err := announcement.Embed.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.TrimSpace(announcement.Content) == "" && announcement.Embed == nil {
+		return nil, errors.New("announcements need a message or an embed")
+	}
+
+	var start time.Time
+
+	if announcement.StartAt != nil {
+		start = *announcement.StartAt
+	}
+
+	return ParseSchedule(announcement.ScheduleType, announcement.Schedule, announcement.Timezone, start)
+}
+
+// Message builds the Discord message of an announcement
+func Message(announcement utils.IBotAnnouncement) discordapi.MessageCreate {
+	// announcements are written by the server's staff, so their mentions are left to ping
+	message := discordapi.MessageCreate{Content: announcement.Content}
+
+	if announcement.Embed != nil {
+		message.Embeds = []utils.Embed{*announcement.Embed}
+	}
+
+	return message
+}
+
+// NextRun returns when an announcement runs next after a time, nil when it never runs again
+func NextRun(announcement utils.IBotAnnouncement, after time.Time) (*time.Time, error) {
+	schedule, err := Validate(announcement)
+
+	if err != nil {
+		return nil, err
+	}
+
+	next, ok := schedule.Next(after)
+
+	if !ok {
+		return nil, nil
+	}
+
+	next = next.UTC()
+
+	return &next, nil
+}
+
+func List(database *supabase.Client, bot string) ([]utils.IBotAnnouncement, error) {
+	var announcements []utils.IBotAnnouncement
+
+	query := database.DB.From("bot_announcements").Select("*").Eq("bot", bot)
+	query.Filter("order", "created_at", "asc")
+
+	err := query.Execute(&announcements)
+
+	return announcements, err
+}
+
+func Get(database *supabase.Client, bot string, id string) (*utils.IBotAnnouncement, error) {
+	var announcements []utils.IBotAnnouncement
+
+	err := database.DB.From("bot_announcements").Select("*").Eq("bot", bot).Eq("id", id).Execute(&announcements)
+
+	if err != nil || len(announcements) == 0 {
+		return nil, err
+	}
+
+	return &announcements[0], nil
+}
+
+func Create(database *supabase.Client, announcement utils.IBotAnnouncement) (utils.IBotAnnouncement, error) {
+	var announcements []utils.IBotAnnouncement
+
+	err := database.DB.From("bot_announcements").Insert(announcement).Execute(&announcements)
+
+	if err != nil || len(announcements) == 0 {
+		return announcement, err
+	}
+
+	return announcements[0], nil
+}
+
+// Update saves the editable fields of an announcement
+func Update(database *supabase.Client, announcement utils.IBotAnnouncement) (utils.IBotAnnouncement, error) {
+	var announcements []utils.IBotAnnouncement
+
+	err := database.DB.From("bot_announcements").Update(map[string]interface{}{
+		"name":          announcement.Name,
+		"enabled":       announcement.Enabled,
+		"channel":       announcement.Channel,
+		"content":       announcement.Content,
+		"embed":         announcement.Embed,
+		"schedule_type": announcement.ScheduleType,
+		"schedule":      announcement.Schedule,
+		"timezone":      announcement.Timezone,
+		"start_at":      announcement.StartAt,
+		"next_run_at":   announcement.NextRunAt,
+	}).Eq("bot", announcement.Bot).Eq("id", *announcement.ID).Execute(&announcements)
+
+	if err != nil || len(announcements) == 0 {
+		return announcement, err
+	}
+
+	return announcements[0], nil
+}
+
+func Delete(database *supabase.Client, bot string, id string) error {
+	return database.DB.From("bot_announcements").Delete().Eq("bot", bot).Eq("id", id).Execute(nil)
+}
+
+// Runs returns the run history of an announcement, newest first
+func Runs(database *supabase.Client, announcement string, limit int) ([]utils.IBotAnnouncementRun, error) {
+	var runs []utils.IBotAnnouncementRun
+
+	query := database.DB.From("bot_announcement_runs").Select("*").Limit(limit).Eq("announcement", announcement)
+	query.Filter("order", "scheduled_for", "desc")
+
+	err := query.Execute(&runs)
+
+	return runs, err
+}
+
+// Due returns the enabled announcements whose next run has passed
+func Due(database *supabase.Client, now time.Time) ([]utils.IBotAnnouncement, error) {
+	var announcements []utils.IBotAnnouncement
+
+	err := database.DB.From("bot_announcements").Select("*").Eq("enabled", "true").Lte("next_run_at", now.UTC().Format(time.RFC3339)).Execute(&announcements)
+
+	return announcements, err
+}
+
+// RunID is the dedupe ID of a run, the same run is never queued twice
+func RunID(announcement utils.IBotAnnouncement) string {
+	return fmt.Sprintf("announcement-%s-%d", *announcement.ID, announcement.NextRunAt.Unix())
+}
+
+// Send queues the due run of an announcement for the runner and moves it on to its next run. Runs missed while
+// the scheduler was down are not made up, the announcement is sent once and skips to its next run after now.
+func Send(database *supabase.Client, announcement utils.IBotAnnouncement, now time.Time) error {
+	id := RunID(announcement)
+
+	payload := Payload{Announcement: *announcement.ID, Channel: announcement.Channel, Message: Message(announcement)}
+
+	// the run is recorded first, if queueing fails the next attempt records the same run and the conflict is ignored
+	err := database.DB.From("bot_announcement_runs").Insert(utils.IBotAnnouncementRun{
+		ID:           id,
+		Announcement: *announcement.ID,
+		Bot:          announcement.Bot,
+		ScheduledFor: *announcement.NextRunAt,
+		Status:       RunPending,
+	}).Execute(nil)
+
+	if err != nil && !utils.IsUniqueViolation(err) {
+		return err
+	}
+
+	if err = utils.QueueRunnerCommand(database, announcement.Bot, id, CommandType, payload); err != nil {
+		return err
+	}
+
+	return Advance(database, announcement, now, announcement.NextRunAt)
+}
+
+// Advance moves an announcement on to its first run after now, lastRun is the run that was sent, nil if none was
+func Advance(database *supabase.Client, announcement utils.IBotAnnouncement, now time.Time, lastRun *time.Time) error {
+	next, err := NextRun(announcement, now)
+
+	if err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{
+		"next_run_at": next,
+	}
+
+	if lastRun != nil {
+		update["last_run_at"] = lastRun
+	}
+
+	return database.DB.From("bot_announcements").Update(update).Eq("id", *announcement.ID).Execute(nil)
+}
+
+// Complete records the outcome of a run once the runner has acknowledged its command
+func Complete(database *supabase.Client, command utils.IRunnerCommand) error {
+	update := map[string]interface{}{
+		"status":       RunSucceeded,
+		"completed_at": command.CompletedAt,
+	}
+
+	// failed and dead-lettered commands
+	if command.Status != utils.RunnerCommandSucceeded {
+		update["status"] = RunFailed
+		update["error"] = command.Error
+	}
+
+	return database.DB.From("bot_announcement_runs").Update(update).Eq("id", command.ID).Execute(nil)
+}
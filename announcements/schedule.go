@@ -0,0 +1,619 @@
+package announcements
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ScheduleCron  = "cron"
+	ScheduleRRule = "rrule"
+)
+
+// schedules are only searched this far ahead, anything later is treated as never
+const horizon = 10 * 366 * 24 * time.Hour
+
+// Schedule tells when an announcement runs
+type Schedule interface {
+	// Next returns the first run after a time, false when there are no more runs
+	Next(after time.Time) (time.Time, bool)
+}
+
+// ParseSchedule reads a cron expression or an RRULE in a time zone. RRULEs repeat from start, cron
+// expressions ignore it.
+func ParseSchedule(kind string, expression string, timezone string, start time.Time) (Schedule, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	location, err := time.LoadLocation(timezone)
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", timezone)
+	}
+
+	switch kind {
+	case ScheduleCron:
+		return parseCron(expression, location)
+	case ScheduleRRule:
+		return parseRRule(expression, location, start)
+	}
+
+	return nil, fmt.Errorf("schedule type must be one of %s or %s", ScheduleCron, ScheduleRRule)
+}
+
+// Upcoming lists up to n runs after a time
+func Upcoming(schedule Schedule, after time.Time, n int) []time.Time {
+	runs := []time.Time{}
+
+	for len(runs) < n {
+		next, ok := schedule.Next(after)
+
+		if !ok {
+			break
+		}
+
+		runs = append(runs, next)
+		after = next
+	}
+
+	return runs
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 is also Sunday
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// whether the day fields start with *, a day matches when both match if either does, otherwise when either
+	// matches. "*/2" counts as *, as it does in cron.
+	domAny, dowAny bool
+	location       *time.Location
+}
+
+func (f cronField) value(s string) (int, error) {
+	if n, ok := f.names[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(s)
+
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, not %q", f.name, f.min, f.max, s)
+	}
+
+	return n, nil
+}
+
+// parse reads a field such as "*/15", "1-5" or "mon,wed,fri" into a bit set
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s has an invalid step %q", f.name, part[i+1:])
+			}
+
+			step, part = n, part[:i]
+		}
+
+		low, high := f.min, f.max
+
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+
+			if low > high {
+				return 0, fmt.Errorf("%s has a backwards range %q", f.name, part)
+			}
+		default:
+			n, err := f.value(part)
+
+			if err != nil {
+				return 0, err
+			}
+
+			low = n
+
+			// "5/10" runs from 5 to the end of the range
+			if step == 1 {
+				high = n
+			}
+		}
+
+		for n := low; n <= high; n += step {
+			bits |= 1 << n
+		}
+	}
+
+	return bits, nil
+}
+
+func parseCron(expression string, location *time.Location) (*cron, error) {
+	expression = strings.TrimSpace(expression)
+
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+
+	if len(fields) != len(cronFields) {
+		return nil, errors.New("cron expressions have five fields: minute, hour, day of month, month and day of week")
+	}
+
+	sets := make([]uint64, len(fields))
+
+	for i, field := range fields {
+		bits, err := cronFields[i].parse(field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sets[i] = bits
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &cron{
+		minute:   sets[0],
+		hour:     sets[1],
+		dom:      sets[2],
+		month:    sets[3],
+		dow:      sets[4],
+		domAny:   strings.HasPrefix(fields[2], "*"),
+		dowAny:   strings.HasPrefix(fields[4], "*"),
+		location: location,
+	}, nil
+}
+
+func (c *cron) day(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// wall returns the time a clock in a location shows. Times the clocks skip move forward by the gap, time.Date
+// would move them back, and times they repeat are the first of the two.
+func wall(year int, month time.Month, day int, hour int, minute int, location *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, minute, 0, 0, location)
+
+	want := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+
+	return t.Add(want.Sub(got))
+}
+
+// Next skips times the clocks jump over and runs times they repeat once, as cron does
+func (c *cron) Next(after time.Time) (time.Time, bool) {
+	t := after.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	end := after.Add(horizon)
+
+	for t.Before(end) {
+		y, m, d := t.Date()
+
+		if c.month&(1<<m) == 0 {
+			t = wall(y, m+1, 1, 0, 0, c.location)
+			continue
+		}
+
+		if !c.day(t) {
+			t = wall(y, m, d+1, 0, 0, c.location)
+			continue
+		}
+
+		if c.hour&(1<<t.Hour()) == 0 {
+			t = wall(y, m, d, t.Hour()+1, 0, c.location)
+			continue
+		}
+
+		// the second of two times that show the same clock
+		if c.minute&(1<<t.Minute()) == 0 || !wall(y, m, d, t.Hour(), t.Minute(), c.location).Equal(t) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+const (
+	freqHourly  = "HOURLY"
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+	freqYearly  = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+type byDay struct {
+	// the nth such weekday of the month, counted from the end when negative, every one when 0
+	n   int
+	day time.Weekday
+}
+
+// rrule is the subset of RFC 5545 recurrence rules announcements need, down to the minute
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      *time.Time
+	byMonth    []int
+	byMonthDay []int
+	byDay      []byDay
+	byHour     []int
+	byMinute   []int
+	// the day weeks start on, for weekly intervals
+	weekStart time.Weekday
+	start     time.Time
+	location  *time.Location
+}
+
+func parseInts(name string, value string, min int, max int, negative bool) ([]int, error) {
+	ints := []int{}
+
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(part)
+
+		if err != nil || n > max || n < min && !(negative && n <= -min && n >= -max) {
+			return nil, fmt.Errorf("%s has an invalid value %q", name, part)
+		}
+
+		ints = append(ints, n)
+	}
+
+	sort.Ints(ints)
+
+	return ints, nil
+}
+
+func parseRRule(expression string, location *time.Location, start time.Time) (*rrule, error) {
+	expression = strings.TrimPrefix(strings.TrimSpace(expression), "RRULE:")
+
+	if start.IsZero() {
+		return nil, errors.New("RRULE schedules need a start time")
+	}
+
+	r := &rrule{interval: 1, weekStart: time.Monday, start: start.In(location).Truncate(time.Minute), location: location}
+
+	for _, part := range strings.Split(expression, ";") {
+		pair := strings.SplitN(part, "=", 2)
+
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("%q is not a NAME=VALUE pair", part)
+		}
+
+		name, value := strings.ToUpper(pair[0]), strings.ToUpper(pair[1])
+
+		var err error
+
+		switch name {
+		case "FREQ":
+			switch value {
+			case freqHourly, freqDaily, freqWeekly, freqMonthly, freqYearly:
+				r.freq = value
+			default:
+				return nil, errors.New("FREQ must be one of HOURLY, DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+		case "INTERVAL":
+			if r.interval, err = strconv.Atoi(value); err != nil || r.interval < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+		case "COUNT":
+			if r.count, err = strconv.Atoi(value); err != nil || r.count < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+		case "UNTIL":
+			var until time.Time
+
+			if until, err = time.ParseInLocation("20060102T150405Z", value, time.UTC); err != nil {
+				if until, err = time.ParseInLocation("20060102", value, location); err != nil {
+					return nil, errors.New("UNTIL must be a date such as 20240131 or a UTC time such as 20240131T090000Z")
+				}
+
+				// a date includes the whole day
+				until = until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+
+			r.until = &until
+		case "BYMONTH":
+			r.byMonth, err = parseInts(name, value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(name, value, 1, 31, true)
+		case "BYHOUR":
+			r.byHour, err = parseInts(name, value, 0, 23, false)
+		case "BYMINUTE":
+			r.byMinute, err = parseInts(name, value, 0, 59, false)
+		case "WKST":
+			weekday, ok := weekdays[value]
+
+			if !ok {
+				return nil, errors.New("WKST must be one of MO, TU, WE, TH, FR, SA or SU")
+			}
+
+			r.weekStart = weekday
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				if len(day) < 2 {
+					return nil, fmt.Errorf("BYDAY has an invalid value %q", day)
+				}
+
+				weekday, ok := weekdays[day[len(day)-2:]]
+
+				if !ok {
+					return nil, fmt.Errorf("BYDAY has an invalid value %q", day)
+				}
+
+				n := 0
+
+				if prefix := day[:len(day)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -5 || n > 5 {
+						return nil, fmt.Errorf("BYDAY has an invalid value %q", day)
+					}
+				}
+
+				r.byDay = append(r.byDay, byDay{n: n, day: weekday})
+			}
+		default:
+			return nil, fmt.Errorf("%s is not supported", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.freq == "" {
+		return nil, errors.New("RRULEs need a FREQ")
+	}
+
+	if r.count > 0 && r.until != nil {
+		return nil, errors.New("RRULEs can have a COUNT or an UNTIL, not both")
+	}
+
+	for _, d := range r.byDay {
+		if d.n != 0 && r.freq != freqMonthly && r.freq != freqYearly {
+			return nil, errors.New("numbered BYDAY values such as 1MO are only allowed with MONTHLY and YEARLY")
+		}
+	}
+
+	return r, nil
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// days counts calendar days between two dates, whatever the time zone does in between
+func days(from time.Time, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// inPeriod reports whether a date falls in a period the interval selects
+func (r *rrule) inPeriod(date time.Time) bool {
+	switch r.freq {
+	case freqDaily:
+		return days(r.start, date)%r.interval == 0
+	case freqWeekly:
+		week := func(t time.Time) time.Time {
+			return t.AddDate(0, 0, -((int(t.Weekday()) - int(r.weekStart) + 7) % 7))
+		}
+
+		return (days(week(r.start), week(date))/7)%r.interval == 0
+	case freqMonthly:
+		return ((date.Year()-r.start.Year())*12+int(date.Month())-int(r.start.Month()))%r.interval == 0
+	case freqYearly:
+		return (date.Year()-r.start.Year())%r.interval == 0
+	}
+
+	// hourly rules apply the interval to hours
+	return true
+}
+
+func (r *rrule) matchesDay(date time.Time) bool {
+	if len(r.byMonth) > 0 && !containsInt(r.byMonth, int(date.Month())) {
+		return false
+	}
+
+	last := daysIn(date.Year(), date.Month())
+
+	if len(r.byMonthDay) > 0 {
+		matched := false
+
+		for _, n := range r.byMonthDay {
+			if n == date.Day() || n < 0 && last+n+1 == date.Day() {
+				matched = true
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(r.byDay) > 0 {
+		matched := false
+
+		for _, d := range r.byDay {
+			if d.day != date.Weekday() {
+				continue
+			}
+
+			if d.n == 0 || d.n > 0 && (date.Day()-1)/7+1 == d.n || d.n < 0 && (last-date.Day())/7+1 == -d.n {
+				matched = true
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	// without day rules, the start date decides which day of the period it runs
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+		switch r.freq {
+		case freqWeekly:
+			return date.Weekday() == r.start.Weekday()
+		case freqMonthly:
+			return date.Day() == r.start.Day()
+		case freqYearly:
+			return date.Day() == r.start.Day() && (len(r.byMonth) > 0 || date.Month() == r.start.Month())
+		}
+	}
+
+	return true
+}
+
+// times returns the runs on a date, in order
+func (r *rrule) times(date time.Time) []time.Time {
+	hours := r.byHour
+
+	if len(hours) == 0 {
+		if r.freq == freqHourly {
+			hours = []int{}
+
+			for h := 0; h < 24; h++ {
+				hours = append(hours, h)
+			}
+		} else {
+			hours = []int{r.start.Hour()}
+		}
+	}
+
+	minutes := r.byMinute
+
+	if len(minutes) == 0 {
+		minutes = []int{r.start.Minute()}
+	}
+
+	times := []time.Time{}
+
+	for _, h := range hours {
+		if r.freq == freqHourly && (days(r.start, date)*24+h-r.start.Hour())%r.interval != 0 {
+			continue
+		}
+
+		for _, m := range minutes {
+			times = append(times, wall(date.Year(), date.Month(), date.Day(), h, m, r.location))
+		}
+	}
+
+	return times
+}
+
+func (r *rrule) Next(after time.Time) (time.Time, bool) {
+	end := after.Add(horizon)
+
+	if r.until != nil && r.until.Before(end) {
+		end = *r.until
+	}
+
+	count := 0
+
+	// dates are walked in UTC, some time zones skip midnight
+	date := time.Date(r.start.Year(), r.start.Month(), r.start.Day(), 0, 0, 0, 0, time.UTC)
+	last := end.In(r.location)
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+
+	// runs are walked from the start so COUNT can be honoured
+	for ; !date.After(last); date = date.AddDate(0, 0, 1) {
+		if !r.inPeriod(date) || !r.matchesDay(date) {
+			continue
+		}
+
+		for _, t := range r.times(date) {
+			if t.Before(r.start) {
+				continue
+			}
+
+			if t.After(end) {
+				return time.Time{}, false
+			}
+
+			count++
+
+			if t.After(after) {
+				return t, true
+			}
+
+			if r.count > 0 && count >= r.count {
+				return time.Time{}, false
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
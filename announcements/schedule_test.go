@@ -0,0 +1,250 @@
+package announcements
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedules(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		expression string
+		timezone   string
+		// the start of RRULEs, and the time runs are listed after
+		start string
+		// the runs, in the schedule's time zone
+		want []string
+		// whether there are no runs after want
+		ends bool
+	}{
+		{
+			name:       "cron every 15 minutes",
+			kind:       ScheduleCron,
+			expression: "*/15 * * * *",
+			start:      "2024-01-01T10:07:00Z",
+			want:       []string{"2024-01-01T10:15:00Z", "2024-01-01T10:30:00Z", "2024-01-01T10:45:00Z"},
+		},
+		{
+			name:       "cron weekdays",
+			kind:       ScheduleCron,
+			expression: "0 9 * * mon-fri",
+			start:      "2024-01-05T10:00:00Z",
+			want:       []string{"2024-01-08T09:00:00Z", "2024-01-09T09:00:00Z"},
+		},
+		{
+			name:       "cron macro",
+			kind:       ScheduleCron,
+			expression: "@monthly",
+			start:      "2024-01-15T00:00:00Z",
+			want:       []string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"},
+		},
+		{
+			name:       "cron restricted days match either",
+			kind:       ScheduleCron,
+			expression: "0 0 13 * 5",
+			start:      "2024-09-01T00:00:00Z",
+			// Fridays, and the 13th, which is a Friday in September
+			want: []string{"2024-09-06T00:00:00Z", "2024-09-13T00:00:00Z", "2024-09-20T00:00:00Z", "2024-09-27T00:00:00Z", "2024-10-04T00:00:00Z", "2024-10-11T00:00:00Z", "2024-10-13T00:00:00Z"},
+		},
+		{
+			name:       "cron */n day of month with a day of week match both",
+			kind:       ScheduleCron,
+			expression: "0 0 */2 * mon",
+			start:      "2024-01-01T00:00:00Z",
+			// Mondays on odd days
+			want: []string{"2024-01-15T00:00:00Z", "2024-01-29T00:00:00Z", "2024-02-05T00:00:00Z"},
+		},
+		{
+			name:       "cron */n day of week with a day of month match both",
+			kind:       ScheduleCron,
+			expression: "0 0 1-7 * */2",
+			start:      "2024-01-01T00:00:00Z",
+			// Sundays, Tuesdays, Thursdays and Saturdays in the first week
+			want: []string{"2024-01-02T00:00:00Z", "2024-01-04T00:00:00Z", "2024-01-06T00:00:00Z", "2024-01-07T00:00:00Z", "2024-02-01T00:00:00Z"},
+		},
+		{
+			name:       "cron 7 is Sunday",
+			kind:       ScheduleCron,
+			expression: "0 12 * * 7",
+			start:      "2024-01-01T00:00:00Z",
+			want:       []string{"2024-01-07T12:00:00Z"},
+		},
+		{
+			name:       "cron skips times the clocks jump over",
+			kind:       ScheduleCron,
+			expression: "30 2 * * *",
+			timezone:   "America/New_York",
+			start:      "2024-03-09T12:00:00Z",
+			want:       []string{"2024-03-11T02:30:00-04:00", "2024-03-12T02:30:00-04:00"},
+		},
+		{
+			name:       "cron after the clocks jump forward",
+			kind:       ScheduleCron,
+			expression: "0 3 * * *",
+			timezone:   "America/New_York",
+			start:      "2024-03-09T12:00:00Z",
+			want:       []string{"2024-03-10T03:00:00-04:00", "2024-03-11T03:00:00-04:00"},
+		},
+		{
+			name:       "cron runs repeated times once",
+			kind:       ScheduleCron,
+			expression: "30 1 * * *",
+			timezone:   "America/New_York",
+			start:      "2024-11-02T12:00:00Z",
+			want:       []string{"2024-11-03T01:30:00-04:00", "2024-11-04T01:30:00-05:00"},
+		},
+		{
+			name:       "cron hourly when the clocks go back",
+			kind:       ScheduleCron,
+			expression: "@hourly",
+			timezone:   "America/New_York",
+			start:      "2024-11-03T03:30:00Z",
+			want:       []string{"2024-11-03T00:00:00-04:00", "2024-11-03T01:00:00-04:00", "2024-11-03T02:00:00-05:00"},
+		},
+		{
+			name:       "rrule daily keeps the local time across daylight saving",
+			kind:       ScheduleRRule,
+			expression: "FREQ=DAILY",
+			timezone:   "Europe/London",
+			start:      "2024-03-30T09:00:00Z",
+			want:       []string{"2024-03-31T09:00:00+01:00", "2024-04-01T09:00:00+01:00"},
+		},
+		{
+			name:       "rrule moves times the clocks jump over forward",
+			kind:       ScheduleRRule,
+			expression: "FREQ=DAILY",
+			timezone:   "America/New_York",
+			start:      "2024-03-09T07:30:00Z",
+			want:       []string{"2024-03-10T03:30:00-04:00", "2024-03-11T02:30:00-04:00"},
+		},
+		{
+			name:       "rrule runs repeated times once",
+			kind:       ScheduleRRule,
+			expression: "FREQ=HOURLY;BYHOUR=0,1,2",
+			timezone:   "America/New_York",
+			start:      "2024-11-03T04:00:00Z",
+			want:       []string{"2024-11-03T01:00:00-04:00", "2024-11-03T02:00:00-05:00", "2024-11-04T00:00:00-05:00"},
+		},
+		{
+			name:       "rrule count",
+			kind:       ScheduleRRule,
+			expression: "FREQ=DAILY;COUNT=3",
+			start:      "2024-01-01T09:00:00Z",
+			want:       []string{"2024-01-02T09:00:00Z", "2024-01-03T09:00:00Z"},
+			ends:       true,
+		},
+		{
+			name:       "rrule until date includes the day",
+			kind:       ScheduleRRule,
+			expression: "FREQ=DAILY;UNTIL=20240103",
+			start:      "2024-01-01T09:00:00Z",
+			want:       []string{"2024-01-02T09:00:00Z", "2024-01-03T09:00:00Z"},
+			ends:       true,
+		},
+		{
+			name:       "rrule until time",
+			kind:       ScheduleRRule,
+			expression: "FREQ=DAILY;UNTIL=20240103T085959Z",
+			start:      "2024-01-01T09:00:00Z",
+			want:       []string{"2024-01-02T09:00:00Z"},
+			ends:       true,
+		},
+		{
+			name:       "rrule weeks start on Monday",
+			kind:       ScheduleRRule,
+			expression: "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU",
+			timezone:   "America/New_York",
+			start:      "1997-08-05T13:00:00Z",
+			// the examples of RFC 5545, without the start itself
+			want: []string{"1997-08-10T09:00:00-04:00", "1997-08-19T09:00:00-04:00", "1997-08-24T09:00:00-04:00"},
+			ends: true,
+		},
+		{
+			name:       "rrule weeks start on WKST",
+			kind:       ScheduleRRule,
+			expression: "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			timezone:   "America/New_York",
+			start:      "1997-08-05T13:00:00Z",
+			want:       []string{"1997-08-17T09:00:00-04:00", "1997-08-19T09:00:00-04:00", "1997-08-31T09:00:00-04:00"},
+			ends:       true,
+		},
+		{
+			name:       "rrule last Friday of the month",
+			kind:       ScheduleRRule,
+			expression: "FREQ=MONTHLY;BYDAY=-1FR",
+			start:      "2024-01-01T18:00:00Z",
+			want:       []string{"2024-01-26T18:00:00Z", "2024-02-23T18:00:00Z", "2024-03-29T18:00:00Z"},
+		},
+		{
+			name:       "rrule last day of the month",
+			kind:       ScheduleRRule,
+			expression: "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1",
+			start:      "2024-01-01T00:00:00Z",
+			want:       []string{"2024-01-31T00:00:00Z", "2024-02-29T00:00:00Z", "2024-03-31T00:00:00Z"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, err := time.Parse(time.RFC3339, test.start)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			schedule, err := ParseSchedule(test.kind, test.expression, test.timezone, start)
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			n := len(test.want)
+
+			// one more than expected, to check the schedule ends where it should
+			if test.ends {
+				n++
+			}
+
+			got := []string{}
+
+			for _, run := range Upcoming(schedule, start, n) {
+				got = append(got, run.Format(time.RFC3339))
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("runs = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		kind       string
+		expression string
+		timezone   string
+	}{
+		{ScheduleCron, "* * * *", ""},
+		{ScheduleCron, "60 * * * *", ""},
+		{ScheduleCron, "* * * * 5-1", ""},
+		{ScheduleCron, "*/0 * * * *", ""},
+		{ScheduleCron, "* * * * *", "Mars/Olympus_Mons"},
+		{ScheduleRRule, "INTERVAL=2", ""},
+		{ScheduleRRule, "FREQ=SECONDLY", ""},
+		{ScheduleRRule, "FREQ=DAILY;COUNT=2;UNTIL=20240301", ""},
+		{ScheduleRRule, "FREQ=WEEKLY;BYDAY=1MO", ""},
+		{ScheduleRRule, "FREQ=WEEKLY;WKST=XX", ""},
+		{ScheduleRRule, "FREQ=DAILY;BYSETPOS=1", ""},
+		{"weekly", "* * * * *", ""},
+	}
+
+	for _, test := range tests {
+		if _, err := ParseSchedule(test.kind, test.expression, test.timezone, start); err == nil {
+			t.Errorf("%s %q in %q: expected an error", test.kind, test.expression, test.timezone)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func AdminHandler(router fiber.Router) {
+	staff := router.Use(utils.AuthMiddleware, utils.NoImpersonationMiddleware, utils.ProfileMiddleware, utils.AccessMiddleware("support"))
+
+	staff.Get("/users", SearchUsers)
+	staff.Get("/users/:user", GetUser)
+	staff.Post("/users/:user/logout", utils.AccessMiddleware("moderator"), LogoutUser)
+	staff.Post("/users/:user/impersonate", ImpersonateUser)
+	staff.Put("/users/:user/verified", utils.AccessMiddleware("moderator"), SetUserVerified)
+	staff.Post("/users/:user/verified", utils.AccessMiddleware("moderator"), SetUserVerified) // Fallback for HTML Forms
+
+	staff.Get("/workspaces", SearchWorkspaces)
+	staff.Get("/workspaces/:workspace", GetWorkspace)
+	staff.Put("/workspaces/:workspace/plan", utils.AccessMiddleware("admin"), SetWorkspacePlan)
+	staff.Post("/workspaces/:workspace/plan", utils.AccessMiddleware("admin"), SetWorkspacePlan) // Fallback for HTML Forms
+
+	for path, table := range CatalogTables {
+		catalog := staff.Group(path)
+		catalog.Get("/", ListRows(table))
+		catalog.Post("/", utils.AccessMiddleware("admin"), CreateRow(table))
+		catalog.Get("/:id", GetRow(table))
+		catalog.Put("/:id", utils.AccessMiddleware("admin"), UpdateRow(table))
+		catalog.Delete("/:id", utils.AccessMiddleware("admin"), DeleteRow(table))
+	}
+
+	staff.Get("/impersonations", GetImpersonationSessions)
+	staff.Post("/impersonations/:session/stop", StopImpersonationSession)
+
+	staff.Get("/audit", GetAuditLogs)
+}
@@ -0,0 +1,777 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	postgrest "github.com/nedpals/postgrest-go/pkg"
+	"github.com/nedpals/supabase-go"
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/sub"
+)
+
+// the platform tables staff can edit, by their path under /admin
+var CatalogTables = map[string]string{
+	"/regions":      "regions",
+	"/plans":        "plans",
+	"/integrations": "integrations",
+	"/team":         "teamMembers",
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// quoted writes a search term as a value of a PostgREST or filter, where commas, dots and parentheses would
+// otherwise be read as part of the filter
+func quoted(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// search matches q against the columns with ilike, against the exact columns with eq, and against the ID columns
+// only when q is a UUID, comparing a uuid column with anything else fails the whole query
+func search(query *postgrest.SelectRequestBuilder, q string, columns []string, exact []string, ids []string) {
+	pattern := quoted("*" + q + "*")
+	conditions := []string{}
+
+	for _, column := range columns {
+		conditions = append(conditions, column+".ilike."+pattern)
+	}
+
+	for _, column := range exact {
+		conditions = append(conditions, column+".eq."+quoted(q))
+	}
+
+	if uuidPattern.MatchString(q) {
+		for _, column := range ids {
+			conditions = append(conditions, column+".eq."+q)
+		}
+	}
+
+	// postgrest-go has no or helper and Filter joins its operator and criteria with a dot, so the list
+	// (column.operator.value, ...) is split at its first dot
+	list := "(" + strings.Join(conditions, ",") + ")"
+	dot := strings.Index(list, ".")
+
+	query.Filter("or", list[:dot], list[dot+1:])
+}
+
+func audit(ctx *fiber.Ctx, action string, targetType string, target string, data any) {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	utils.AuditRequest(ctx, db.New(), staff.ID, action, targetType, target, data)
+}
+
+func SearchUsers(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	query := database.DB.From("profiles").Select("id, created_at, email, preferred_name, discord_id, access, verified, avatar_url").Limit(50)
+
+	if q := strings.TrimSpace(ctx.Query("q")); q != "" {
+		search(query, q, []string{"preferred_name", "email"}, []string{"discord_id"}, []string{"id"})
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var profiles []map[string]interface{}
+
+	err := query.Execute(&profiles)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]map[string]interface{}]{
+		Result: profiles,
+		Code:   http.StatusOK,
+	})
+}
+
+type UserDetails struct {
+	Profile    utils.IProfile           `json:"profile"`
+	Providers  []map[string]interface{} `json:"providers"`
+	Workspaces []map[string]interface{} `json:"workspaces"`
+	Blacklist  *utils.IBlacklist        `json:"blacklist,omitempty"`
+}
+
+func GetUser(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	var profiles []utils.IProfile
+
+	err := database.DB.From("profiles").Select("*").Eq("id", ctx.Params("user")).Execute(&profiles)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(profiles) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("User not found"), true)
+	}
+
+	details := UserDetails{Profile: profiles[0]}
+
+	// provider tokens are never shown, even to staff
+	err = database.DB.From("providers").Select("id, created_at, type, provider_id, provider_avatar_url, provider_email").Eq("user", profiles[0].ID).Execute(&details.Providers)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	err = database.DB.From("workspace_members").Select("id, role, pending, workspace(id, name, plan, owner)").Eq("profile", profiles[0].ID).Execute(&details.Workspaces)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	details.Blacklist, err = utils.GetActiveBlacklist(database, profiles[0].ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[UserDetails]{
+		Result: details,
+		Code:   http.StatusOK,
+	})
+}
+
+func updateProfile(ctx *fiber.Ctx, changes map[string]interface{}) (*utils.IProfile, error) {
+	var profiles []utils.IProfile
+
+	err := db.New().DB.From("profiles").Update(changes).Eq("id", ctx.Params("user")).Execute(&profiles)
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(profiles) == 0 {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("User not found"), true)
+	}
+
+	return &profiles[0], nil
+}
+
+func profileResponse(ctx *fiber.Ctx, profile utils.IProfile) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IProfile]{
+		Result: profile,
+		Code:   http.StatusOK,
+	})
+}
+
+// signs a user out of every session by rejecting every token issued before now
+func LogoutUser(ctx *fiber.Ctx) error {
+	now := time.Now().UTC()
+
+	profile, err := updateProfile(ctx, map[string]interface{}{"sessions_revoked_at": now})
+
+	if profile == nil {
+		return err
+	}
+
+	audit(ctx, "user.logout", "user", profile.ID, map[string]any{"sessions_revoked_at": now})
+
+	return profileResponse(ctx, *profile)
+}
+
+func SetUserVerified(ctx *fiber.Ctx) error {
+	data := struct {
+		Verified *bool `json:"verified" form:"verified"`
+	}{}
+
+	if err := ctx.BodyParser(&data); err != nil || data.Verified == nil {
+		return utils.ErrorResponse(ctx, 400, errors.New("verified must be true or false"), true)
+	}
+
+	verified := *data.Verified
+
+	profile, err := updateProfile(ctx, map[string]interface{}{"verified": verified})
+
+	if profile == nil {
+		return err
+	}
+
+	audit(ctx, "user.verified", "user", profile.ID, map[string]any{"verified": verified})
+
+	return profileResponse(ctx, *profile)
+}
+
+func SearchWorkspaces(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	query := database.DB.From("workspaces").Select("id, created_at, name, logo, owner, plan, visibility, pending").Limit(50)
+
+	if q := strings.TrimSpace(ctx.Query("q")); q != "" {
+		search(query, q, []string{"name"}, nil, []string{"id", "owner"})
+	}
+
+	if plan := ctx.Query("plan"); plan != "" {
+		query.Eq("plan", plan)
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var workspaces []utils.IWorkspace
+
+	err := query.Execute(&workspaces)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IWorkspace]{
+		Result: workspaces,
+		Code:   http.StatusOK,
+	})
+}
+
+type WorkspaceDetails struct {
+	Workspace utils.IWorkspace         `json:"workspace"`
+	Members   []map[string]interface{} `json:"members"`
+	Bots      []utils.IBot             `json:"bots"`
+}
+
+// a read-only view of any workspace, bot tokens are left out
+func GetWorkspace(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	var workspaces []utils.IWorkspace
+
+	err := database.DB.From("workspaces").Select("*").Eq("id", ctx.Params("workspace")).Execute(&workspaces)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(workspaces) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Workspace not found"), true)
+	}
+
+	details := WorkspaceDetails{Workspace: workspaces[0]}
+
+	err = database.DB.From("workspace_members").Select("id, created_at, role, pending, profile(id, preferred_name, email)").Eq("workspace", *workspaces[0].ID).Execute(&details.Members)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	err = database.DB.From("bots").Select("id, created_at, owner, region, settings, commands, permissions").Eq("workspace", *workspaces[0].ID).Execute(&details.Bots)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	audit(ctx, "workspace.view", "workspace", *workspaces[0].ID, nil)
+
+	return ctx.Status(200).JSON(utils.Response[WorkspaceDetails]{
+		Result: details,
+		Code:   http.StatusOK,
+	})
+}
+
+// moves a workspace to another plan, the settings and the Stripe subscription follow it like they do when the
+// workspace is updated by its owner
+func SetWorkspacePlan(ctx *fiber.Ctx) error {
+	data := struct {
+		Plan string `json:"plan" form:"plan"`
+	}{}
+
+	if err := ctx.BodyParser(&data); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	plan, ok := utils.Plans[data.Plan]
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 400, errors.New("plan must be one of free, starter or pro"), true)
+	}
+
+	database := db.New()
+
+	var workspaces []utils.IWorkspace
+
+	err := database.DB.From("workspaces").Select("id, owner, plan, settings").Eq("id", ctx.Params("workspace")).Execute(&workspaces)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(workspaces) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Workspace not found"), true)
+	}
+
+	workspace := workspaces[0]
+	previous := workspace.Plan
+
+	price := utils.IPlan{}
+
+	err = database.DB.From("plans").Select("*").Single().Eq("id", data.Plan).Execute(&price)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	// keep the rest of the settings, only the plan's parts change
+	settings := map[string]interface{}{}
+
+	if current, ok := workspace.Settings.(map[string]interface{}); ok {
+		for key, value := range current {
+			settings[key] = value
+		}
+	}
+
+	subscription, err := subscribe(database, workspace, settings, price.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	settings["isPaidPlan"] = plan > 1
+
+	billing := map[string]interface{}{}
+
+	if current, ok := settings["stripe"].(map[string]interface{}); ok {
+		for key, value := range current {
+			billing[key] = value
+		}
+	}
+
+	billing["subscription"] = subscription
+	settings["stripe"] = billing
+
+	err = database.DB.From("workspaces").Update(map[string]interface{}{
+		"plan":     plan,
+		"settings": settings,
+	}).Eq("id", *workspace.ID).Execute(&workspaces)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	audit(ctx, "workspace.plan", "workspace", *workspaces[0].ID, map[string]any{"from": previous, "to": plan})
+
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IWorkspace]{
+		Result: workspaces[0],
+		Code:   http.StatusOK,
+	})
+}
+
+// subscribe moves the workspace's Stripe subscription to the price, workspaces without one get a subscription for
+// their owner like a new workspace does. It returns the ID of the subscription.
+func subscribe(database *supabase.Client, workspace utils.IWorkspace, settings map[string]interface{}, price string) (string, error) {
+	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
+
+	id := ""
+
+	if billing, ok := settings["stripe"].(map[string]interface{}); ok {
+		id, _ = billing["subscription"].(string)
+	}
+
+	if id == "" {
+		if workspace.Owner == nil {
+			return "", errors.New("the workspace has no owner to bill")
+		}
+
+		var owners []utils.IProfile
+
+		err := database.DB.From("profiles").Select("*").Eq("id", *workspace.Owner).Execute(&owners)
+
+		if err != nil {
+			return "", err
+		}
+
+		if len(owners) == 0 {
+			return "", errors.New("the workspace's owner has no profile")
+		}
+
+		subscription, err := sub.New(&stripe.SubscriptionParams{
+			Customer: stripe.String(owners[0].StripeCustomerID),
+			Items: []*stripe.SubscriptionItemsParams{
+				{Price: stripe.String(price)},
+			},
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		return subscription.ID, nil
+	}
+
+	subscription, err := sub.Get(id, nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	items := []*stripe.SubscriptionItemsParams{}
+
+	// replace the current prices rather than adding the new one next to them
+	for _, item := range subscription.Items.Data {
+		items = append(items, &stripe.SubscriptionItemsParams{
+			ID:      stripe.String(item.ID),
+			Deleted: stripe.Bool(true),
+		})
+	}
+
+	items = append(items, &stripe.SubscriptionItemsParams{Price: stripe.String(price)})
+
+	_, err = sub.Update(id, &stripe.SubscriptionParams{Items: items})
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func ListRows(table string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var rows []map[string]interface{}
+
+		err := db.New().DB.From(table).Select("*").Execute(&rows)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		return ctx.Status(200).JSON(utils.Response[[]map[string]interface{}]{
+			Result: rows,
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+func GetRow(table string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var rows []map[string]interface{}
+
+		err := db.New().DB.From(table).Select("*").Eq("id", ctx.Params("id")).Execute(&rows)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		if len(rows) == 0 {
+			return utils.ErrorResponse(ctx, 404, errors.New("Row not found"), true)
+		}
+
+		return ctx.Status(200).JSON(utils.Response[map[string]interface{}]{
+			Result: rows[0],
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+// rows are edited as JSON objects so new columns need no API changes
+func parseRow(ctx *fiber.Ctx) (map[string]interface{}, error) {
+	var row map[string]interface{}
+
+	if !strings.HasPrefix(ctx.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return nil, utils.ErrorResponse(ctx, 415, errors.New("Rows must be sent as JSON"), true)
+	}
+
+	if err := ctx.BodyParser(&row); err != nil {
+		return nil, utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if len(row) == 0 {
+		return nil, utils.ErrorResponse(ctx, 400, errors.New("The row is empty"), true)
+	}
+
+	delete(row, "created_at")
+
+	return row, nil
+}
+
+func CreateRow(table string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		row, err := parseRow(ctx)
+
+		if row == nil {
+			return err
+		}
+
+		var rows []map[string]interface{}
+
+		err = db.New().DB.From(table).Insert(row).Execute(&rows)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		audit(ctx, table+".create", table, fmt.Sprint(rows[0]["id"]), rows[0])
+
+		return ctx.Status(200).JSON(utils.Response[map[string]interface{}]{
+			Result: rows[0],
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+func UpdateRow(table string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		changes, err := parseRow(ctx)
+
+		if changes == nil {
+			return err
+		}
+
+		delete(changes, "id")
+
+		database := db.New()
+
+		var before []map[string]interface{}
+
+		err = database.DB.From(table).Select("*").Eq("id", ctx.Params("id")).Execute(&before)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		if len(before) == 0 {
+			return utils.ErrorResponse(ctx, 404, errors.New("Row not found"), true)
+		}
+
+		var rows []map[string]interface{}
+
+		err = database.DB.From(table).Update(changes).Eq("id", ctx.Params("id")).Execute(&rows)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		previous := map[string]interface{}{}
+
+		for column := range changes {
+			previous[column] = before[0][column]
+		}
+
+		audit(ctx, table+".update", table, ctx.Params("id"), map[string]any{"from": previous, "to": changes})
+
+		return ctx.Status(200).JSON(utils.Response[map[string]interface{}]{
+			Result: rows[0],
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+func DeleteRow(table string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		database := db.New()
+
+		var rows []map[string]interface{}
+
+		// postgrest-go does not ask for the deleted rows back, so the row is read first for the response and the audit log
+		err := database.DB.From(table).Select("*").Eq("id", ctx.Params("id")).Execute(&rows)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		if len(rows) == 0 {
+			return utils.ErrorResponse(ctx, 404, errors.New("Row not found"), true)
+		}
+
+		err = database.DB.From(table).Delete().Eq("id", ctx.Params("id")).Execute(nil)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		audit(ctx, table+".delete", table, ctx.Params("id"), rows[0])
+
+		return ctx.Status(200).JSON(utils.Response[map[string]interface{}]{
+			Result: rows[0],
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+func GetAuditLogs(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	query := database.DB.From("audit_logs").Select("*").Limit(100)
+
+	for _, filter := range []string{"actor", "action", "target_type", "target"} {
+		if value := ctx.Query(filter); value != "" {
+			query.Eq(filter, value)
+		}
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var logs []utils.IAuditLog
+
+	err := query.Execute(&logs)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IAuditLog]{
+		Result: logs,
+		Code:   http.StatusOK,
+	})
+}
+
+type ImpersonationResponse struct {
+	Session utils.IImpersonationSession `json:"session"`
+	Token   string                      `json:"token"`
+}
+
+// starts a short-lived session as a user so support can see exactly what they see
+func ImpersonateUser(ctx *fiber.Ctx) error {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	reason := strings.TrimSpace(ctx.FormValue("reason"))
+
+	if reason == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("A reason is required"), true)
+	}
+
+	database := db.New()
+
+	var profiles []utils.IProfile
+
+	err := database.DB.From("profiles").Select("id, access").Eq("id", ctx.Params("user")).Execute(&profiles)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(profiles) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("User not found"), true)
+	}
+
+	// acting as another member of staff would hand out their access
+	if profiles[0].ID == staff.ID || utils.HasAccess(profiles[0], "support") {
+		return utils.ErrorResponse(ctx, 403, errors.New("Staff accounts cannot be impersonated"), true)
+	}
+
+	// the token carries the user's sign-in provider, as a normal session does
+	var providers []utils.IProvider
+
+	err = database.DB.From("providers").Select("*").Eq("id", profiles[0].ID).Execute(&providers)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(providers) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("The user has no sign-in provider"), true)
+	}
+
+	provider := providers[0]
+	provider.ProviderAccessToken, provider.ProviderRefreshToken = "", ""
+
+	var sessions []utils.IImpersonationSession
+
+	err = database.DB.From("impersonation_sessions").Insert(utils.IImpersonationSession{
+		Staff:     staff.ID,
+		User:      profiles[0].ID,
+		Reason:    reason,
+		ExpiresAt: time.Now().Add(utils.ImpersonationDuration).UTC(),
+	}).Execute(&sessions)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	token, err := utils.CreateImpersonationToken(provider, sessions[0])
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	utils.AuditRequest(ctx, database, staff.ID, "impersonation.start", "user", profiles[0].ID, map[string]any{
+		"session": *sessions[0].ID,
+		"reason":  reason,
+		"expires": sessions[0].ExpiresAt,
+	})
+
+	return ctx.Status(200).JSON(utils.Response[ImpersonationResponse]{
+		Result: ImpersonationResponse{Session: sessions[0], Token: token},
+		Code:   http.StatusOK,
+	})
+}
+
+func GetImpersonationSessions(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	query := database.DB.From("impersonation_sessions").Select("*").Limit(100)
+
+	for _, filter := range []string{"staff", "user"} {
+		if value := ctx.Query(filter); value != "" {
+			query.Eq(filter, value)
+		}
+	}
+
+	if ctx.Query("active") == "true" {
+		query.Is("ended_at", "null").Gt("expires_at", time.Now().UTC().Format(time.RFC3339))
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var sessions []utils.IImpersonationSession
+
+	err := query.Execute(&sessions)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IImpersonationSession]{
+		Result: sessions,
+		Code:   http.StatusOK,
+	})
+}
+
+func StopImpersonationSession(ctx *fiber.Ctx) error {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	database := db.New()
+
+	session, err := utils.GetImpersonationSession(database, ctx.Params("session"))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if session == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("Impersonation session not found"), true)
+	}
+
+	if !session.Active(time.Now()) {
+		return utils.ErrorResponse(ctx, 409, errors.New("This impersonation session has already ended"), true)
+	}
+
+	ended, err := utils.EndImpersonationSession(ctx, database, *session, staff.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IImpersonationSession]{
+		Result: ended,
+		Code:   http.StatusOK,
+	})
+}
@@ -44,10 +44,14 @@ func AuthHandler(router fiber.Router) {
 	authed := router.Use(utils.AuthMiddleware, utils.ProfileMiddleware)
 	authed.Get("/providers", ProvidersHandler)
 	authed.Get("/providers/:provider", ProviderHandler)
-	authed.Post("/providers/:provider", UpdateProviderHandler)
+	authed.Get("/discord/guilds", DiscordGuildsHandler)
+	authed.Post("/providers/:provider", utils.NoImpersonationMiddleware, UpdateProviderHandler)
 	authed.Get("/status", StatusHandler)
+	authed.Post("/status/appeal", AppealHandler)
 	authed.Get("/gdpr", DataHandler)
-	authed.Post("/delete", DeleteAccountHandler)
+	authed.Post("/delete", utils.NoImpersonationMiddleware, DeleteAccountHandler)
+	authed.Get("/audit", AuditHandler)
+	authed.Post("/impersonation/stop", StopImpersonationHandler)
 }
 
 func InitGoth() {
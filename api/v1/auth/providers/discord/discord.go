@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/astralservices/api/blacklist"
 	"github.com/astralservices/api/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/markbates/goth"
@@ -92,6 +93,13 @@ func (p DiscordProvider) CreateUser() error {
 		return utils.ErrorResponse(ctx, 500, profileErr, false)
 	}
 
+	// detection must not hold up the sign-up
+	go func(id string) {
+		if _, err := blacklist.Detect(database, id, blacklist.TriggerSignup); err != nil {
+			log.Println("alt detection failed for", id, err)
+		}
+	}(*out[0].ID)
+
 	if redirect != "" {
 		TokenString, _ := utils.CreateToken(user.UserID, out[0])
 
@@ -0,0 +1,181 @@
+package discord
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/utils"
+	"github.com/markbates/goth"
+	"github.com/nedpals/supabase-go"
+)
+
+// ErrReauthorize is returned when the stored token cannot be used or refreshed, the user has to log in again
+var ErrReauthorize = errors.New("Your Discord login has expired, log in with Discord again")
+
+const (
+	// how long a refresh holds the provider row, longer than a refresh takes
+	refreshLease = 30 * time.Second
+	// how often and how many times a request waiting on another request's refresh looks for the new token
+	refreshPoll  = 500 * time.Millisecond
+	refreshPolls = 20
+)
+
+// stored reads the user's Discord provider. The token in the session is as old as the session, the stored one may
+// have been refreshed since.
+func stored(database *supabase.Client, user utils.IProvider) (utils.IProvider, error) {
+	var providers []utils.IProvider
+
+	err := database.DB.From("providers").Select("*").Eq("provider_id", user.ProviderID).Eq("type", "discord").Execute(&providers)
+
+	if err != nil {
+		return utils.IProvider{}, err
+	}
+
+	if len(providers) == 0 || providers[0].ProviderAccessToken == "" {
+		return utils.IProvider{}, ErrReauthorize
+	}
+
+	return providers[0], nil
+}
+
+// fresh reports whether a token can still be used, tokens are refreshed a little early so they do not expire on
+// the way to Discord
+func fresh(provider utils.IProvider) bool {
+	return provider.ProviderExpiresAt == nil || provider.ProviderExpiresAt.IsZero() || time.Now().Add(time.Minute).Before(*provider.ProviderExpiresAt)
+}
+
+// AccessToken returns the user's Discord access token, refreshing and saving it first when it has expired. Discord
+// replaces the refresh token on every refresh, so one request refreshes while the others wait for its token.
+func AccessToken(database *supabase.Client, user utils.IProvider) (string, error) {
+	provider, err := stored(database, user)
+
+	if err != nil {
+		return "", err
+	}
+
+	if fresh(provider) {
+		return provider.ProviderAccessToken, nil
+	}
+
+	var taken bool
+
+	err = utils.Rpc(database, "try_job_lock", map[string]interface{}{
+		"p_name":    "discord refresh " + *provider.ID,
+		"p_seconds": int(refreshLease.Seconds()),
+	}, &taken)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !taken {
+		for i := 0; i < refreshPolls; i++ {
+			time.Sleep(refreshPoll)
+
+			if provider, err = stored(database, user); err != nil {
+				return "", err
+			}
+
+			if fresh(provider) {
+				return provider.ProviderAccessToken, nil
+			}
+		}
+
+		return "", ErrReauthorize
+	}
+
+	// another refresh may have finished between reading the row and taking the lease
+	if provider, err = stored(database, user); err != nil {
+		return "", err
+	}
+
+	if fresh(provider) {
+		return provider.ProviderAccessToken, nil
+	}
+
+	if provider.ProviderRefreshToken == "" {
+		return "", ErrReauthorize
+	}
+
+	discord, err := goth.GetProvider("discord")
+
+	if err != nil {
+		return "", err
+	}
+
+	token, err := discord.RefreshToken(provider.ProviderRefreshToken)
+
+	if err != nil {
+		return "", ErrReauthorize
+	}
+
+	refreshToken := token.RefreshToken
+
+	if refreshToken == "" {
+		refreshToken = provider.ProviderRefreshToken
+	}
+
+	err = database.DB.From("providers").Update(map[string]interface{}{
+		"provider_access_token":  token.AccessToken,
+		"provider_refresh_token": refreshToken,
+		"provider_expires_at":    token.Expiry.UTC(),
+		"updated_at":             time.Now().UTC(),
+	}).Eq("id", *provider.ID).Execute(nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
+
+// ManageableGuilds lists the guilds the user has Manage Server in
+func ManageableGuilds(database *supabase.Client, user utils.IProvider) ([]discordapi.Guild, error) {
+	token, err := AccessToken(database, user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guilds, _, err := discordapi.NewBearer(token).CurrentUserGuilds()
+
+	var apiErr *discordapi.Error
+
+	// tokens can be revoked before they expire, and logins from before the guilds scope cannot list guilds
+	if errors.As(err, &apiErr) && (apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden) {
+		return nil, ErrReauthorize
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	manageable := []discordapi.Guild{}
+
+	for _, guild := range guilds {
+		if guild.CanManage() {
+			manageable = append(manageable, guild)
+		}
+	}
+
+	return manageable, nil
+}
+
+// CanManageGuild reports whether the user has Manage Server in a guild
+func CanManageGuild(database *supabase.Client, user utils.IProvider, guild string) (bool, error) {
+	guilds, err := ManageableGuilds(database, user)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, g := range guilds {
+		if g.ID == guild {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -2,10 +2,12 @@ package roblox
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/astralservices/api/blacklist"
 	"github.com/astralservices/api/utils"
 	"github.com/astralservices/goblox/goblox"
 	"github.com/gofiber/fiber/v2"
@@ -139,6 +141,12 @@ func (p RobloxProvider) VerifyUser() error {
 			return utils.ErrorResponse(ctx, 500, err, false)
 		}
 
+		go func(id string) {
+			if _, err := blacklist.Detect(database, id, blacklist.TriggerProviderLink); err != nil {
+				log.Println("alt detection failed for", id, err)
+			}
+		}(out[0].User)
+
 		if redirect != "" {
 			ctx.ClearCookie("redirect")
 			return ctx.Redirect(redirect)
@@ -14,6 +14,9 @@ import (
 	"github.com/astralservices/api/api/v1/auth/providers/discord"
 	"github.com/astralservices/api/api/v1/auth/providers/lastfm"
 	"github.com/astralservices/api/api/v1/auth/providers/roblox"
+	"github.com/astralservices/api/blacklist"
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/modules"
 	db "github.com/astralservices/api/supabase"
 	"github.com/astralservices/api/utils"
 	"github.com/gofiber/fiber/v2"
@@ -368,25 +371,125 @@ func ProvidersHandler(ctx *fiber.Ctx) error {
 	})
 }
 
+type DiscordGuild struct {
+	discordapi.Guild
+	// whether the workspace's bot is in the guild
+	Joined bool `json:"joined"`
+	// adds the workspace's bot to the guild, only set when it is not in it
+	InviteURL string `json:"inviteUrl,omitempty"`
+}
+
+// lists the guilds the user can manage, for picking the guild of a bot. With ?workspace= the guilds are marked with
+// whether the workspace's bot is in them, and the others get an invite link with the permissions its modules need.
+func DiscordGuildsHandler(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	manageable, err := discord.ManageableGuilds(database, user)
+
+	if errors.Is(err, discord.ErrReauthorize) {
+		return utils.ErrorResponse(ctx, 401, err, true)
+	}
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	guilds := make([]DiscordGuild, len(manageable))
+
+	for i, guild := range manageable {
+		guilds[i] = DiscordGuild{Guild: guild}
+	}
+
+	workspace := ctx.Query("workspace")
+
+	if workspace == "" {
+		return ctx.Status(200).JSON(utils.Response[[]DiscordGuild]{
+			Result: guilds,
+			Code:   http.StatusOK,
+		})
+	}
+
+	var members []utils.IWorkspaceMember
+
+	err = database.DB.From("workspace_members").Select("id").Eq("workspace", workspace).Eq("profile", *user.ID).Execute(&members)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(members) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Workspace not found"), true)
+	}
+
+	var bots []utils.IBot
+
+	err = database.DB.From("bots").Select("id, settings, token").Eq("workspace", workspace).Execute(&bots)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(bots) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Bot not found"), true)
+	}
+
+	bot := discordapi.New(bots[0].Token)
+
+	application, _, err := bot.CurrentApplication()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	joined, _, err := bot.CurrentUserGuilds()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	in := map[string]bool{}
+
+	for _, guild := range joined {
+		in[guild.ID] = true
+	}
+
+	permissions := discordapi.PermissionBits(append(modules.Permissions(bots[0].Settings.Modules), "VIEW_CHANNEL"))
+
+	for i := range guilds {
+		guilds[i].Joined = in[guilds[i].ID]
+
+		if !guilds[i].Joined {
+			guilds[i].InviteURL = discordapi.InviteURL(application.ID, permissions, guilds[i].ID)
+		}
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]DiscordGuild]{
+		Result: guilds,
+		Code:   http.StatusOK,
+	})
+}
+
 type StatusResponse struct {
-	Authenticated bool              `json:"authenticated"`
-	Blacklist     *utils.IBlacklist `json:"blacklist,omitempty"`
+	Authenticated bool                    `json:"authenticated"`
+	Blacklist     *utils.IBlacklist       `json:"blacklist,omitempty"`
+	Appeal        *utils.IBlacklistAppeal `json:"appeal,omitempty"`
 }
 
 func StatusHandler(ctx *fiber.Ctx) error {
 	user := ctx.Locals("user").(utils.IProvider)
-	var blacklist []utils.IBlacklist
 
 	database := db.New()
 
-	err := database.DB.From("blacklist").Select("*").Eq("user", *user.ID).Execute(&blacklist)
+	entry, err := utils.GetActiveBlacklist(database, *user.ID)
 
 	if err != nil {
 		return utils.ErrorResponse(ctx, 500, err, false)
 
 	}
 
-	if len(blacklist) == 0 {
+	if entry == nil {
 		return ctx.Status(200).JSON(utils.Response[StatusResponse]{
 			Result: StatusResponse{
 				Authenticated: true,
@@ -395,15 +498,80 @@ func StatusHandler(ctx *fiber.Ctx) error {
 		})
 	}
 
+	appeal, err := blacklist.GetAppealFor(database, *entry)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
 	return ctx.Status(200).JSON(utils.Response[StatusResponse]{
 		Result: StatusResponse{
 			Authenticated: true,
-			Blacklist:     &blacklist[0],
+			Blacklist:     entry,
+			Appeal:        appeal,
 		},
 		Code: http.StatusForbidden,
 	})
 }
 
+type AppealFormData struct {
+	Statement string `json:"statement" form:"statement"`
+}
+
+// lets a blacklisted user appeal their entry, or answer staff if they asked for more information
+func AppealHandler(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	var form AppealFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	database := db.New()
+
+	entry, err := utils.GetActiveBlacklist(database, *user.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if entry == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("You are not blacklisted"), true)
+	}
+
+	if strings.TrimSpace(form.Statement) == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("A statement is required"), true)
+	}
+
+	existing, err := blacklist.GetAppealFor(database, *entry)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if existing != nil && existing.Status != blacklist.AppealInfoRequested {
+		return utils.ErrorResponse(ctx, 409, errors.New("You have already appealed this blacklist"), true)
+	}
+
+	appeal, err := blacklist.FileAppeal(database, *entry, *user.ID, strings.TrimSpace(form.Statement))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[StatusResponse]{
+		Result: StatusResponse{
+			Authenticated: true,
+			Blacklist:     entry,
+			Appeal:        &appeal,
+		},
+		Code: http.StatusOK,
+	})
+}
+
 // gets all the user's data and returns it as an actual JSON file
 func DataHandler(ctx *fiber.Ctx) error {
 	user := ctx.Locals("user").(utils.IProvider)
@@ -544,3 +712,53 @@ func DeleteAccountHandler(ctx *fiber.Ctx) error {
 		Code:   http.StatusOK,
 	})
 }
+
+// lists the audited actions staff have taken on the user's account, including every impersonated request
+func AuditHandler(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	query := database.DB.From("audit_logs").Select("created_at, actor, action, data, method, path").Limit(200).Eq("target_type", "user").Eq("target", *user.ID)
+
+	if action := ctx.Query("action"); action != "" {
+		query.Eq("action", action)
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var logs []utils.IAuditLog
+
+	err := query.Execute(&logs)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IAuditLog]{
+		Result: logs,
+		Code:   http.StatusOK,
+	})
+}
+
+// ends the impersonation session the request was made with
+func StopImpersonationHandler(ctx *fiber.Ctx) error {
+	session, ok := ctx.Locals("impersonation").(utils.IImpersonationSession)
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 400, errors.New("You are not impersonating anyone"), true)
+	}
+
+	session, err := utils.EndImpersonationSession(ctx, db.New(), session, session.Staff)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	ctx.ClearCookie("token")
+
+	return ctx.Status(200).JSON(utils.Response[utils.IImpersonationSession]{
+		Result: session,
+		Code:   http.StatusOK,
+	})
+}
@@ -0,0 +1,10 @@
+package billing
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func BillingHandler(router fiber.Router) {
+	// authenticated by Stripe's signature instead of a user
+	router.Post("/webhook", StripeWebhook)
+}
@@ -0,0 +1,56 @@
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/astralservices/api/blacklist"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+// receives Stripe's events, only saved cards are handled so far. Failures answer 500 so Stripe delivers the event again.
+func StripeWebhook(ctx *fiber.Ctx) error {
+	secret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+
+	if secret == "" {
+		return webhookError(ctx, http.StatusServiceUnavailable, errors.New("Stripe webhooks are not configured"))
+	}
+
+	event, err := webhook.ConstructEvent(ctx.Body(), ctx.Get("Stripe-Signature"), secret)
+
+	if err != nil {
+		return webhookError(ctx, http.StatusBadRequest, err)
+	}
+
+	switch event.Type {
+	case "payment_method.attached":
+		var method stripe.PaymentMethod
+
+		if err = json.Unmarshal(event.Data.Raw, &method); err != nil {
+			return webhookError(ctx, http.StatusBadRequest, err)
+		}
+
+		if _, err = blacklist.RecordPaymentMethod(db.New(), &method); err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+	}
+
+	return ctx.Status(200).JSON(utils.Response[any]{
+		Code: http.StatusOK,
+	})
+}
+
+// answers Stripe with the status itself, ErrorResponse always answers 500 which Stripe retries
+func webhookError(ctx *fiber.Ctx, status int, err error) error {
+	return ctx.Status(status).JSON(utils.Response[any]{
+		Result: nil,
+		Code:   status,
+		Error:  err.Error(),
+	})
+}
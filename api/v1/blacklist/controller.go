@@ -0,0 +1,28 @@
+package blacklist
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func BlacklistHandler(router fiber.Router) {
+	staff := router.Use(utils.AuthMiddleware, utils.NoImpersonationMiddleware, utils.ProfileMiddleware, utils.AccessMiddleware("moderator"))
+	// appeals and detections are registered first so they are not matched as entries
+	staff.Get("/appeals", GetBlacklistAppeals)
+	staff.Get("/appeals/:appeal", GetBlacklistAppeal)
+	staff.Post("/appeals/:appeal/approve", ReviewBlacklistAppeal("approved"))
+	staff.Post("/appeals/:appeal/deny", ReviewBlacklistAppeal("denied"))
+	staff.Post("/appeals/:appeal/request-info", ReviewBlacklistAppeal("info_requested"))
+	staff.Get("/detections", GetAltDetections)
+	staff.Get("/detections/:detection", GetAltDetection)
+	staff.Post("/detections/:detection/confirm", ReviewAltDetection(true))
+	staff.Post("/detections/:detection/dismiss", ReviewAltDetection(false))
+
+	staff.Get("/", SearchBlacklist)
+	staff.Post("/", CreateBlacklistEntry)
+	staff.Get("/:entry", GetBlacklistEntry)
+	staff.Put("/:entry", UpdateBlacklistEntry)
+	staff.Post("/:entry", UpdateBlacklistEntry) // Fallback for HTML Forms
+	staff.Delete("/:entry", LiftBlacklistEntry)
+	staff.Post("/:entry/lift", LiftBlacklistEntry) // Fallback for HTML Forms
+}
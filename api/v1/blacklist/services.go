@@ -0,0 +1,512 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/blacklist"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BlacklistFormData struct {
+	User           *string   `json:"user,omitempty" form:"user"`
+	DiscordID      *string   `json:"discord_id,omitempty" form:"discord_id"`
+	Reason         *string   `json:"reason,omitempty" form:"reason"`
+	Expires        *bool     `json:"expires,omitempty" form:"expires"`
+	Expiry         *string   `json:"expiry,omitempty" form:"expiry"`
+	Flags          any       `json:"flags,omitempty" form:"flags"`
+	FactorMatching *[]string `json:"factor_matching,omitempty" form:"factor_matching"`
+	Notes          *string   `json:"notes,omitempty" form:"notes"`
+}
+
+// changes converts the submitted fields into a row update
+func (f BlacklistFormData) changes() (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+
+	if f.Reason != nil {
+		changes["reason"] = *f.Reason
+	}
+
+	if f.Expires != nil {
+		changes["expires"] = *f.Expires
+	}
+
+	if f.Expiry != nil && *f.Expiry != "" {
+		expiry, err := time.Parse(time.RFC3339, *f.Expiry)
+
+		if err != nil {
+			return nil, errors.New("expiry must be an RFC 3339 timestamp")
+		}
+
+		changes["expiry"] = expiry.UTC()
+	}
+
+	if f.Flags != nil {
+		changes["flags"] = f.Flags
+	}
+
+	if f.FactorMatching != nil {
+		changes["factor_matching"] = *f.FactorMatching
+	}
+
+	if f.Notes != nil {
+		changes["notes"] = *f.Notes
+	}
+
+	return changes, nil
+}
+
+// checkExpiry makes sure an entry that expires has an expiry in the future once the changes are saved, entry is nil
+// for new entries. Entries whose expiry has passed are lifted by the expiry job, which keeps a note of it.
+func checkExpiry(entry *utils.IBlacklist, changes map[string]interface{}, now time.Time) error {
+	expires, expiresChanged := changes["expires"].(bool)
+	expiry, expiryChanged := changes["expiry"].(time.Time)
+
+	if !expiresChanged && entry != nil {
+		expires = entry.Expires
+	}
+
+	if !expires || !expiresChanged && !expiryChanged {
+		return nil
+	}
+
+	if !expiryChanged {
+		if entry == nil || !entry.Expires {
+			return errors.New("Expiring entries need an expiry")
+		}
+
+		expiry = entry.Expiry
+	}
+
+	if !expiry.After(now) {
+		return errors.New("The expiry must be in the future")
+	}
+
+	return nil
+}
+
+func entryResponse(ctx *fiber.Ctx, entry utils.IBlacklist) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBlacklist]{
+		Result: entry,
+		Code:   http.StatusOK,
+	})
+}
+
+func SearchBlacklist(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	query := database.DB.From("blacklist").Select("*")
+
+	for _, filter := range []string{"user", "discord_id", "moderator", "lifted"} {
+		if value := ctx.Query(filter); value != "" {
+			query.Eq(filter, value)
+		}
+	}
+
+	if q := ctx.Query("q"); q != "" {
+		query.Ilike("reason", "%"+q+"%")
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var entries []utils.IBlacklist
+
+	err := query.Execute(&entries)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if ctx.Query("active") != "" {
+		active := ctx.Query("active") == "true"
+		now := time.Now()
+		filtered := []utils.IBlacklist{}
+
+		for _, entry := range entries {
+			if entry.Active(now) == active {
+				filtered = append(filtered, entry)
+			}
+		}
+
+		entries = filtered
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBlacklist]{
+		Result: entries,
+		Code:   http.StatusOK,
+	})
+}
+
+func CreateBlacklistEntry(ctx *fiber.Ctx) error {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	var form BlacklistFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	row, err := form.changes()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if form.Reason == nil || *form.Reason == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("A reason is required"), true)
+	}
+
+	if err = checkExpiry(nil, row, time.Now()); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	database := db.New()
+
+	// entries are matched by profile, so resolve whichever identifier was given to the profile
+	var profiles []utils.IProfile
+
+	if form.User != nil && *form.User != "" {
+		err = database.DB.From("profiles").Select("id, discord_id").Eq("id", *form.User).Execute(&profiles)
+	} else if form.DiscordID != nil && *form.DiscordID != "" {
+		err = database.DB.From("profiles").Select("id, discord_id").Eq("discord_id", *form.DiscordID).Execute(&profiles)
+	} else {
+		return utils.ErrorResponse(ctx, 400, errors.New("A user or Discord ID is required"), true)
+	}
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(profiles) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("User not found"), true)
+	}
+
+	row["user"] = profiles[0].ID
+	row["discord_id"] = profiles[0].DiscordID
+	row["moderator"] = staff.ID
+
+	var entries []utils.IBlacklist
+
+	err = database.DB.From("blacklist").Insert(row).Execute(&entries)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	utils.AuditRequest(ctx, database, staff.ID, "blacklist.create", "blacklist", strconv.FormatInt(entries[0].ID, 10), row)
+
+	return entryResponse(ctx, entries[0])
+}
+
+func getEntry(ctx *fiber.Ctx) (*utils.IBlacklist, error) {
+	entry, err := blacklist.Get(db.New(), ctx.Params("entry"))
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if entry == nil {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("Blacklist entry not found"), true)
+	}
+
+	return entry, nil
+}
+
+func GetBlacklistEntry(ctx *fiber.Ctx) error {
+	entry, err := getEntry(ctx)
+
+	if entry == nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBlacklist]{
+		Result: *entry,
+		Code:   http.StatusOK,
+	})
+}
+
+func UpdateBlacklistEntry(ctx *fiber.Ctx) error {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	entry, err := getEntry(ctx)
+
+	if entry == nil {
+		return err
+	}
+
+	var form BlacklistFormData
+
+	err = ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	changes, err := form.changes()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if len(changes) == 0 {
+		return entryResponse(ctx, *entry)
+	}
+
+	if err = checkExpiry(entry, changes, time.Now()); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	database := db.New()
+
+	updated, err := blacklist.Update(database, *entry, changes)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	utils.AuditRequest(ctx, database, staff.ID, "blacklist.update", "blacklist", strconv.FormatInt(entry.ID, 10), changes)
+
+	return entryResponse(ctx, updated)
+}
+
+func LiftBlacklistEntry(ctx *fiber.Ctx) error {
+	staff := ctx.Locals("profile").(utils.IProfile)
+
+	entry, err := getEntry(ctx)
+
+	if entry == nil {
+		return err
+	}
+
+	if entry.Lifted {
+		return utils.ErrorResponse(ctx, 409, errors.New("This entry has already been lifted"), true)
+	}
+
+	data := struct {
+		Reason string `json:"reason" form:"reason"`
+	}{}
+
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&data); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	database := db.New()
+
+	lifted, err := blacklist.Lift(database, *entry, staff.ID, data.Reason)
+
+	if errors.Is(err, blacklist.ErrLifted) {
+		return utils.ErrorResponse(ctx, 409, err, true)
+	}
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	utils.AuditRequest(ctx, database, staff.ID, "blacklist.lift", "blacklist", strconv.FormatInt(entry.ID, 10), map[string]any{"reason": data.Reason})
+
+	return entryResponse(ctx, lifted)
+}
+
+type AppealReviewResponse struct {
+	Appeal utils.IBlacklistAppeal `json:"appeal"`
+	Entry  *utils.IBlacklist      `json:"entry,omitempty"`
+}
+
+func GetBlacklistAppeals(ctx *fiber.Ctx) error {
+	appeals, err := blacklist.ListAppeals(db.New(), ctx.Query("status", blacklist.AppealPending))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBlacklistAppeal]{
+		Result: appeals,
+		Code:   http.StatusOK,
+	})
+}
+
+func getAppeal(ctx *fiber.Ctx) (*utils.IBlacklistAppeal, *utils.IBlacklist, error) {
+	database := db.New()
+
+	appeal, err := blacklist.GetAppeal(database, ctx.Params("appeal"))
+
+	if err != nil {
+		return nil, nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if appeal == nil {
+		return nil, nil, utils.ErrorResponse(ctx, 404, errors.New("Appeal not found"), true)
+	}
+
+	entry, err := blacklist.Get(database, strconv.FormatInt(appeal.Blacklist, 10))
+
+	if err != nil {
+		return nil, nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if entry == nil {
+		return nil, nil, utils.ErrorResponse(ctx, 404, errors.New("Blacklist entry not found"), true)
+	}
+
+	return appeal, entry, nil
+}
+
+func GetBlacklistAppeal(ctx *fiber.Ctx) error {
+	appeal, entry, err := getAppeal(ctx)
+
+	if appeal == nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(utils.Response[AppealReviewResponse]{
+		Result: AppealReviewResponse{Appeal: *appeal, Entry: entry},
+		Code:   http.StatusOK,
+	})
+}
+
+func ReviewBlacklistAppeal(decision string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		staff := ctx.Locals("profile").(utils.IProfile)
+
+		appeal, entry, err := getAppeal(ctx)
+
+		if appeal == nil {
+			return err
+		}
+
+		data := struct {
+			Response string `json:"response" form:"response"`
+		}{}
+
+		if len(ctx.Body()) > 0 {
+			if err = ctx.BodyParser(&data); err != nil {
+				return utils.ErrorResponse(ctx, 400, err, true)
+			}
+		}
+
+		response := data.Response
+
+		if err = blacklist.ValidateReview(*appeal, decision, response); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+
+		database := db.New()
+
+		reviewed, err := blacklist.ReviewAppeal(database, *entry, *appeal, decision, staff.ID, response)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		utils.AuditRequest(ctx, database, staff.ID, "blacklist.appeal."+decision, "blacklist_appeal", *appeal.ID, map[string]any{"response": response})
+
+		entry, err = blacklist.Get(database, strconv.FormatInt(entry.ID, 10))
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		redirect := ctx.FormValue("redirect")
+
+		if redirect != "" {
+			return ctx.Redirect(redirect)
+		}
+
+		return ctx.Status(200).JSON(utils.Response[AppealReviewResponse]{
+			Result: AppealReviewResponse{Appeal: reviewed, Entry: entry},
+			Code:   http.StatusOK,
+		})
+	}
+}
+
+func GetAltDetections(ctx *fiber.Ctx) error {
+	detections, err := blacklist.ListDetections(db.New(), ctx.Query("status", blacklist.DetectionPending))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IAltDetection]{
+		Result: detections,
+		Code:   http.StatusOK,
+	})
+}
+
+func getDetection(ctx *fiber.Ctx) (*utils.IAltDetection, error) {
+	detection, err := blacklist.GetDetection(db.New(), ctx.Params("detection"))
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if detection == nil {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("Detection not found"), true)
+	}
+
+	return detection, nil
+}
+
+func GetAltDetection(ctx *fiber.Ctx) error {
+	detection, err := getDetection(ctx)
+
+	if detection == nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IAltDetection]{
+		Result: *detection,
+		Code:   http.StatusOK,
+	})
+}
+
+func ReviewAltDetection(confirm bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		staff := ctx.Locals("profile").(utils.IProfile)
+
+		detection, err := getDetection(ctx)
+
+		if detection == nil {
+			return err
+		}
+
+		if detection.Status != blacklist.DetectionPending {
+			return utils.ErrorResponse(ctx, 409, fmt.Errorf("This detection has already been %s", detection.Status), true)
+		}
+
+		database := db.New()
+
+		reviewed, err := blacklist.ReviewDetection(database, *detection, confirm, staff.ID)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		utils.AuditRequest(ctx, database, staff.ID, "blacklist.detection."+reviewed.Status, "alt_detection", *detection.ID, nil)
+
+		redirect := ctx.FormValue("redirect")
+
+		if redirect != "" {
+			return ctx.Redirect(redirect)
+		}
+
+		return ctx.Status(200).JSON(utils.Response[utils.IAltDetection]{
+			Result: reviewed,
+			Code:   http.StatusOK,
+		})
+	}
+}
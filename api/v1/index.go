@@ -6,8 +6,15 @@ import (
 	"os"
 	"sort"
 
+	"github.com/astralservices/api/api/v1/admin"
 	"github.com/astralservices/api/api/v1/auth"
+	"github.com/astralservices/api/api/v1/billing"
+	"github.com/astralservices/api/api/v1/blacklist"
+	"github.com/astralservices/api/api/v1/runners"
 	"github.com/astralservices/api/api/v1/workspaces"
+	"github.com/astralservices/api/commands"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/permissions"
 	db "github.com/astralservices/api/supabase"
 	"github.com/astralservices/api/utils"
 	"github.com/aybabtme/orderedjson"
@@ -21,9 +28,16 @@ func V1Handler(router fiber.Router) {
 	router.Get("/plans", PlansHandler)
 	router.Get("/integrations", IntegrationsHandler)
 	router.Get("/integrations/:id", IntegrationHandler)
+	router.Get("/commands", CommandsHandler)
+	router.Get("/modules", ModulesHandler)
+	router.Post("/embeds/preview", EmbedPreviewHandler)
 
 	auth.AuthHandler(router.Group("/auth").Use(utils.AuthInjectorMiddleware))
 	workspaces.WorkspacesHandler(router.Group("/workspaces"))
+	runners.RunnersHandler(router.Group("/runners"))
+	blacklist.BlacklistHandler(router.Group("/blacklist"))
+	admin.AdminHandler(router.Group("/admin"))
+	billing.BillingHandler(router.Group("/billing"))
 }
 
 func PlansHandler(c *fiber.Ctx) error {
@@ -191,3 +205,51 @@ func IntegrationHandler(c *fiber.Ctx) error {
 		Code:   http.StatusOK,
 	})
 }
+
+type CommandsCatalog struct {
+	Commands          []commands.Command `json:"commands"`
+	TemplateVariables map[string]string  `json:"templateVariables"`
+	// permission nodes that are not commands
+	Nodes []string `json:"nodes"`
+}
+
+func CommandsHandler(c *fiber.Ctx) error {
+	return c.JSON(utils.Response[CommandsCatalog]{
+		Result: CommandsCatalog{
+			Commands:          commands.Catalog(),
+			TemplateVariables: commands.TemplateVariables,
+			Nodes:             permissions.Nodes,
+		},
+		Code: http.StatusOK,
+	})
+}
+
+func ModulesHandler(c *fiber.Ctx) error {
+	return c.JSON(utils.Response[[]modules.Module]{
+		Result: modules.Catalog(),
+		Code:   http.StatusOK,
+	})
+}
+
+// EmbedPreviewHandler converts an embed posted in the form shape into Discord's and checks it against Discord's
+// limits, problems are part of the preview rather than an error so editors can show them as the embed is written
+func EmbedPreviewHandler(c *fiber.Ctx) error {
+	var form utils.EmbedForm
+
+	err := c.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, err, true)
+	}
+
+	embed, err := form.Embed()
+
+	if err != nil {
+		return utils.ErrorResponse(c, http.StatusBadRequest, err, true)
+	}
+
+	return c.JSON(utils.Response[utils.EmbedPreview]{
+		Result: embed.Preview(),
+		Code:   http.StatusOK,
+	})
+}
@@ -0,0 +1,20 @@
+package runners
+
+import (
+	"github.com/astralservices/api/api/v1/workspaces"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RunnersHandler(router fiber.Router) {
+	authed := router.Use(utils.RunnerMiddleware)
+
+	botRouter := authed.Group("/bots/:bot_id").Use(utils.RunnerBotMiddleware)
+	botRouter.Post("/analytics", IngestAnalytics)
+	botRouter.Get("/commands", GetRunnerCommands)
+	botRouter.Post("/commands/:command/ack", AckRunnerCommand)
+	botRouter.Post("/permissions/evaluate", workspaces.EvaluateBotPermissions)
+	botRouter.Post("/automod/evaluate", EvaluateAutomod)
+	botRouter.Get("/reactionroles", GetReactionRoles)
+	botRouter.Put("/reactionroles/:menu/message", SetReactionRoleMessage)
+}
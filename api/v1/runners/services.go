@@ -0,0 +1,241 @@
+package runners
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/astralservices/api/analytics"
+	"github.com/astralservices/api/announcements"
+	"github.com/astralservices/api/automod"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/reactionroles"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+func IngestAnalytics(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var batch utils.IBotAnalyticsBatch
+
+	err := ctx.BodyParser(&batch)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	commands, err := analytics.ValidateBatch(batch.Samples)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	result, err := analytics.Ingest(db.New(), *bot.ID, batch.Samples, commands)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[analytics.IngestResult]{
+		Result: result,
+		Code:   http.StatusOK,
+	})
+}
+
+// hands out pending commands, and delivered commands that were never acknowledged, marking them as delivered.
+// Claiming happens in one statement, so concurrent polls never get the same command.
+func GetRunnerCommands(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	database := db.New()
+
+	var claimed []utils.IRunnerCommand
+
+	err := utils.Rpc(database, "claim_runner_commands", map[string]interface{}{
+		"p_bot":             *bot.ID,
+		"p_limit":           100,
+		"p_timeout_seconds": int(utils.RunnerCommandVisibilityTimeout.Seconds()),
+		"p_max_attempts":    utils.RunnerCommandMaxAttempts,
+	}, &claimed)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	commands := []utils.IRunnerCommand{}
+
+	for _, command := range claimed {
+		if command.Status != utils.RunnerCommandDead {
+			commands = append(commands, command)
+			continue
+		}
+
+		log.Warnf("runner command %s of bot %s was dead-lettered after %d deliveries", command.ID, command.Bot, command.Attempts)
+
+		if command.Type == announcements.CommandType {
+			if err = announcements.Complete(database, command); err != nil {
+				return utils.ErrorResponse(ctx, 500, err, false)
+			}
+		}
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IRunnerCommand]{
+		Result: commands,
+		Code:   http.StatusOK,
+	})
+}
+
+func AckRunnerCommand(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	data := struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}{}
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	update := map[string]interface{}{
+		"status":       utils.RunnerCommandSucceeded,
+		"completed_at": time.Now().UTC(),
+	}
+
+	if !data.Success {
+		update["status"] = utils.RunnerCommandFailed
+		update["error"] = data.Error
+	}
+
+	var commands []utils.IRunnerCommand
+
+	database := db.New()
+
+	// dead-lettered commands were already cleaned up after, a late acknowledgement does not revive them
+	err = database.DB.From("runner_commands").Update(update).Eq("bot", *bot.ID).Eq("id", ctx.Params("command")).Neq("status", utils.RunnerCommandDead).Execute(&commands)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(commands) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Command not found, or dead-lettered after too many deliveries"), true)
+	}
+
+	if commands[0].Type == announcements.CommandType {
+		if err = announcements.Complete(database, commands[0]); err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IRunnerCommand]{
+		Result: commands[0],
+		Code:   http.StatusOK,
+	})
+}
+
+// evaluates a message against the bot's automod rules, for runners that cannot use the automod package directly
+func EvaluateAutomod(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var message automod.Message
+
+	err := ctx.BodyParser(&message)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	result := automod.Result{Matches: []automod.Match{}, Actions: []string{}}
+
+	if settings, _ := modules.Resolve(bot.Settings.Modules, "automod"); settings.Enabled {
+		config, err := automod.ParseConfig(settings.Options)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		result = automod.Evaluate(config, message)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[automod.Result]{
+		Result: result,
+		Code:   http.StatusOK,
+	})
+}
+
+// the bot's menus compiled for runners, empty when the module is disabled
+func GetReactionRoles(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	lookup := reactionroles.Compile(reactionroles.Config{}, nil)
+
+	if settings, _ := modules.Resolve(bot.Settings.Modules, "reactionroles"); settings.Enabled {
+		config, err := reactionroles.ParseConfig(settings.Options)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		messages, err := reactionroles.Messages(db.New(), *bot.ID)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		lookup = reactionroles.Compile(config, messages)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[reactionroles.Lookup]{
+		Result: lookup,
+		Code:   http.StatusOK,
+	})
+}
+
+// records the message a runner posted a menu as, so reactions on it can be looked up
+func SetReactionRoleMessage(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	data := struct {
+		Channel string `json:"channel"`
+		Message string `json:"message"`
+	}{}
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if data.Channel == "" || data.Message == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("A channel and a message are required"), true)
+	}
+
+	settings, _ := modules.Resolve(bot.Settings.Modules, "reactionroles")
+
+	config, err := reactionroles.ParseConfig(settings.Options)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if config.Find(ctx.Params("menu")) < 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Menu not found"), true)
+	}
+
+	message, err := reactionroles.SetMessage(db.New(), *bot.ID, ctx.Params("menu"), data.Channel, data.Message)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IReactionRoleMessage]{
+		Result: message,
+		Code:   http.StatusOK,
+	})
+}
@@ -0,0 +1,19 @@
+package announcements
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func AnnouncementsHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", GetAnnouncements)
+	members.Post("/", CreateAnnouncement)
+	members.Get("/:announcement", GetAnnouncement)
+	members.Put("/:announcement", UpdateAnnouncement)
+	members.Post("/:announcement", UpdateAnnouncement) // Fallback for HTML Forms
+	members.Delete("/:announcement", utils.NoImpersonationMiddleware, DeleteAnnouncement)
+	members.Post("/:announcement/delete", utils.NoImpersonationMiddleware, DeleteAnnouncement) // Fallback for HTML Forms
+	members.Get("/:announcement/runs", GetAnnouncementRuns)
+}
@@ -0,0 +1,313 @@
+package announcements
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/announcements"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nedpals/supabase-go"
+)
+
+type AnnouncementFormData struct {
+	Name    *string `json:"name,omitempty" form:"name"`
+	Enabled *bool   `json:"enabled,omitempty" form:"enabled"`
+	Channel *string `json:"channel,omitempty" form:"channel"`
+	Content *string `json:"content,omitempty" form:"content"`
+	// posted as embed.title and so on, an embed with every field empty removes the announcement's embed
+	Embed        *utils.EmbedForm `json:"embed,omitempty" form:"embed"`
+	ScheduleType *string          `json:"scheduleType,omitempty" form:"scheduleType"`
+	Schedule     *string          `json:"schedule,omitempty" form:"schedule"`
+	Timezone     *string          `json:"timezone,omitempty" form:"timezone"`
+	StartAt      *time.Time       `json:"startAt,omitempty" form:"startAt"`
+}
+
+// apply copies the submitted fields onto an announcement
+func (f AnnouncementFormData) apply(announcement utils.IBotAnnouncement) (utils.IBotAnnouncement, error) {
+	if f.Name != nil {
+		announcement.Name = *f.Name
+	}
+
+	if f.Enabled != nil {
+		announcement.Enabled = *f.Enabled
+	}
+
+	if f.Channel != nil {
+		announcement.Channel = *f.Channel
+	}
+
+	if f.Content != nil {
+		announcement.Content = *f.Content
+	}
+
+	if f.Embed != nil {
+		embed, err := f.Embed.Embed()
+
+		if err != nil {
+			return announcement, err
+		}
+
+		if reflect.DeepEqual(embed, utils.Embed{}) {
+			announcement.Embed = nil
+		} else {
+			announcement.Embed = &embed
+		}
+	}
+
+	if f.ScheduleType != nil {
+		announcement.ScheduleType = *f.ScheduleType
+	}
+
+	if f.Schedule != nil {
+		announcement.Schedule = *f.Schedule
+	}
+
+	if f.Timezone != nil {
+		announcement.Timezone = *f.Timezone
+	}
+
+	if f.StartAt != nil {
+		announcement.StartAt = f.StartAt
+	}
+
+	return announcement, nil
+}
+
+type AnnouncementDetails struct {
+	utils.IBotAnnouncement
+	// the next few runs, for checking a schedule does what was meant
+	Upcoming []time.Time                 `json:"upcoming"`
+	Runs     []utils.IBotAnnouncementRun `json:"runs"`
+}
+
+func getAnnouncement(ctx *fiber.Ctx, database *supabase.Client) (*utils.IBotAnnouncement, error) {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	announcement, err := announcements.Get(database, *bot.ID, ctx.Params("announcement"))
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if announcement == nil {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("Announcement not found"), true)
+	}
+
+	return announcement, nil
+}
+
+func announcementResponse(ctx *fiber.Ctx, announcement utils.IBotAnnouncement) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBotAnnouncement]{
+		Result: announcement,
+		Code:   http.StatusOK,
+	})
+}
+
+// reschedule checks an announcement and works out its next run
+func reschedule(announcement utils.IBotAnnouncement) (utils.IBotAnnouncement, error) {
+	var err error
+
+	if announcement.Timezone == "" {
+		announcement.Timezone = "UTC"
+	}
+
+	// RRULEs repeat from when they were saved unless they say otherwise
+	if announcement.ScheduleType == announcements.ScheduleRRule && announcement.StartAt == nil {
+		now := time.Now().UTC()
+		announcement.StartAt = &now
+	}
+
+	announcement.NextRunAt, err = announcements.NextRun(announcement, time.Now().UTC())
+
+	return announcement, err
+}
+
+func GetAnnouncements(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	list, err := announcements.List(db.New(), *bot.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBotAnnouncement]{
+		Result: list,
+		Code:   http.StatusOK,
+	})
+}
+
+func CreateAnnouncement(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	var form AnnouncementFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	database := db.New()
+
+	existing, err := announcements.List(database, *bot.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(existing) >= announcements.MaxAnnouncements {
+		return utils.ErrorResponse(ctx, 400, fmt.Errorf("Bots can have at most %d announcements", announcements.MaxAnnouncements), true)
+	}
+
+	announcement, err := form.apply(utils.IBotAnnouncement{
+		Bot:          *bot.ID,
+		Enabled:      true,
+		ScheduleType: announcements.ScheduleCron,
+		CreatedBy:    *user.ID,
+	})
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	announcement, err = reschedule(announcement)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	announcement, err = announcements.Create(database, announcement)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return announcementResponse(ctx, announcement)
+}
+
+func GetAnnouncement(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	announcement, err := getAnnouncement(ctx, database)
+
+	if announcement == nil {
+		return err
+	}
+
+	details := AnnouncementDetails{IBotAnnouncement: *announcement, Upcoming: []time.Time{}}
+
+	if schedule, err := announcements.Validate(*announcement); err == nil && announcement.Enabled {
+		details.Upcoming = announcements.Upcoming(schedule, time.Now().UTC(), 5)
+	}
+
+	details.Runs, err = announcements.Runs(database, *announcement.ID, 10)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[AnnouncementDetails]{
+		Result: details,
+		Code:   http.StatusOK,
+	})
+}
+
+func UpdateAnnouncement(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	announcement, err := getAnnouncement(ctx, database)
+
+	if announcement == nil {
+		return err
+	}
+
+	var form AnnouncementFormData
+
+	err = ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	updated, err := form.apply(*announcement)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	updated, err = reschedule(updated)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	updated, err = announcements.Update(database, updated)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return announcementResponse(ctx, updated)
+}
+
+func DeleteAnnouncement(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	announcement, err := getAnnouncement(ctx, database)
+
+	if announcement == nil {
+		return err
+	}
+
+	err = announcements.Delete(database, announcement.Bot, *announcement.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return announcementResponse(ctx, *announcement)
+}
+
+// the run history of an announcement, newest first, limit defaults to 50 and is at most 100
+func GetAnnouncementRuns(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	announcement, err := getAnnouncement(ctx, database)
+
+	if announcement == nil {
+		return err
+	}
+
+	limit := 50
+
+	if value := ctx.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+			return utils.ErrorResponse(ctx, 400, errors.New("limit must be between 1 and 100"), true)
+		}
+	}
+
+	runs, err := announcements.Runs(database, *announcement.ID, limit)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBotAnnouncementRun]{
+		Result: runs,
+		Code:   http.StatusOK,
+	})
+}
@@ -0,0 +1,106 @@
+// Package checks holds the checks a bot's config has to pass before it is saved, shared by the routes that save one
+package checks
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/astralservices/api/api/v1/auth/providers/discord"
+	"github.com/astralservices/api/commands"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/permissions"
+	"github.com/astralservices/api/presence"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+var ErrGuildAccess = errors.New("You need the Manage Server permission in this guild")
+
+// errGuildLookup wraps the errors of looking up the user's guilds, which are not the user's to fix
+var errGuildLookup = errors.New("could not check the user's guilds")
+
+// Config checks every part of a config the way each part is checked when it is saved on its own, normalising its
+// commands and module options, and that the user can manage the guild it points the bot at. Permission rules and
+// the guild the bot already has are not checked again. Creating a bot checks its config against an empty bot.
+func Config(database *supabase.Client, user utils.IProvider, workspace utils.IWorkspace, bot utils.IBot, config *utils.IBotVersionConfig) error {
+	if len(config.Commands) > commands.MaxCommands {
+		return fmt.Errorf("bots can have at most %d commands", commands.MaxCommands)
+	}
+
+	seen := map[string]bool{}
+
+	for i, command := range config.Commands {
+		if seen[command.ID] {
+			return fmt.Errorf("the %s command is listed more than once", command.ID)
+		}
+
+		seen[command.ID] = true
+
+		command, err := commands.Validate(command)
+
+		if err != nil {
+			return fmt.Errorf("commands.%d: %w", i, err)
+		}
+
+		config.Commands[i] = command
+	}
+
+	if config.Commands == nil {
+		config.Commands = []utils.IBotCommand{}
+	}
+
+	err := permissions.Validate(bot.Permissions, config.Permissions, permissions.KnownNodes(config.Commands))
+
+	if err != nil {
+		return err
+	}
+
+	if err = presence.Validate(config.Settings); err != nil {
+		return err
+	}
+
+	if config.Settings.Modules, err = modules.ValidateAll(workspace, config.Settings.Modules); err != nil {
+		return err
+	}
+
+	// last, as it asks Discord
+	return Guild(database, user, bot.Settings.Guild, config.Settings.Guild)
+}
+
+// Guild checks that the user has Manage Server in the guild a bot is pointed at, so a bot can only be added to
+// guilds the user could add it to themselves. Guilds that do not change are not checked again.
+func Guild(database *supabase.Client, user utils.IProvider, current string, guild string) error {
+	if guild == "" || guild == current {
+		return nil
+	}
+
+	manageable, err := discord.CanManageGuild(database, user, guild)
+
+	if errors.Is(err, discord.ErrReauthorize) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", errGuildLookup, err)
+	}
+
+	if !manageable {
+		return ErrGuildAccess
+	}
+
+	return nil
+}
+
+// Status returns the status code to answer a failed check with, and whether its message is meant for users
+func Status(err error) (int, bool) {
+	switch {
+	case errors.Is(err, discord.ErrReauthorize):
+		return 401, true
+	case errors.Is(err, ErrGuildAccess):
+		return 403, true
+	case errors.Is(err, errGuildLookup):
+		return 500, false
+	default:
+		return 400, true
+	}
+}
@@ -0,0 +1,20 @@
+package commands
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func CommandsHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", GetCommands)
+	members.Post("/", CreateCommand)
+	members.Get("/:command", GetCommand)
+	members.Put("/:command", UpdateCommand)
+	members.Post("/:command", UpdateCommand) // Fallback for HTML Forms
+	members.Delete("/:command", utils.NoImpersonationMiddleware, DeleteCommand)
+	members.Post("/:command/delete", utils.NoImpersonationMiddleware, DeleteCommand) // Fallback for HTML Forms
+	members.Post("/:command/enable", SetCommandEnabled(true))
+	members.Post("/:command/disable", SetCommandEnabled(false))
+}
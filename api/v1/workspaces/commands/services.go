@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/astralservices/api/commands"
+	"github.com/astralservices/api/permissions"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CommandFormData struct {
+	ID           *string                        `json:"id,omitempty" form:"id"`
+	Options      map[string]any                 `json:"options,omitempty"`
+	Enabled      *bool                          `json:"enabled,omitempty" form:"enabled"`
+	Cooldown     *int                           `json:"cooldown,omitempty" form:"cooldown"`
+	Restrictions *utils.IBotCommandRestrictions `json:"restrictions,omitempty"`
+	Custom       *bool                          `json:"custom,omitempty" form:"custom"`
+	Description  *string                        `json:"description,omitempty" form:"description"`
+	Response     *string                        `json:"response,omitempty" form:"response"`
+}
+
+// apply copies the submitted fields onto a command
+func (f CommandFormData) apply(command utils.IBotCommand) utils.IBotCommand {
+	if f.Options != nil {
+		command.Options = f.Options
+	}
+
+	if f.Enabled != nil {
+		command.Enabled = *f.Enabled
+	}
+
+	if f.Cooldown != nil {
+		command.Cooldown = *f.Cooldown
+	}
+
+	if f.Restrictions != nil {
+		command.Restrictions = *f.Restrictions
+	}
+
+	if f.Description != nil {
+		command.Description = *f.Description
+	}
+
+	if f.Response != nil {
+		command.Response = *f.Response
+	}
+
+	return command
+}
+
+type CommandDetails struct {
+	utils.IBotCommand
+	// the registry entry of built-in commands
+	Command *commands.Command `json:"command,omitempty"`
+	// whether the bot has configured the command or it uses its defaults
+	Configured bool `json:"configured"`
+}
+
+func details(bot utils.IBot, command utils.IBotCommand) CommandDetails {
+	d := CommandDetails{IBotCommand: command, Configured: commands.Find(bot.Commands, command.ID) >= 0}
+
+	if builtin, ok := commands.Registry[command.ID]; ok && !command.Custom {
+		d.Command = &builtin
+	}
+
+	return d
+}
+
+func commandResponse(ctx *fiber.Ctx, bot utils.IBot, command utils.IBotCommand) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[CommandDetails]{
+		Result: details(bot, command),
+		Code:   http.StatusOK,
+	})
+}
+
+// lists every built-in command with the bot's configuration, followed by the bot's custom commands
+func GetCommands(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	list := []CommandDetails{}
+
+	for _, builtin := range commands.Catalog() {
+		command, _ := commands.Resolve(bot.Commands, builtin.Name)
+		list = append(list, details(bot, command))
+	}
+
+	custom := []CommandDetails{}
+
+	for _, command := range bot.Commands {
+		if command.Custom {
+			custom = append(custom, details(bot, command))
+		}
+	}
+
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].ID < custom[j].ID
+	})
+
+	return ctx.Status(200).JSON(utils.Response[[]CommandDetails]{
+		Result: append(list, custom...),
+		Code:   http.StatusOK,
+	})
+}
+
+func GetCommand(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	command, ok := commands.Resolve(bot.Commands, ctx.Params("command"))
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 404, errors.New("Command not found"), true)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[CommandDetails]{
+		Result: details(bot, command),
+		Code:   http.StatusOK,
+	})
+}
+
+func save(ctx *fiber.Ctx, bot utils.IBot, list []utils.IBotCommand, command utils.IBotCommand, summary string) error {
+	return saveWith(ctx, bot, list, nil, command, summary)
+}
+
+// saveWith saves the commands together with other columns of the bot
+func saveWith(ctx *fiber.Ctx, bot utils.IBot, list []utils.IBotCommand, changes map[string]interface{}, command utils.IBotCommand, summary string) error {
+	if len(list) > commands.MaxCommands {
+		return utils.ErrorResponse(ctx, 400, fmt.Errorf("Bots can have at most %d commands", commands.MaxCommands), true)
+	}
+
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	bot, err := commands.Save(db.New(), workspace, bot, list, changes, *user.ID, fmt.Sprintf(summary, command.ID))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return commandResponse(ctx, bot, command)
+}
+
+// creates a custom command, or configures a built-in command the bot has not configured yet
+func CreateCommand(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var form CommandFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if form.ID == nil {
+		return utils.ErrorResponse(ctx, 400, errors.New("A command name is required"), true)
+	}
+
+	if commands.Find(bot.Commands, *form.ID) >= 0 {
+		return utils.ErrorResponse(ctx, 409, errors.New("This command already exists"), true)
+	}
+
+	command := utils.IBotCommand{ID: *form.ID, Enabled: true, Custom: form.Custom != nil && *form.Custom}
+
+	command, err = commands.Validate(form.apply(command))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	return save(ctx, bot, append(bot.Commands, command), command, "Added the %s command")
+}
+
+func UpdateCommand(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	command, ok := commands.Resolve(bot.Commands, ctx.Params("command"))
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 404, errors.New("Command not found"), true)
+	}
+
+	var form CommandFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	command, err = commands.Validate(form.apply(command))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	list := append([]utils.IBotCommand{}, bot.Commands...)
+
+	if i := commands.Find(list, command.ID); i >= 0 {
+		list[i] = command
+	} else {
+		list = append(list, command)
+	}
+
+	return save(ctx, bot, list, command, "Updated the %s command")
+}
+
+func SetCommandEnabled(enabled bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		bot := ctx.Locals("bot").(utils.IBot)
+
+		command, ok := commands.Resolve(bot.Commands, ctx.Params("command"))
+
+		if !ok {
+			return utils.ErrorResponse(ctx, 404, errors.New("Command not found"), true)
+		}
+
+		command.Enabled = enabled
+
+		// built-in commands with required options cannot be enabled before they are configured
+		if enabled {
+			var err error
+
+			if command, err = commands.Validate(command); err != nil {
+				return utils.ErrorResponse(ctx, 400, err, true)
+			}
+		}
+
+		list := append([]utils.IBotCommand{}, bot.Commands...)
+
+		if i := commands.Find(list, command.ID); i >= 0 {
+			list[i] = command
+		} else {
+			list = append(list, command)
+		}
+
+		if enabled {
+			return save(ctx, bot, list, command, "Enabled the %s command")
+		}
+
+		return save(ctx, bot, list, command, "Disabled the %s command")
+	}
+}
+
+// deletes a custom command, or resets a built-in command to its defaults
+func DeleteCommand(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	i := commands.Find(bot.Commands, ctx.Params("command"))
+
+	if i < 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Command not found"), true)
+	}
+
+	command := bot.Commands[i]
+
+	list := append(append([]utils.IBotCommand{}, bot.Commands[:i]...), bot.Commands[i+1:]...)
+
+	// rules for a deleted custom command cannot match anything any more
+	if command.Custom {
+		if pruned, removed := permissions.Prune(bot.Permissions, "custom."+command.ID); removed {
+			return saveWith(ctx, bot, list, map[string]interface{}{"permissions": pruned}, command, "Removed the %s command")
+		}
+	}
+
+	return save(ctx, bot, list, command, "Removed the %s command")
+}
@@ -0,0 +1,13 @@
+package config
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ConfigHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", ExportConfig)
+	members.Post("/", utils.NoImpersonationMiddleware, ImportConfig)
+}
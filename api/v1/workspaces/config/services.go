@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/astralservices/api/api/v1/workspaces/checks"
+	"github.com/astralservices/api/botconfig"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nedpals/supabase-go"
+)
+
+type ImportResult struct {
+	// whether the document was only checked, without being applied
+	DryRun  bool                      `json:"dryRun"`
+	Changes []utils.IBotVersionChange `json:"changes"`
+	Bot     *utils.IBot               `json:"bot,omitempty"`
+}
+
+func ExportConfig(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	format, err := botconfig.ParseFormat(ctx.Query("format", string(botconfig.FormatJSON)))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	document, err := botconfig.Export(db.New(), workspace, bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	data, err := botconfig.Marshal(document, format)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	ctx.Set("Content-Type", format.ContentType())
+	ctx.Set("Content-Disposition", fmt.Sprintf("attachment; filename=bot-%s.%s", *workspace.ID, format))
+
+	return ctx.Status(200).Send(data)
+}
+
+// validate checks a document the way the bot's settings and commands are checked when they are saved
+// one at a time, normalising its commands
+func validate(database *supabase.Client, user utils.IProvider, workspace utils.IWorkspace, bot utils.IBot, document *botconfig.Document, enabled map[string]utils.IWorkspaceIntegration) error {
+	config := utils.IBotVersionConfig{
+		Region:      document.Region,
+		Settings:    document.Settings,
+		Permissions: document.Permissions,
+		Commands:    document.Commands,
+	}
+
+	if err := checks.Config(database, user, workspace, bot, &config); err != nil {
+		return err
+	}
+
+	document.Settings, document.Commands = config.Settings, config.Commands
+
+	for id := range document.Integrations {
+		if _, ok := enabled[id]; !ok {
+			return fmt.Errorf("the %s integration must be enabled before its settings can be imported", id)
+		}
+	}
+
+	return nil
+}
+
+// imports a configuration document, the format is taken from the format query or the content type.
+// Documents are always checked and diffed against the current config first, dryRun=true stops there.
+func ImportConfig(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	value := ctx.Query("format")
+
+	if value == "" {
+		value = string(botconfig.FormatJSON)
+
+		if strings.Contains(string(ctx.Request().Header.ContentType()), "yaml") {
+			value = string(botconfig.FormatYAML)
+		}
+	}
+
+	format, err := botconfig.ParseFormat(value)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	body := ctx.Body()
+
+	// HTML forms send the document as a field
+	if field := ctx.FormValue("config"); field != "" {
+		body = []byte(field)
+	}
+
+	if len(body) == 0 {
+		return utils.ErrorResponse(ctx, 400, errors.New("A configuration document is required"), true)
+	}
+
+	document, err := botconfig.Parse(body, format)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	database := db.New()
+
+	enabled, err := botconfig.EnabledIntegrations(database, *workspace.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if err = validate(database, user, workspace, bot, &document, enabled); err != nil {
+		status, manual := checks.Status(err)
+
+		return utils.ErrorResponse(ctx, status, err, manual)
+	}
+
+	current, err := botconfig.Export(database, workspace, bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	// integrations the document leaves out are not changed, so they are left out of the diff too
+	for id := range current.Integrations {
+		if _, ok := document.Integrations[id]; !ok {
+			document.Integrations[id] = current.Integrations[id]
+		}
+	}
+
+	changes, err := botconfig.DiffDocuments(current, document)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	result := ImportResult{DryRun: ctx.Query("dryRun") == "true", Changes: changes}
+
+	if !result.DryRun && len(changes) > 0 {
+		bot, err = botconfig.Import(database, workspace, bot, document, *user.ID)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		result.Bot = &bot
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[ImportResult]{
+		Result: result,
+		Code:   http.StatusOK,
+	})
+}
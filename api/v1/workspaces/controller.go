@@ -1,6 +1,13 @@
 package workspaces
 
 import (
+	"github.com/astralservices/api/api/v1/workspaces/announcements"
+	"github.com/astralservices/api/api/v1/workspaces/commands"
+	"github.com/astralservices/api/api/v1/workspaces/config"
+	"github.com/astralservices/api/api/v1/workspaces/moderation"
+	"github.com/astralservices/api/api/v1/workspaces/modules"
+	"github.com/astralservices/api/api/v1/workspaces/reactionroles"
+	"github.com/astralservices/api/api/v1/workspaces/versions"
 	"github.com/astralservices/api/utils"
 	"github.com/gofiber/fiber/v2"
 )
@@ -8,13 +15,14 @@ import (
 func WorkspacesHandler(router fiber.Router) {
 	authed := router.Use(utils.AuthMiddleware, utils.ProfileMiddleware)
 	authed.Get("/", GetWorkspaces)
-	authed.Post("/", CreateWorkspace)
+	authed.Post("/", utils.NoImpersonationMiddleware, CreateWorkspace)
 
 	workspaceRouter := authed.Group("/:workspace_id").Use(utils.WorkspaceMiddleware)
 
 	workspaceRouter.Get("/", GetWorkspace)
-	workspaceRouter.Put("/", UpdateWorkspace)
-	workspaceRouter.Post("/", UpdateWorkspace)
+	// updates always set the plan, so they count as billing changes
+	workspaceRouter.Put("/", utils.NoImpersonationMiddleware, UpdateWorkspace)
+	workspaceRouter.Post("/", utils.NoImpersonationMiddleware, UpdateWorkspace)
 	// workspaceRouter.Delete("/:id", DeleteWorkspace)
 
 	memberRouter := workspaceRouter.Group("/members").Use(utils.WorkspaceMemberMiddleware)
@@ -22,8 +30,8 @@ func WorkspacesHandler(router fiber.Router) {
 	memberRouter.Post("/", AddWorkspaceMember)
 	memberRouter.Get("/:member", GetWorkspaceMember)
 	memberRouter.Put("/:member", UpdateWorkspaceMember)
-	memberRouter.Delete("/:member", RemoveWorkspaceMember)
-	memberRouter.Post("/:member/remove", RemoveWorkspaceMember) // Fallback for HTML Forms
+	memberRouter.Delete("/:member", utils.NoImpersonationMiddleware, RemoveWorkspaceMember)
+	memberRouter.Post("/:member/remove", utils.NoImpersonationMiddleware, RemoveWorkspaceMember) // Fallback for HTML Forms
 
 	// compatablity with HTML forms
 	workspaceRouter.Post("/bot/create", CreateWorkspaceBot)
@@ -31,8 +39,24 @@ func WorkspacesHandler(router fiber.Router) {
 	botRouter := workspaceRouter.Group("/bot").Use(utils.BotMiddleware)
 	botRouter.Get("/", GetWorkspaceBot)
 	botRouter.Post("/", UpdateWorkspaceBot)
+	botRouter.Post("/permissions/evaluate", utils.WorkspaceMemberMiddleware, EvaluateBotPermissions)
+	botRouter.Get("/permissions/lint", utils.WorkspaceMemberMiddleware, LintBotPermissions)
+	botRouter.Post("/permissions/lint", utils.WorkspaceMemberMiddleware, LintBotPermissions)
+	botRouter.Post("/automod/test", utils.WorkspaceMemberMiddleware, TestAutomod)
+	botRouter.Post("/welcome/preview", utils.WorkspaceMemberMiddleware, PreviewWelcome)
 
-	workspaceRouter.Get("/analytics", GetWorkspaceAnalytics)
+	announcements.AnnouncementsHandler(botRouter.Group("/announcements"))
+	commands.CommandsHandler(botRouter.Group("/commands"))
+	config.ConfigHandler(botRouter.Group("/config"))
+	moderation.ModerationHandler(botRouter.Group("/moderation"))
+	modules.ModulesHandler(botRouter.Group("/modules"))
+	moderation.AppealsHandler(botRouter.Group("/appeals"))
+	reactionroles.ReactionRolesHandler(botRouter.Group("/reactionroles"))
+	versions.VersionsHandler(botRouter.Group("/versions"))
+
+	workspaceRouter.Get("/analytics", utils.WorkspaceMemberMiddleware, GetWorkspaceAnalytics)
+	workspaceRouter.Get("/analytics/export", utils.WorkspaceMemberMiddleware, ExportWorkspaceAnalytics)
+	workspaceRouter.Get("/analytics/retention", utils.WorkspaceMemberMiddleware, GetWorkspaceAnalyticsRetention)
 
 	workspaceRouter.Get("/integrations", GetWorkspaceIntegrations)
 
@@ -0,0 +1,35 @@
+package moderation
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ModerationHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	// registered before the action routes so "/cases" is not taken as an action ID
+	members.Get("/cases", GetCases)
+	members.Post("/cases", CreateCase)
+	members.Get("/cases/:case", GetCase)
+	members.Put("/cases/:case", UpdateCase)
+	members.Post("/cases/:case", UpdateCase) // Fallback for HTML Forms
+	members.Post("/cases/:case/actions", LinkCaseAction)
+	members.Post("/cases/:case/notes", AddCaseNote)
+	members.Post("/cases/:case/evidence", AddCaseEvidence)
+	members.Post("/cases/:case/appeals/:appeal/approve", ApproveAppeal)
+	members.Post("/cases/:case/appeals/:appeal/deny", DenyAppeal)
+
+	members.Get("/", GetModerationActions)
+	members.Post("/", CreateModerationAction)
+	members.Get("/:action", GetModerationAction)
+	members.Get("/:action/log", GetModerationActionLog)
+	members.Delete("/:action", utils.NoImpersonationMiddleware, RevokeModerationAction)
+	members.Post("/:action/revoke", utils.NoImpersonationMiddleware, RevokeModerationAction) // Fallback for HTML Forms
+}
+
+// appeals are filed by the moderated user, who is usually not a member of the workspace
+func AppealsHandler(router fiber.Router) {
+	router.Get("/", GetOwnCases)
+	router.Post("/", FileAppeal)
+}
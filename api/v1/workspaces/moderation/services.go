@@ -0,0 +1,703 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/moderation"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nedpals/supabase-go"
+)
+
+func GetModerationActions(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	limit, err := strconv.Atoi(ctx.Query("limit", "50"))
+
+	if err != nil || limit < 1 || limit > 200 {
+		return utils.ErrorResponse(ctx, 400, errors.New("limit must be between 1 and 200"), true)
+	}
+
+	offset, err := strconv.Atoi(ctx.Query("offset", "0"))
+
+	if err != nil || offset < 0 {
+		return utils.ErrorResponse(ctx, 400, errors.New("offset must not be negative"), true)
+	}
+
+	database := db.New()
+
+	query := database.DB.From("moderation_actions").Select("*").LimitWithOffset(limit, offset).Eq("bot", *bot.ID)
+
+	for _, filter := range []string{"user", "action", "moderator", "revoked", "expired"} {
+		if value := ctx.Query(filter); value != "" {
+			query.Eq(filter, value)
+		}
+	}
+
+	if value := ctx.Query("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 400, errors.New("from must be an RFC 3339 timestamp"), true)
+		}
+
+		query.Gte("created_at", from.UTC().Format(time.RFC3339))
+	}
+
+	if value := ctx.Query("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 400, errors.New("to must be an RFC 3339 timestamp"), true)
+		}
+
+		query.Lt("created_at", to.UTC().Format(time.RFC3339))
+	}
+
+	// newest first
+	query.Filter("order", "created_at", "desc")
+
+	var actions []utils.IBotModerationAction
+
+	err = query.Execute(&actions)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBotModerationAction]{
+		Result: actions,
+		Code:   http.StatusOK,
+	})
+}
+
+func CreateModerationAction(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	data := struct {
+		User     string `json:"user" form:"user"`
+		Action   string `json:"action" form:"action"`
+		Reason   string `json:"reason" form:"reason"`
+		Guild    string `json:"guild" form:"guild"`
+		Duration int    `json:"duration" form:"duration"`
+		Expiry   string `json:"expiry" form:"expiry"`
+	}{}
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	now := time.Now().UTC()
+
+	action := utils.IBotModerationAction{
+		Bot:       *bot.ID,
+		Guild:     data.Guild,
+		Action:    data.Action,
+		Moderator: user.ProviderID,
+		Reason:    data.Reason,
+		User:      data.User,
+	}
+
+	if action.Guild == "" {
+		action.Guild = bot.Settings.Guild
+	}
+
+	// temporary actions take either a duration in seconds or an absolute expiry
+	if data.Duration > 0 {
+		action.Expires = true
+		action.Expiry = now.Add(time.Duration(data.Duration) * time.Second)
+	} else if data.Expiry != "" {
+		action.Expires = true
+
+		if action.Expiry, err = time.Parse(time.RFC3339, data.Expiry); err != nil {
+			return utils.ErrorResponse(ctx, 400, errors.New("expiry must be an RFC 3339 timestamp"), true)
+		}
+	}
+
+	if err = moderation.Validate(action, now); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	action, err = moderation.Create(db.New(), action)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBotModerationAction]{
+		Result: action,
+		Code:   http.StatusOK,
+	})
+}
+
+func GetModerationAction(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	action, err := moderation.Get(db.New(), *bot.ID, ctx.Params("action"))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if action == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("Moderation action not found"), true)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBotModerationAction]{
+		Result: *action,
+		Code:   http.StatusOK,
+	})
+}
+
+// returns how posting the action to the bot's moderation log channel went
+func GetModerationActionLog(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	database := db.New()
+
+	action, err := moderation.Get(database, *bot.ID, ctx.Params("action"))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if action == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("Moderation action not found"), true)
+	}
+
+	deliveries, err := moderation.Deliveries(database, action.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IModlogDelivery]{
+		Result: deliveries,
+		Code:   http.StatusOK,
+	})
+}
+
+func RevokeModerationAction(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	database := db.New()
+
+	action, err := moderation.Get(database, *bot.ID, ctx.Params("action"))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if action == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("Moderation action not found"), true)
+	}
+
+	data := struct {
+		Reason string `json:"reason" form:"reason"`
+	}{}
+
+	// the reason is optional, and DELETE requests usually have no body
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&data); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	revoked, err := moderation.Revoke(database, *action, user.ProviderID, data.Reason)
+
+	if errors.Is(err, moderation.ErrRevoked) {
+		return utils.ErrorResponse(ctx, 409, err, true)
+	}
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBotModerationAction]{
+		Result: revoked,
+		Code:   http.StatusOK,
+	})
+}
+
+// the largest evidence attachment that can be uploaded
+const maxEvidenceSize = 8 * 1024 * 1024
+
+var evidenceTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"video/mp4":       true,
+	"text/plain":      true,
+	"application/pdf": true,
+}
+
+func getCase(ctx *fiber.Ctx, database *supabase.Client) (*utils.IModerationCase, error) {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	c, err := moderation.GetCase(database, *bot.ID, ctx.Params("case"))
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if c == nil {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("Case not found"), true)
+	}
+
+	return c, nil
+}
+
+func caseResponse(ctx *fiber.Ctx, c utils.IModerationCase) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IModerationCase]{
+		Result: c,
+		Code:   http.StatusOK,
+	})
+}
+
+func GetCases(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	database := db.New()
+
+	query := database.DB.From("moderation_cases").Select("*").Eq("bot", *bot.ID)
+
+	for _, filter := range []string{"status", "assignee", "user"} {
+		if value := ctx.Query(filter); value != "" {
+			query.Eq(filter, value)
+		}
+	}
+
+	query.Filter("order", "created_at", "desc")
+
+	var cases []utils.IModerationCase
+
+	err := query.Execute(&cases)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IModerationCase]{
+		Result: cases,
+		Code:   http.StatusOK,
+	})
+}
+
+func CreateCase(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	data := struct {
+		Title    string   `json:"title" form:"title"`
+		User     string   `json:"user" form:"user"`
+		Assignee string   `json:"assignee" form:"assignee"`
+		Actions  []string `json:"actions" form:"actions"`
+	}{}
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if data.Title == "" || data.User == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("A title and user are required"), true)
+	}
+
+	database := db.New()
+
+	for _, actionId := range data.Actions {
+		action, err := moderation.Get(database, *bot.ID, actionId)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		if action == nil {
+			return utils.ErrorResponse(ctx, 404, fmt.Errorf("Moderation action %s not found", actionId), true)
+		}
+	}
+
+	c := utils.IModerationCase{
+		Bot:     *bot.ID,
+		Title:   data.Title,
+		User:    data.User,
+		Actions: data.Actions,
+	}
+
+	if data.Assignee != "" {
+		c.Assignee = &data.Assignee
+	}
+
+	c, err = moderation.CreateCase(database, c, user.ProviderID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return caseResponse(ctx, c)
+}
+
+func GetCase(ctx *fiber.Ctx) error {
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	details, err := moderation.GetCaseDetails(database, *c)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[moderation.CaseDetails]{
+		Result: details,
+		Code:   http.StatusOK,
+	})
+}
+
+func UpdateCase(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	data := struct {
+		Title    *string `json:"title" form:"title"`
+		Status   *string `json:"status" form:"status"`
+		Assignee *string `json:"assignee" form:"assignee"`
+	}{}
+
+	err = ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	changes := map[string]interface{}{}
+
+	if data.Title != nil && *data.Title != c.Title {
+		changes["title"] = *data.Title
+	}
+
+	if data.Status != nil && *data.Status != c.Status {
+		if !moderation.CaseStatuses[*data.Status] {
+			return utils.ErrorResponse(ctx, 400, errors.New("Status must be one of open, under_review or closed"), true)
+		}
+
+		changes["status"] = *data.Status
+	}
+
+	if data.Assignee != nil && (c.Assignee == nil || *data.Assignee != *c.Assignee) {
+		if *data.Assignee == "" {
+			changes["assignee"] = nil
+		} else {
+			changes["assignee"] = *data.Assignee
+		}
+	}
+
+	if len(changes) == 0 {
+		return caseResponse(ctx, *c)
+	}
+
+	updated, err := moderation.UpdateCase(database, *c, changes, "updated", user.ProviderID, changes)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return caseResponse(ctx, updated)
+}
+
+func LinkCaseAction(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	data := struct {
+		Action string `json:"action" form:"action"`
+	}{}
+
+	err = ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	updated, err := moderation.LinkAction(database, *c, data.Action, user.ProviderID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	return caseResponse(ctx, updated)
+}
+
+func AddCaseNote(ctx *fiber.Ctx) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	data := struct {
+		Content string `json:"content" form:"content"`
+	}{}
+
+	err = ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	updated, err := moderation.AddNote(database, *c, user.ProviderID, data.Content)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	return caseResponse(ctx, updated)
+}
+
+func AddCaseEvidence(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	contentType := fileHeader.Header.Get("Content-Type")
+
+	if !evidenceTypes[contentType] {
+		return utils.ErrorResponse(ctx, 400, fmt.Errorf("Evidence of type %s is not supported", contentType), true)
+	}
+
+	if fileHeader.Size > maxEvidenceSize {
+		return utils.ErrorResponse(ctx, 400, errors.New("Evidence must be smaller than 8MB"), true)
+	}
+
+	file, err := fileHeader.Open()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("cases/%s/%d-%s", *c.ID, now.UnixNano(), url.PathEscape(filepath.Base(fileHeader.Filename)))
+
+	publicPath, err := utils.UploadWorkspaceFile(*workspace.ID, name, contentType, data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	updated, err := moderation.AddEvidence(database, *c, utils.IModerationCaseEvidence{
+		Name:        fileHeader.Filename,
+		URL:         publicPath,
+		ContentType: contentType,
+		UploadedBy:  user.ProviderID,
+		CreatedAt:   now,
+	})
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return caseResponse(ctx, updated)
+}
+
+func reviewAppeal(ctx *fiber.Ctx, approve bool) error {
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	database := db.New()
+
+	c, err := getCase(ctx, database)
+
+	if c == nil {
+		return err
+	}
+
+	appeal, err := moderation.GetAppeal(database, *c.ID, ctx.Params("appeal"))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if appeal == nil {
+		return utils.ErrorResponse(ctx, 404, errors.New("Appeal not found"), true)
+	}
+
+	if appeal.Status != moderation.AppealPending {
+		return utils.ErrorResponse(ctx, 409, fmt.Errorf("This appeal has already been %s", appeal.Status), true)
+	}
+
+	data := struct {
+		Response string `json:"response" form:"response"`
+	}{}
+
+	if len(ctx.Body()) > 0 {
+		if err = ctx.BodyParser(&data); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	reviewed, err := moderation.ReviewAppeal(database, *c, *appeal, approve, user.ProviderID, data.Response)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IModerationAppeal]{
+		Result: reviewed,
+		Code:   http.StatusOK,
+	})
+}
+
+func ApproveAppeal(ctx *fiber.Ctx) error {
+	return reviewAppeal(ctx, true)
+}
+
+func DenyAppeal(ctx *fiber.Ctx) error {
+	return reviewAppeal(ctx, false)
+}
+
+// lists the cases against the authenticated user, without moderator notes
+func GetOwnCases(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	database := db.New()
+
+	var cases []utils.IModerationCase
+
+	err := database.DB.From("moderation_cases").Select("id, created_at, bot, user, title, status, actions").Eq("bot", *bot.ID).Eq("user", user.ProviderID).Execute(&cases)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IModerationCase]{
+		Result: cases,
+		Code:   http.StatusOK,
+	})
+}
+
+func FileAppeal(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	data := struct {
+		Case      string `json:"case" form:"case"`
+		Statement string `json:"statement" form:"statement"`
+		Redirect  string `json:"redirect" form:"redirect"`
+	}{}
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	redirect := data.Redirect
+
+	database := db.New()
+
+	c, err := moderation.GetCase(database, *bot.ID, data.Case)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if c == nil || c.User != user.ProviderID {
+		return utils.ErrorResponse(ctx, 404, errors.New("Case not found"), true)
+	}
+
+	appeal, err := moderation.FileAppeal(database, *c, user.ProviderID, data.Statement)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IModerationAppeal]{
+		Result: appeal,
+		Code:   http.StatusOK,
+	})
+}
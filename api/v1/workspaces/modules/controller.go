@@ -0,0 +1,15 @@
+package modules
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ModulesHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", GetModules)
+	members.Get("/:module", GetModule)
+	members.Put("/:module", UpdateModule)
+	members.Post("/:module", UpdateModule) // Fallback for HTML Forms
+}
@@ -0,0 +1,124 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/astralservices/api/botconfig"
+	"github.com/astralservices/api/modules"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ModuleFormData struct {
+	Enabled *bool          `json:"enabled,omitempty" form:"enabled"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type ModuleDetails struct {
+	utils.IBotModule[map[string]any]
+	Module modules.Module `json:"module"`
+	// whether the workspace's plan allows the module
+	Available bool `json:"available"`
+}
+
+func details(workspace utils.IWorkspace, bot utils.IBot, module modules.Module) ModuleDetails {
+	settings, _ := modules.Resolve(bot.Settings.Modules, module.ID)
+
+	return ModuleDetails{IBotModule: settings, Module: module, Available: module.Available(workspace)}
+}
+
+func GetModules(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	list := []ModuleDetails{}
+
+	for _, module := range modules.Catalog() {
+		list = append(list, details(workspace, bot, module))
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]ModuleDetails]{
+		Result: list,
+		Code:   http.StatusOK,
+	})
+}
+
+func GetModule(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	module, ok := modules.Registry[ctx.Params("module")]
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 404, errors.New("Module not found"), true)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[ModuleDetails]{
+		Result: details(workspace, bot, module),
+		Code:   http.StatusOK,
+	})
+}
+
+// enables, disables or configures a module, options replace the module's options and are checked against its schema
+func UpdateModule(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	module, ok := modules.Registry[ctx.Params("module")]
+
+	if !ok {
+		return utils.ErrorResponse(ctx, 404, errors.New("Module not found"), true)
+	}
+
+	var form ModuleFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	settings, _ := modules.Resolve(bot.Settings.Modules, module.ID)
+
+	if form.Enabled != nil {
+		settings.Enabled = *form.Enabled
+	}
+
+	if form.Options != nil {
+		if settings.Options, err = module.Validate(form.Options); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	if settings.Enabled && !module.Available(workspace) {
+		return utils.ErrorResponse(ctx, 403, fmt.Errorf("The %s module needs the %s plan", module.Name, module.Plan), true)
+	}
+
+	// the bot in locals is the "before" side of the version diff, so its modules are copied rather than changed
+	botSettings := bot.Settings
+	botSettings.Modules = modules.Normalize(bot.Settings.Modules)
+	botSettings.Modules[module.ID] = settings
+
+	bot, err = botconfig.Save(db.New(), workspace, bot, map[string]interface{}{
+		"settings": botSettings,
+	}, *user.ID, fmt.Sprintf("Updated the %s module", module.Name))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[ModuleDetails]{
+		Result: details(workspace, bot, module),
+		Code:   http.StatusOK,
+	})
+}
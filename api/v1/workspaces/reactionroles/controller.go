@@ -0,0 +1,18 @@
+package reactionroles
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ReactionRolesHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", GetMenus)
+	members.Post("/", CreateMenu)
+	members.Get("/:menu", GetMenu)
+	members.Put("/:menu", UpdateMenu)
+	members.Post("/:menu", UpdateMenu) // Fallback for HTML Forms
+	members.Delete("/:menu", utils.NoImpersonationMiddleware, DeleteMenu)
+	members.Post("/:menu/delete", utils.NoImpersonationMiddleware, DeleteMenu) // Fallback for HTML Forms
+}
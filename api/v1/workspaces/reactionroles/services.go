@@ -0,0 +1,241 @@
+package reactionroles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/astralservices/api/botconfig"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/reactionroles"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+type MenuFormData struct {
+	ID      *string               `json:"id,omitempty" form:"id"`
+	Channel *string               `json:"channel,omitempty" form:"channel"`
+	Content *string               `json:"content,omitempty" form:"content"`
+	Type    *string               `json:"type,omitempty" form:"type"`
+	Mode    *string               `json:"mode,omitempty" form:"mode"`
+	Entries []reactionroles.Entry `json:"entries,omitempty"`
+}
+
+// apply copies the submitted fields onto a menu, entries replace the menu's entries
+func (f MenuFormData) apply(menu reactionroles.Menu) reactionroles.Menu {
+	if f.Channel != nil {
+		menu.Channel = *f.Channel
+	}
+
+	if f.Content != nil {
+		menu.Content = *f.Content
+	}
+
+	if f.Type != nil {
+		menu.Type = *f.Type
+	}
+
+	if f.Mode != nil {
+		menu.Mode = *f.Mode
+	}
+
+	if f.Entries != nil {
+		menu.Entries = f.Entries
+	}
+
+	return menu
+}
+
+func resolve(bot utils.IBot) (reactionroles.Config, error) {
+	settings, _ := modules.Resolve(bot.Settings.Modules, "reactionroles")
+
+	config, err := reactionroles.ParseConfig(settings.Options)
+
+	if config.Menus == nil {
+		config.Menus = []reactionroles.Menu{}
+	}
+
+	return config, err
+}
+
+func menuResponse(ctx *fiber.Ctx, menu reactionroles.Menu) error {
+	redirect := ctx.FormValue("redirect")
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[reactionroles.Menu]{
+		Result: menu,
+		Code:   http.StatusOK,
+	})
+}
+
+// save replaces the bot's menus, checking them against the module's schema and Discord's limits
+func save(ctx *fiber.Ctx, bot utils.IBot, menus []reactionroles.Menu, menu reactionroles.Menu, summary string) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	module := modules.Registry["reactionroles"]
+	settings, _ := modules.Resolve(bot.Settings.Modules, module.ID)
+
+	options, err := reactionroles.Config{Menus: menus}.Options()
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if settings.Options, err = module.Validate(options); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	// the bot in locals is the "before" side of the version diff, so its modules are copied rather than changed
+	botSettings := bot.Settings
+	botSettings.Modules = modules.Normalize(bot.Settings.Modules)
+	botSettings.Modules[module.ID] = settings
+
+	_, err = botconfig.Save(db.New(), workspace, bot, map[string]interface{}{
+		"settings": botSettings,
+	}, *user.ID, fmt.Sprintf(summary, menu.ID))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	// the saved menu has the schema's defaults filled in, removed menus are returned as they were
+	if saved, err := reactionroles.ParseConfig(settings.Options); err == nil {
+		if i := saved.Find(menu.ID); i >= 0 {
+			menu = saved.Menus[i]
+		}
+	}
+
+	return menuResponse(ctx, menu)
+}
+
+func GetMenus(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	config, err := resolve(bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]reactionroles.Menu]{
+		Result: config.Menus,
+		Code:   http.StatusOK,
+	})
+}
+
+func GetMenu(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	config, err := resolve(bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	i := config.Find(ctx.Params("menu"))
+
+	if i < 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Menu not found"), true)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[reactionroles.Menu]{
+		Result: config.Menus[i],
+		Code:   http.StatusOK,
+	})
+}
+
+func CreateMenu(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var form MenuFormData
+
+	err := ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if form.ID == nil {
+		return utils.ErrorResponse(ctx, 400, errors.New("A menu ID is required"), true)
+	}
+
+	config, err := resolve(bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if config.Find(*form.ID) >= 0 {
+		return utils.ErrorResponse(ctx, 409, errors.New("This menu already exists"), true)
+	}
+
+	menu := form.apply(reactionroles.Menu{ID: *form.ID, Mode: reactionroles.ModeToggle})
+
+	if err = reactionroles.Validate(menu); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	return save(ctx, bot, append(config.Menus, menu), menu, "Added the %s reaction role menu")
+}
+
+func UpdateMenu(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	config, err := resolve(bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	i := config.Find(ctx.Params("menu"))
+
+	if i < 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Menu not found"), true)
+	}
+
+	var form MenuFormData
+
+	err = ctx.BodyParser(&form)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	menu := form.apply(config.Menus[i])
+
+	if err = reactionroles.Validate(menu); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	menus := append([]reactionroles.Menu{}, config.Menus...)
+	menus[i] = menu
+
+	return save(ctx, bot, menus, menu, "Updated the %s reaction role menu")
+}
+
+func DeleteMenu(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	config, err := resolve(bot)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	i := config.Find(ctx.Params("menu"))
+
+	if i < 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Menu not found"), true)
+	}
+
+	menu := config.Menus[i]
+
+	menus := append(append([]reactionroles.Menu{}, config.Menus[:i]...), config.Menus[i+1:]...)
+
+	return save(ctx, bot, menus, menu, "Removed the %s reaction role menu")
+}
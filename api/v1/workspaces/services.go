@@ -1,6 +1,7 @@
 package workspaces
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,15 +9,24 @@ import (
 	"image/png"
 	"net/http"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
-
+	"time"
+
+	"github.com/astralservices/api/analytics"
+	"github.com/astralservices/api/api/v1/workspaces/checks"
+	"github.com/astralservices/api/automod"
+	"github.com/astralservices/api/botconfig"
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/permissions"
 	db "github.com/astralservices/api/supabase"
 	"github.com/astralservices/api/utils"
+	"github.com/astralservices/api/welcome"
 	"github.com/gofiber/fiber/v2"
 	"github.com/nfnt/resize"
 	"github.com/nqd/flat"
+	log "github.com/sirupsen/logrus"
 	"github.com/stripe/stripe-go/v72"
 	"github.com/stripe/stripe-go/v72/sub"
 )
@@ -511,11 +521,29 @@ func RemoveWorkspaceMember(ctx *fiber.Ctx) error {
 func GetWorkspaceAnalytics(ctx *fiber.Ctx) error {
 	workspace := ctx.Locals("workspace").(utils.IWorkspace)
 
+	now := time.Now().UTC()
+
+	query, err := analytics.ParseQuery(ctx.Query, now)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	plan, err := utils.GetWorkspacePlan(workspace)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if err = query.CheckRetention(analytics.PolicyForPlan(plan), now); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
 	database := db.New()
 
 	var bots []utils.IBot
 
-	err := database.DB.From("bots").Select("id").Eq("workspace", *workspace.ID).Execute(&bots)
+	err = database.DB.From("bots").Select("id").Eq("workspace", *workspace.ID).Execute(&bots)
 
 	if err != nil {
 		return utils.ErrorResponse(ctx, 500, err, false)
@@ -530,21 +558,146 @@ func GetWorkspaceAnalytics(ctx *fiber.Ctx) error {
 
 	bot := bots[0]
 
-	var analytics []utils.IBotAnalytics
+	rollups, err := analytics.GetRollups(database, *bot.ID, query.Source(), query.Truncate(query.From), query.To)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[analytics.Result]{
+		Result: analytics.Aggregate(query, rollups),
+		Code:   http.StatusOK,
+	})
+}
+
+func ExportWorkspaceAnalytics(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+
+	format, err := analytics.ParseExportFormat(ctx.Query("format", string(analytics.FormatCSV)))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
 
-	err = database.DB.From("bot_analytics").Select("commands, timestamp, members, messages").Eq("bot", *bot.ID).Execute(&analytics)
+	source, err := analytics.ParseSource(ctx.Query("source", string(analytics.SourceRaw)))
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	plan, err := utils.GetWorkspacePlan(workspace)
 
 	if err != nil {
 		return utils.ErrorResponse(ctx, 500, err, false)
 	}
 
-	// sort array by timestamp, latest first
-	sort.Slice(analytics, func(i, j int) bool {
-		return analytics[i].Timestamp.UTC().After(analytics[j].Timestamp.UTC())
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+
+	if plan.AnalyticsRetention > 0 {
+		from = to.AddDate(0, 0, -plan.AnalyticsRetention)
+	}
+
+	retentionStart := from
+
+	if value := ctx.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			return utils.ErrorResponse(ctx, 400, errors.New("from must be an RFC 3339 timestamp"), true)
+		}
+	}
+
+	if value := ctx.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			return utils.ErrorResponse(ctx, 400, errors.New("to must be an RFC 3339 timestamp"), true)
+		}
+	}
+
+	if !from.Before(to) {
+		return utils.ErrorResponse(ctx, 400, errors.New("from must be before to"), true)
+	}
+
+	if plan.AnalyticsRetention > 0 && from.Before(retentionStart) {
+		return utils.ErrorResponse(ctx, 403, fmt.Errorf("the %s plan can only export the last %d days of analytics", plan.Name, plan.AnalyticsRetention), true)
+	}
+
+	database := db.New()
+
+	var bots []utils.IBot
+
+	err = database.DB.From("bots").Select("id").Eq("workspace", *workspace.ID).Execute(&bots)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(bots) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Bot not found"), true)
+	}
+
+	bot := bots[0]
+
+	var commands []string
+
+	// csv needs every command column before the first row is written
+	if format == analytics.FormatCSV {
+		commands, err = analytics.ExportCommands(database, *bot.ID, source, from, to)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 500, err, false)
+		}
+
+		ctx.Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		ctx.Set("Content-Type", "application/x-ndjson")
+	}
+
+	ctx.Set("Content-Disposition", fmt.Sprintf("attachment; filename=analytics-%s.%s", *workspace.ID, format))
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		err := analytics.Export(w, database, *bot.ID, source, format, from, to, commands)
+
+		if err != nil {
+			log.Errorf("analytics export for workspace %s failed: %v", *workspace.ID, err)
+		}
+
+		w.Flush()
 	})
 
-	return ctx.Status(200).JSON(utils.Response[[]utils.IBotAnalytics]{
-		Result: analytics,
+	return nil
+}
+
+// reports what the retention jobs would downsample and delete for the workspace's bot, without changing anything
+func GetWorkspaceAnalyticsRetention(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+
+	plan, err := utils.GetWorkspacePlan(workspace)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	database := db.New()
+
+	var bots []utils.IBot
+
+	err = database.DB.From("bots").Select("id").Eq("workspace", *workspace.ID).Execute(&bots)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(bots) == 0 {
+		return utils.ErrorResponse(ctx, 404, errors.New("Bot not found"), true)
+	}
+
+	report, err := analytics.ApplyRetention(database, *bots[0].ID, analytics.PolicyForPlan(plan), time.Now().UTC(), true)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[analytics.RetentionReport]{
+		Result: report,
 		Code:   http.StatusOK,
 	})
 }
@@ -559,14 +712,17 @@ func GetWorkspaceBot(ctx *fiber.Ctx) error {
 }
 
 type BotSettings struct {
-	Guild               string               `json:"guild,omitempty"`
-	Prefix              string               `json:"prefix,omitempty" form:"prefix,omitempty"`
-	Status              string               `json:"status,omitempty" form:"status,omitempty"`
-	Activities          []utils.IBotActivity `json:"activities,omitempty" form:"activities,omitempty"`
-	RandomizeActivities bool                 `json:"randomizeActivities,omitempty" form:"randomizeActivities,omitempty"`
-	ActivityInterval    int                  `json:"activityInterval,omitempty" form:"activityInterval,omitempty"`
-	CurrentActivity     int                  `json:"currentActivity,omitempty"`
-	Modules             utils.IBotModules    `json:"modules" form:"modules"`
+	Guild               string                       `json:"guild,omitempty"`
+	Prefix              string                       `json:"prefix,omitempty" form:"prefix,omitempty"`
+	Status              string                       `json:"status,omitempty" form:"status,omitempty"`
+	Activities          []utils.IBotActivity         `json:"activities,omitempty" form:"activities,omitempty"`
+	RandomizeActivities bool                         `json:"randomizeActivities,omitempty" form:"randomizeActivities,omitempty"`
+	ActivityInterval    int                          `json:"activityInterval,omitempty" form:"activityInterval,omitempty"`
+	CurrentActivity     int                          `json:"currentActivity,omitempty"`
+	ActivitySchedules   []utils.IBotActivitySchedule `json:"activitySchedules,omitempty"`
+	ActivitySchedule    string                       `json:"activitySchedule,omitempty"`
+	ActivityUpdatedAt   *time.Time                   `json:"activityUpdatedAt,omitempty"`
+	Modules             utils.IBotModules            `json:"modules" form:"modules"`
 }
 
 type BotFormData struct {
@@ -593,7 +749,7 @@ func CreateWorkspaceBot(ctx *fiber.Ctx) error {
 			RandomizeActivities: false,
 			ActivityInterval:    300,
 			CurrentActivity:     0,
-			Modules:             utils.IBotModules{},
+			Modules:             modules.Normalize(nil),
 			Status:              "online",
 		},
 	}
@@ -604,6 +760,20 @@ func CreateWorkspaceBot(ctx *fiber.Ctx) error {
 		return utils.ErrorResponse(ctx, 500, err, false)
 	}
 
+	database := db.New()
+
+	// the same checks as updates, so modules the plan does not include cannot be enabled and guilds the user cannot
+	// manage cannot be picked by creating the bot with them
+	config := utils.IBotVersionConfig{Settings: utils.IBotSettings(*formData.Settings)}
+
+	if err = checks.Config(database, user, workspace, utils.IBot{}, &config); err != nil {
+		status, manual := checks.Status(err)
+
+		return utils.ErrorResponse(ctx, status, err, manual)
+	}
+
+	formData.Settings.Modules = config.Settings.Modules
+
 	// validate the token through Discord's API by fetching the self user
 
 	client := fiber.AcquireClient()
@@ -628,8 +798,6 @@ func CreateWorkspaceBot(ctx *fiber.Ctx) error {
 
 	var bot any
 
-	database := db.New()
-
 	err = database.DB.From("bots").Insert(map[string]interface{}{
 		"workspace": workspace.ID,
 		"region":    formData.Region,
@@ -660,6 +828,10 @@ func UpdateWorkspaceBot(ctx *fiber.Ctx) error {
 
 	redirect := ctx.FormValue("redirect")
 
+	// the bot in locals is the "before" side of the version diff and the permission checks, so its rules are
+	// copied rather than changed
+	rules := permissions.Clone(bot.Permissions)
+
 	form := BotFormData{
 		Settings: &BotSettings{
 			Guild:               bot.Settings.Guild,
@@ -669,14 +841,12 @@ func UpdateWorkspaceBot(ctx *fiber.Ctx) error {
 			RandomizeActivities: bot.Settings.RandomizeActivities,
 			ActivityInterval:    bot.Settings.ActivityInterval,
 			CurrentActivity:     bot.Settings.CurrentActivity,
+			ActivitySchedules:   bot.Settings.ActivitySchedules,
+			ActivitySchedule:    bot.Settings.ActivitySchedule,
+			ActivityUpdatedAt:   bot.Settings.ActivityUpdatedAt,
 			Modules:             bot.Settings.Modules,
 		},
-		Permissions: &utils.IBotPermissions{
-			Roles:             bot.Permissions.Roles,
-			Users:             bot.Permissions.Users,
-			DefaultAdminRules: bot.Permissions.DefaultAdminRules,
-			DefaultUserRules:  bot.Permissions.DefaultUserRules,
-		},
+		Permissions: &rules,
 	}
 
 	err := ctx.BodyParser(&form)
@@ -720,25 +890,34 @@ func UpdateWorkspaceBot(ctx *fiber.Ctx) error {
 		}
 	}
 
-	var bots []any
+	user := ctx.Locals("user").(utils.IProvider)
+	database := db.New()
 
-	var updatedBot any
+	config := utils.IBotVersionConfig{
+		Settings:    utils.IBotSettings(*form.Settings),
+		Permissions: *form.Permissions,
+		Commands:    bot.Commands,
+	}
 
-	database := db.New()
+	if err = checks.Config(database, user, workspace, bot, &config); err != nil {
+		status, manual := checks.Status(err)
+
+		return utils.ErrorResponse(ctx, status, err, manual)
+	}
+
+	form.Settings.Modules = config.Settings.Modules
 
-	err = database.DB.From("bots").Update(BotFormData{
+	updatedBot, err := botconfig.Save(database, workspace, bot, BotFormData{
 		Region:      form.Region,
 		Settings:    form.Settings,
 		Token:       form.Token,
 		Permissions: form.Permissions,
-	}).Eq("workspace", *workspace.ID).Execute(&bots)
+	}, *user.ID, "Updated the bot's settings")
 
 	if err != nil {
 		return utils.ErrorResponse(ctx, 500, err, false)
 	}
 
-	updatedBot = bots[0]
-
 	if redirect != "" {
 		return ctx.Redirect(redirect)
 	}
@@ -749,6 +928,155 @@ func UpdateWorkspaceBot(ctx *fiber.Ctx) error {
 	})
 }
 
+type AutomodTestData struct {
+	automod.Message
+	// options to test instead of the saved ones, so rules can be tried before they are saved
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// dry-runs a message against the bot's automod rules, using the same evaluator as the runners
+func TestAutomod(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var data AutomodTestData
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	settings, _ := modules.Resolve(bot.Settings.Modules, "automod")
+	options := settings.Options
+
+	if data.Options != nil {
+		if options, err = modules.Registry["automod"].Validate(data.Options); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	config, err := automod.ParseConfig(options)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[automod.Result]{
+		Result: automod.Evaluate(config, data.Message),
+		Code:   http.StatusOK,
+	})
+}
+
+type WelcomePreviewData struct {
+	Event string `json:"event" form:"event"`
+	// options to preview instead of the saved ones, so messages can be tried before they are saved
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type WelcomePreview struct {
+	Event   string                   `json:"event"`
+	Channel string                   `json:"channel,omitempty"`
+	DM      bool                     `json:"dm"`
+	Message discordapi.MessageCreate `json:"message"`
+	Errors  []welcome.TemplateError  `json:"errors"`
+}
+
+// renders a welcome or leave message with sample data, template errors are returned rather than failing the request
+func PreviewWelcome(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var data WelcomePreviewData
+
+	err := ctx.BodyParser(&data)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if data.Event == "" {
+		data.Event = welcome.EventJoin
+	}
+
+	settings, _ := modules.Resolve(bot.Settings.Modules, "welcome")
+	options := settings.Options
+
+	// only the schema is applied, template errors are what the preview is for
+	if data.Options != nil {
+		validated, err := modules.Registry["welcome"].Schema.Validate("", data.Options)
+
+		if err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+
+		options = validated.(map[string]any)
+	}
+
+	config, err := welcome.ParseConfig(options)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	message, err := config.Message(data.Event)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	rendered, errs := welcome.Render(message, welcome.SampleVariables)
+
+	return ctx.Status(200).JSON(utils.Response[WelcomePreview]{
+		Result: WelcomePreview{
+			Event:   data.Event,
+			Channel: message.Channel,
+			DM:      message.DM,
+			Message: rendered,
+			Errors:  errs,
+		},
+		Code: http.StatusOK,
+	})
+}
+
+// evaluates what a member of the bot's guild may do, for the dashboard and for runners that cannot import the evaluator
+func EvaluateBotPermissions(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	var request permissions.Request
+
+	err := ctx.BodyParser(&request)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	if err = request.Validate(); err != nil {
+		return utils.ErrorResponse(ctx, 400, err, true)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[permissions.Decision]{
+		Result: permissions.Evaluate(bot.Permissions, request),
+		Code:   http.StatusOK,
+	})
+}
+
+// reports problems with the bot's saved rules, or with the rules in the body if there are any
+func LintBotPermissions(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	rules := bot.Permissions
+
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&rules); err != nil {
+			return utils.ErrorResponse(ctx, 400, err, true)
+		}
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]permissions.Issue]{
+		Result: permissions.Lint(rules, permissions.KnownNodes(bot.Commands)),
+		Code:   http.StatusOK,
+	})
+}
+
 func DeleteWorkspaceBot(ctx *fiber.Ctx) error {
 	workspace := ctx.Locals("workspace").(utils.IWorkspace)
 
@@ -0,0 +1,16 @@
+package versions
+
+import (
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+func VersionsHandler(router fiber.Router) {
+	members := router.Use(utils.WorkspaceMemberMiddleware)
+
+	members.Get("/", GetVersions)
+	// registered before "/:version" so "diff" is not taken as a version number
+	members.Get("/diff", DiffVersions)
+	members.Get("/:version", GetVersion)
+	members.Post("/:version/restore", utils.NoImpersonationMiddleware, RestoreVersion)
+}
@@ -0,0 +1,148 @@
+package versions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/astralservices/api/api/v1/workspaces/checks"
+	"github.com/astralservices/api/botconfig"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nedpals/supabase-go"
+)
+
+type VersionDiff struct {
+	From    string                    `json:"from"`
+	To      string                    `json:"to"`
+	Changes []utils.IBotVersionChange `json:"changes"`
+}
+
+func GetVersions(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	versions, err := botconfig.List(db.New(), *bot.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[[]utils.IBotVersion]{
+		Result: versions,
+		Code:   http.StatusOK,
+	})
+}
+
+// version looks up a version of the bot by its number, returning a 404 response when it does not exist
+func version(ctx *fiber.Ctx, database *supabase.Client, bot utils.IBot, number string) (*utils.IBotVersion, error) {
+	if _, err := strconv.Atoi(number); err != nil {
+		return nil, utils.ErrorResponse(ctx, 400, errors.New("Versions must be numbers"), true)
+	}
+
+	v, err := botconfig.Get(database, *bot.ID, number)
+
+	if err != nil {
+		return nil, utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if v == nil {
+		return nil, utils.ErrorResponse(ctx, 404, errors.New("Version not found"), true)
+	}
+
+	return v, nil
+}
+
+func GetVersion(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	v, err := version(ctx, db.New(), bot, ctx.Params("version"))
+
+	if v == nil {
+		return err
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBotVersion]{
+		Result: *v,
+		Code:   http.StatusOK,
+	})
+}
+
+// diffs two versions of the bot, "current" stands for the bot's config as it is now
+func DiffVersions(ctx *fiber.Ctx) error {
+	bot := ctx.Locals("bot").(utils.IBot)
+
+	from, to := ctx.Query("from"), ctx.Query("to", "current")
+
+	if from == "" {
+		return utils.ErrorResponse(ctx, 400, errors.New("from is required"), true)
+	}
+
+	database := db.New()
+
+	configs := make([]botconfig.Config, 2)
+
+	for i, number := range []string{from, to} {
+		if number == "current" {
+			configs[i] = botconfig.Snapshot(bot)
+			continue
+		}
+
+		v, err := version(ctx, database, bot, number)
+
+		if v == nil {
+			return err
+		}
+
+		configs[i] = v.Config
+	}
+
+	changes, err := botconfig.Diff(configs[0], configs[1])
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[VersionDiff]{
+		Result: VersionDiff{From: from, To: to, Changes: changes},
+		Code:   http.StatusOK,
+	})
+}
+
+func RestoreVersion(ctx *fiber.Ctx) error {
+	workspace := ctx.Locals("workspace").(utils.IWorkspace)
+	bot := ctx.Locals("bot").(utils.IBot)
+	user := ctx.Locals("user").(utils.IProvider)
+
+	redirect := ctx.FormValue("redirect")
+
+	database := db.New()
+
+	v, err := version(ctx, database, bot, ctx.Params("version"))
+
+	if v == nil {
+		return err
+	}
+
+	// the config is saved again, so it has to pass today's checks
+	if err = checks.Config(database, user, workspace, bot, &v.Config); err != nil {
+		status, manual := checks.Status(err)
+
+		return utils.ErrorResponse(ctx, status, err, manual)
+	}
+
+	bot, err = botconfig.Restore(database, workspace, bot, *v, *user.ID)
+
+	if err != nil {
+		return utils.ErrorResponse(ctx, 500, err, false)
+	}
+
+	if redirect != "" {
+		return ctx.Redirect(redirect)
+	}
+
+	return ctx.Status(200).JSON(utils.Response[utils.IBot]{
+		Result: bot,
+		Code:   http.StatusOK,
+	})
+}
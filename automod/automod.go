@@ -0,0 +1,297 @@
+package automod
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	RuleSpam     = "spam"
+	RuleMentions = "mentions"
+	RuleInvites  = "invites"
+	RuleLinks    = "links"
+	RuleCaps     = "caps"
+	RuleWords    = "words"
+)
+
+// the actions a rule can take, from the least to the most severe
+var Actions = []string{"delete", "warn", "timeout", "kick", "ban"}
+
+type Rule struct {
+	Enabled bool     `json:"enabled"`
+	Actions []string `json:"actions"`
+	// seconds a timeout lasts
+	TimeoutDuration int `json:"timeoutDuration"`
+}
+
+type SpamRule struct {
+	Rule
+	MaxMessages int `json:"maxMessages"`
+	PerSeconds  int `json:"perSeconds"`
+}
+
+type MentionsRule struct {
+	Rule
+	Max int `json:"max"`
+}
+
+type InvitesRule struct {
+	Rule
+	// invite codes that are always allowed, such as the server's own
+	Allowed []string `json:"allowed"`
+}
+
+type LinksRule struct {
+	Rule
+	// allow only permits the listed domains, deny blocks them
+	Mode    string   `json:"mode"`
+	Domains []string `json:"domains"`
+}
+
+type CapsRule struct {
+	Rule
+	// messages with fewer letters are never checked
+	MinLength int `json:"minLength"`
+	Percent   int `json:"percent"`
+}
+
+type WordsRule struct {
+	Rule
+	Words []string `json:"words"`
+}
+
+// Config is the options of the automod module
+type Config struct {
+	Spam           SpamRule     `json:"spam"`
+	Mentions       MentionsRule `json:"mentions"`
+	Invites        InvitesRule  `json:"invites"`
+	Links          LinksRule    `json:"links"`
+	Caps           CapsRule     `json:"caps"`
+	Words          WordsRule    `json:"words"`
+	ExemptRoles    []string     `json:"exemptRoles"`
+	ExemptChannels []string     `json:"exemptChannels"`
+}
+
+// ParseConfig reads the automod module's options, which have already been checked against its schema
+func ParseConfig(options map[string]any) (Config, error) {
+	var config Config
+
+	data, err := json.Marshal(options)
+
+	if err != nil {
+		return config, err
+	}
+
+	return config, json.Unmarshal(data, &config)
+}
+
+// Message is a message event as runners see it
+type Message struct {
+	Content string   `json:"content"`
+	Author  string   `json:"author"`
+	Channel string   `json:"channel"`
+	Roles   []string `json:"roles"`
+	// messages the author sent within the spam window before this one, see Tracker
+	Recent int `json:"recent"`
+}
+
+type Match struct {
+	Rule    string   `json:"rule"`
+	Reason  string   `json:"reason"`
+	Actions []string `json:"actions"`
+	// seconds a timeout lasts, when the rule times the author out
+	TimeoutDuration int `json:"timeoutDuration,omitempty"`
+}
+
+type Result struct {
+	// whether the author or channel is exempt, exempt messages never match
+	Exempt  bool    `json:"exempt"`
+	Matches []Match `json:"matches"`
+	// every action of the matching rules, from the least to the most severe
+	Actions []string `json:"actions"`
+}
+
+var (
+	mentionPattern = regexp.MustCompile(`<@[!&]?\d+>|@everyone|@here`)
+	invitePattern  = regexp.MustCompile(`(?i)(?:discord\.gg|discord(?:app)?\.com/invite)/([a-z0-9-]+)`)
+	linkPattern    = regexp.MustCompile(`(?i)https?://([^/\s:?#]+)`)
+)
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func match(rule Rule, name string, reason string) Match {
+	actions := rule.Actions
+
+	if actions == nil {
+		actions = []string{}
+	}
+
+	m := Match{Rule: name, Reason: reason, Actions: actions}
+
+	if contains(actions, "timeout") {
+		m.TimeoutDuration = rule.TimeoutDuration
+	}
+
+	return m
+}
+
+// domainListed reports whether a domain or one of its parents is in a list
+func domainListed(domains []string, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	for _, listed := range domains {
+		listed = strings.ToLower(listed)
+
+		if domain == listed || strings.HasSuffix(domain, "."+listed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evaluate checks a message against every enabled rule
+func Evaluate(config Config, message Message) Result {
+	result := Result{Matches: []Match{}, Actions: []string{}}
+
+	if contains(config.ExemptChannels, message.Channel) {
+		result.Exempt = true
+		return result
+	}
+
+	for _, role := range message.Roles {
+		if contains(config.ExemptRoles, role) {
+			result.Exempt = true
+			return result
+		}
+	}
+
+	if r := config.Spam; r.Enabled && r.MaxMessages > 0 && message.Recent+1 > r.MaxMessages {
+		result.Matches = append(result.Matches, match(r.Rule, RuleSpam, fmt.Sprintf("more than %d messages in %d seconds", r.MaxMessages, r.PerSeconds)))
+	}
+
+	if r := config.Mentions; r.Enabled {
+		mentions := map[string]bool{}
+
+		for _, mention := range mentionPattern.FindAllString(message.Content, -1) {
+			mentions[strings.Replace(mention, "<@!", "<@", 1)] = true
+		}
+
+		if len(mentions) > r.Max {
+			result.Matches = append(result.Matches, match(r.Rule, RuleMentions, fmt.Sprintf("%d mentions, at most %d are allowed", len(mentions), r.Max)))
+		}
+	}
+
+	if r := config.Invites; r.Enabled {
+		for _, invite := range invitePattern.FindAllStringSubmatch(message.Content, -1) {
+			if !contains(r.Allowed, invite[1]) {
+				result.Matches = append(result.Matches, match(r.Rule, RuleInvites, "invite to "+invite[1]))
+				break
+			}
+		}
+	}
+
+	if r := config.Links; r.Enabled {
+		for _, link := range linkPattern.FindAllStringSubmatch(message.Content, -1) {
+			listed := domainListed(r.Domains, link[1])
+
+			if (r.Mode == "allow" && !listed) || (r.Mode != "allow" && listed) {
+				result.Matches = append(result.Matches, match(r.Rule, RuleLinks, "link to "+link[1]))
+				break
+			}
+		}
+	}
+
+	if r := config.Caps; r.Enabled {
+		letters, upper := 0, 0
+
+		for _, c := range message.Content {
+			if unicode.IsLetter(c) {
+				letters++
+
+				if unicode.IsUpper(c) {
+					upper++
+				}
+			}
+		}
+
+		if letters > 0 && letters >= r.MinLength && upper*100 >= r.Percent*letters {
+			result.Matches = append(result.Matches, match(r.Rule, RuleCaps, fmt.Sprintf("%d%% capital letters", upper*100/letters)))
+		}
+	}
+
+	if r := config.Words; r.Enabled {
+		if word, ok := FindWord(r.Words, message.Content); ok {
+			result.Matches = append(result.Matches, match(r.Rule, RuleWords, "banned word "+word))
+		}
+	}
+
+	actions := map[string]bool{}
+
+	for _, m := range result.Matches {
+		for _, action := range m.Actions {
+			actions[action] = true
+		}
+	}
+
+	for _, action := range Actions {
+		if actions[action] {
+			result.Actions = append(result.Actions, action)
+		}
+	}
+
+	return result
+}
+
+// Tracker counts the recent messages of authors for the spam rule, runners keep one per guild. Authors without
+// messages in the window are dropped once per window, so authors who stop talking are not kept forever.
+type Tracker struct {
+	mu       sync.Mutex
+	messages map[string][]time.Time
+	swept    time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{messages: map[string][]time.Time{}}
+}
+
+// Record adds a message and returns how many messages the author sent within the window before it
+func (t *Tracker) Record(author string, at time.Time, window time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.Sub(t.swept) >= window {
+		for other, sent := range t.messages {
+			if at.Sub(sent[len(sent)-1]) >= window {
+				delete(t.messages, other)
+			}
+		}
+
+		t.swept = at
+	}
+
+	recent := t.messages[author][:0]
+
+	for _, sent := range t.messages[author] {
+		if at.Sub(sent) < window {
+			recent = append(recent, sent)
+		}
+	}
+
+	t.messages[author] = append(recent, at)
+
+	return len(recent)
+}
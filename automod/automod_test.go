@@ -0,0 +1,216 @@
+package automod
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	config := Config{
+		Spam:     SpamRule{Rule: Rule{Enabled: true, Actions: []string{"ban", "delete"}}, MaxMessages: 5, PerSeconds: 10},
+		Mentions: MentionsRule{Rule: Rule{Enabled: true, Actions: []string{"delete"}}, Max: 2},
+		Invites:  InvitesRule{Rule: Rule{Enabled: true, Actions: []string{"delete"}}, Allowed: []string{"astral"}},
+		Links:    LinksRule{Rule: Rule{Enabled: true, Actions: []string{"delete"}}, Mode: "deny", Domains: []string{"example.com"}},
+		Caps:     CapsRule{Rule: Rule{Enabled: true, Actions: []string{"warn"}}, MinLength: 5, Percent: 70},
+		Words: WordsRule{
+			Rule:  Rule{Enabled: true, Actions: []string{"timeout"}, TimeoutDuration: 60},
+			Words: []string{"badword", "spoil*"},
+		},
+		ExemptRoles:    []string{"mods"},
+		ExemptChannels: []string{"staff"},
+	}
+
+	allow := config
+	allow.Links.Mode = "allow"
+
+	disabled := config
+	disabled.Caps.Enabled = false
+
+	tests := []struct {
+		name    string
+		config  Config
+		message Message
+		exempt  bool
+		// the rules that match, in the order they are checked
+		rules   []string
+		actions []string
+	}{
+		{
+			name:    "clean message",
+			config:  config,
+			message: Message{Content: "hello there"},
+		},
+		{
+			name:    "exempt channel",
+			config:  config,
+			message: Message{Content: "BADWORD", Channel: "staff"},
+			exempt:  true,
+		},
+		{
+			name:    "exempt role",
+			config:  config,
+			message: Message{Content: "BADWORD", Roles: []string{"members", "mods"}},
+			exempt:  true,
+		},
+		{
+			name:    "spam at the limit",
+			config:  config,
+			message: Message{Content: "hi", Recent: 4},
+		},
+		{
+			name:    "spam over the limit",
+			config:  config,
+			message: Message{Content: "hi", Recent: 5},
+			rules:   []string{RuleSpam},
+			actions: []string{"delete", "ban"},
+		},
+		{
+			name:    "repeated mentions count once",
+			config:  config,
+			message: Message{Content: "<@1> <@!1> <@2>"},
+		},
+		{
+			name:    "too many mentions",
+			config:  config,
+			message: Message{Content: "<@1> <@2> @everyone"},
+			rules:   []string{RuleMentions},
+			actions: []string{"delete"},
+		},
+		{
+			name:    "allowed invite",
+			config:  config,
+			message: Message{Content: "join discord.gg/astral"},
+		},
+		{
+			name:    "invite",
+			config:  config,
+			message: Message{Content: "join discord.com/invite/other"},
+			rules:   []string{RuleInvites},
+			actions: []string{"delete"},
+		},
+		{
+			name:    "denied subdomain",
+			config:  config,
+			message: Message{Content: "see https://www.example.com/page"},
+			rules:   []string{RuleLinks},
+			actions: []string{"delete"},
+		},
+		{
+			name:    "domain that only ends like a denied one",
+			config:  config,
+			message: Message{Content: "see https://notexample.com"},
+		},
+		{
+			name:    "domain outside the allowed ones",
+			config:  allow,
+			message: Message{Content: "see https://other.org"},
+			rules:   []string{RuleLinks},
+			actions: []string{"delete"},
+		},
+		{
+			name:    "caps",
+			config:  config,
+			message: Message{Content: "STOP THAT now"},
+			rules:   []string{RuleCaps},
+			actions: []string{"warn"},
+		},
+		{
+			name:    "caps shorter than the minimum",
+			config:  config,
+			message: Message{Content: "OK!"},
+		},
+		{
+			name:    "disabled rules never match",
+			config:  disabled,
+			message: Message{Content: "STOP THAT NOW"},
+		},
+		{
+			name:    "disguised word",
+			config:  config,
+			message: Message{Content: "what a b4dw0rd"},
+			rules:   []string{RuleWords},
+			actions: []string{"timeout"},
+		},
+		{
+			name:    "prefix word",
+			config:  config,
+			message: Message{Content: "no spoilers"},
+			rules:   []string{RuleWords},
+			actions: []string{"timeout"},
+		},
+		{
+			name:    "actions of every match, least severe first",
+			config:  config,
+			message: Message{Content: "BADWORD AT HTTPS://EXAMPLE.COM", Recent: 9},
+			rules:   []string{RuleSpam, RuleLinks, RuleCaps, RuleWords},
+			actions: []string{"delete", "warn", "timeout", "ban"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Evaluate(test.config, test.message)
+
+			if result.Exempt != test.exempt {
+				t.Errorf("exempt = %v, want %v", result.Exempt, test.exempt)
+			}
+
+			rules := []string{}
+
+			for _, m := range result.Matches {
+				rules = append(rules, m.Rule)
+
+				if wantTimeout := m.Rule == RuleWords; (m.TimeoutDuration == 60) != wantTimeout {
+					t.Errorf("%s timeout = %d", m.Rule, m.TimeoutDuration)
+				}
+			}
+
+			if test.rules == nil {
+				test.rules = []string{}
+			}
+
+			if test.actions == nil {
+				test.actions = []string{}
+			}
+
+			if !reflect.DeepEqual(rules, test.rules) {
+				t.Errorf("rules = %v, want %v", rules, test.rules)
+			}
+
+			if !reflect.DeepEqual(result.Actions, test.actions) {
+				t.Errorf("actions = %v, want %v", result.Actions, test.actions)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 10 * time.Second
+
+	for i, want := range []int{0, 1, 2} {
+		if got := tracker.Record("a", start.Add(time.Duration(i)*time.Second), window); got != want {
+			t.Errorf("message %d: recent = %d, want %d", i+1, got, want)
+		}
+	}
+
+	// the first two messages are out of the window
+	if got := tracker.Record("a", start.Add(11*time.Second), window); got != 1 {
+		t.Errorf("recent = %d, want 1", got)
+	}
+
+	tracker.Record("b", start.Add(12*time.Second), window)
+
+	// a has no messages in the window any more and is dropped, b is kept
+	tracker.Record("c", start.Add(21*time.Second), window)
+
+	if _, ok := tracker.messages["a"]; ok {
+		t.Error("a is still tracked")
+	}
+
+	if _, ok := tracker.messages["b"]; !ok {
+		t.Error("b is not tracked")
+	}
+}
@@ -0,0 +1,152 @@
+package automod
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// characters commonly swapped for letters to get around word filters
+var lookalikes = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'!': 'i',
+	'|': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'+': 't',
+	'8': 'b',
+}
+
+// Normalize reduces text to lowercase letters and digits separated by single spaces, so that "Wörd" and
+// "W0RD" compare equal: accents are dropped and lookalike characters are replaced
+func Normalize(text string) string {
+	return normalize(text, true)
+}
+
+// normalize is Normalize, replacing lookalike symbols such as ! and $ only when symbols is true. They are
+// punctuation just as often, so messages are checked both ways.
+func normalize(text string, symbols bool) string {
+	var out strings.Builder
+
+	space := false
+
+	for _, c := range norm.NFKD.String(strings.ToLower(text)) {
+		if unicode.Is(unicode.Mn, c) {
+			continue
+		}
+
+		if replacement, ok := lookalikes[c]; ok && (symbols || unicode.IsDigit(c)) {
+			c = replacement
+		}
+
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			space = out.Len() > 0
+			continue
+		}
+
+		if space {
+			out.WriteRune(' ')
+			space = false
+		}
+
+		out.WriteRune(c)
+	}
+
+	return out.String()
+}
+
+type run struct {
+	letter rune
+	count  int
+}
+
+func runs(word string) []run {
+	var out []run
+
+	for _, c := range word {
+		if len(out) > 0 && out[len(out)-1].letter == c {
+			out[len(out)-1].count++
+		} else {
+			out = append(out, run{c, 1})
+		}
+	}
+
+	return out
+}
+
+// matches reports whether a word is the target with any of its letters drawn out, so "woooord" matches
+// "word" but "as" does not match "ass". Prefix targets only need to match the start of the word.
+func matches(word string, target string, prefix bool) bool {
+	w, t := runs(word), runs(target)
+
+	if len(w) < len(t) || (!prefix && len(w) != len(t)) {
+		return false
+	}
+
+	for i := range t {
+		if w[i].letter != t[i].letter || w[i].count < t[i].count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tokens splits normalized text into words, runs of single letters such as "w o r d" are joined back up
+func tokens(text string) []string {
+	var words []string
+	var spelled strings.Builder
+
+	flush := func() {
+		if spelled.Len() > 1 {
+			words = append(words, spelled.String())
+		}
+
+		spelled.Reset()
+	}
+
+	for _, word := range strings.Fields(text) {
+		if len([]rune(word)) == 1 {
+			spelled.WriteString(word)
+			continue
+		}
+
+		flush()
+
+		words = append(words, word)
+	}
+
+	flush()
+
+	return words
+}
+
+// FindWord returns the first banned word in a message. Words match whole words after normalization, a
+// trailing * matches any word starting with it.
+func FindWord(banned []string, content string) (string, bool) {
+	// "$hit" needs the symbol replaced, "idiot!" needs it dropped
+	words := append(tokens(normalize(content, true)), tokens(normalize(content, false))...)
+
+	for _, entry := range banned {
+		prefix := strings.HasSuffix(entry, "*")
+		target := Normalize(strings.TrimSuffix(entry, "*"))
+
+		if target == "" {
+			continue
+		}
+
+		for _, word := range words {
+			if matches(word, target, prefix) {
+				return entry, true
+			}
+		}
+	}
+
+	return "", false
+}
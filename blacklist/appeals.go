@@ -0,0 +1,192 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	AppealPending       = "pending"
+	AppealInfoRequested = "info_requested"
+	AppealApproved      = "approved"
+	AppealDenied        = "denied"
+)
+
+// GetAppealFor returns the appeal of an entry, every entry can only be appealed once
+func GetAppealFor(database *supabase.Client, entry utils.IBlacklist) (*utils.IBlacklistAppeal, error) {
+	var appeals []utils.IBlacklistAppeal
+
+	err := database.DB.From("blacklist_appeals").Select("*").Eq("blacklist", strconv.FormatInt(entry.ID, 10)).Execute(&appeals)
+
+	if err != nil || len(appeals) == 0 {
+		return nil, err
+	}
+
+	return &appeals[0], nil
+}
+
+func GetAppeal(database *supabase.Client, id string) (*utils.IBlacklistAppeal, error) {
+	var appeals []utils.IBlacklistAppeal
+
+	err := database.DB.From("blacklist_appeals").Select("*").Eq("id", id).Execute(&appeals)
+
+	if err != nil || len(appeals) == 0 {
+		return nil, err
+	}
+
+	return &appeals[0], nil
+}
+
+// ListAppeals returns the appeals with a status, oldest first, so the queue is worked in order
+func ListAppeals(database *supabase.Client, status string) ([]utils.IBlacklistAppeal, error) {
+	var appeals []utils.IBlacklistAppeal
+
+	query := database.DB.From("blacklist_appeals").Select("*")
+
+	if status != "" {
+		query.Eq("status", status)
+	}
+
+	query.Filter("order", "created_at", "asc")
+
+	err := query.Execute(&appeals)
+
+	return appeals, err
+}
+
+func updateAppeal(database *supabase.Client, appeal utils.IBlacklistAppeal, changes map[string]interface{}) (utils.IBlacklistAppeal, error) {
+	var appeals []utils.IBlacklistAppeal
+
+	err := database.DB.From("blacklist_appeals").Update(changes).Eq("id", *appeal.ID).Execute(&appeals)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	if len(appeals) > 0 {
+		appeal = appeals[0]
+	}
+
+	return appeal, nil
+}
+
+// FileAppeal submits the appeal of an entry, or answers the questions of staff if they asked for more information
+func FileAppeal(database *supabase.Client, entry utils.IBlacklist, user string, statement string) (utils.IBlacklistAppeal, error) {
+	appeal := utils.IBlacklistAppeal{Blacklist: entry.ID, User: user, Statement: statement, Status: AppealPending, Messages: []utils.IBlacklistAppealMessage{}}
+
+	if entry.User != user {
+		return appeal, errors.New("only the blacklisted user can appeal this entry")
+	}
+
+	if statement == "" {
+		return appeal, errors.New("a statement is required")
+	}
+
+	existing, err := GetAppealFor(database, entry)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	if existing != nil {
+		if existing.Status != AppealInfoRequested {
+			return *existing, errors.New("this entry has already been appealed")
+		}
+
+		message := utils.IBlacklistAppealMessage{Author: user, Content: statement, CreatedAt: time.Now().UTC()}
+
+		return updateAppeal(database, *existing, map[string]interface{}{
+			"status":   AppealPending,
+			"messages": append(existing.Messages, message),
+		})
+	}
+
+	var appeals []utils.IBlacklistAppeal
+
+	err = database.DB.From("blacklist_appeals").Insert(appeal).Execute(&appeals)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	appeal = appeals[0]
+
+	_, err = Update(database, entry, map[string]interface{}{
+		"notes": AppendNote(entry.Notes, user, "Appeal filed"),
+	})
+
+	return appeal, err
+}
+
+// ValidateReview checks a staff decision before it is applied
+func ValidateReview(appeal utils.IBlacklistAppeal, decision string, response string) error {
+	if appeal.Status != AppealPending {
+		return fmt.Errorf("this appeal is %s, not pending", appeal.Status)
+	}
+
+	switch decision {
+	case AppealApproved, AppealDenied, AppealInfoRequested:
+	default:
+		return fmt.Errorf("unknown decision %q", decision)
+	}
+
+	if decision == AppealInfoRequested && response == "" {
+		return errors.New("a question is required when requesting more information")
+	}
+
+	return nil
+}
+
+// ReviewAppeal records a staff decision on an appeal and on the notes of its entry.
+// Approving an appeal lifts the entry, requesting information sends the appeal back to the user.
+func ReviewAppeal(database *supabase.Client, entry utils.IBlacklist, appeal utils.IBlacklistAppeal, decision string, reviewer string, response string) (utils.IBlacklistAppeal, error) {
+	if err := ValidateReview(appeal, decision, response); err != nil {
+		return appeal, err
+	}
+
+	changes := map[string]interface{}{
+		"status":      decision,
+		"reviewer":    reviewer,
+		"reviewed_at": time.Now().UTC(),
+	}
+
+	if response != "" {
+		changes["messages"] = append(appeal.Messages, utils.IBlacklistAppealMessage{Author: reviewer, Staff: true, Content: response, CreatedAt: time.Now().UTC()})
+	}
+
+	appeal, err := updateAppeal(database, appeal, changes)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	note := "Appeal " + map[string]string{
+		AppealApproved:      "approved",
+		AppealDenied:        "denied",
+		AppealInfoRequested: "needs more information",
+	}[decision]
+
+	if response != "" {
+		note += ": " + response
+	}
+
+	entry.Notes = AppendNote(entry.Notes, reviewer, note)
+
+	if decision == AppealApproved && !entry.Lifted {
+		// lifted meanwhile is as good
+		if _, err = Lift(database, entry, reviewer, "Appeal approved"); errors.Is(err, ErrLifted) {
+			err = nil
+		}
+
+		return appeal, err
+	}
+
+	_, err = Update(database, entry, map[string]interface{}{"notes": entry.Notes})
+
+	return appeal, err
+}
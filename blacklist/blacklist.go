@@ -0,0 +1,95 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+func Get(database *supabase.Client, id string) (*utils.IBlacklist, error) {
+	var entries []utils.IBlacklist
+
+	err := database.DB.From("blacklist").Select("*").Eq("id", id).Execute(&entries)
+
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return &entries[0], nil
+}
+
+// AppendNote adds a dated line to an entry's notes, which double as its decision log
+func AppendNote(notes string, author string, note string) string {
+	line := fmt.Sprintf("[%s] %s: %s", time.Now().UTC().Format(time.RFC3339), author, note)
+
+	if strings.TrimSpace(notes) == "" {
+		return line
+	}
+
+	return notes + "\n" + line
+}
+
+// Update saves changes to an entry
+func Update(database *supabase.Client, entry utils.IBlacklist, changes map[string]interface{}) (utils.IBlacklist, error) {
+	var entries []utils.IBlacklist
+
+	err := database.DB.From("blacklist").Update(changes).Eq("id", strconv.FormatInt(entry.ID, 10)).Execute(&entries)
+
+	if err != nil {
+		return entry, err
+	}
+
+	if len(entries) == 0 {
+		return entry, errors.New("blacklist entry not found")
+	}
+
+	return entries[0], nil
+}
+
+var ErrLifted = errors.New("This entry has already been lifted")
+
+// Lift stops an entry from blocking its user, keeping it for the record. Only one of two lifts of the same entry
+// is saved, the other returns ErrLifted.
+func Lift(database *supabase.Client, entry utils.IBlacklist, by string, reason string) (utils.IBlacklist, error) {
+	if entry.Lifted {
+		return entry, ErrLifted
+	}
+
+	changes := map[string]interface{}{
+		"lifted":    true,
+		"lifted_at": time.Now().UTC(),
+		"lifted_by": by,
+	}
+
+	if reason != "" {
+		changes["notes"] = AppendNote(entry.Notes, by, "Lifted: "+reason)
+	}
+
+	var entries []utils.IBlacklist
+
+	err := database.DB.From("blacklist").Update(changes).Eq("id", strconv.FormatInt(entry.ID, 10)).Eq("lifted", "false").Execute(&entries)
+
+	if err != nil {
+		return entry, err
+	}
+
+	if len(entries) == 0 {
+		return entry, ErrLifted
+	}
+
+	return entries[0], nil
+}
+
+// DueForLift returns the entries whose expiry has passed but are still in place
+func DueForLift(database *supabase.Client, now time.Time) ([]utils.IBlacklist, error) {
+	var entries []utils.IBlacklist
+
+	err := database.DB.From("blacklist").Select("*").Eq("lifted", "false").Eq("expires", "true").Lte("expiry", now.UTC().Format(time.RFC3339)).Execute(&entries)
+
+	return entries, err
+}
@@ -0,0 +1,548 @@
+package blacklist
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+	"github.com/stripe/stripe-go/v72"
+)
+
+const (
+	FactorRoblox   = "roblox"
+	FactorEmail    = "email"
+	FactorPayment  = "payment"
+	FactorUsername = "username"
+)
+
+// how strongly each factor on its own suggests an alternate account
+var Factors = map[string]float64{
+	FactorRoblox:   0.95,
+	FactorPayment:  0.9,
+	FactorEmail:    0.7,
+	FactorUsername: 0.4,
+}
+
+// usernames less similar than this are not considered a match
+const usernameThreshold = 0.8
+
+const (
+	TriggerSignup        = "signup"
+	TriggerProviderLink  = "provider_link"
+	TriggerPaymentMethod = "payment_method"
+)
+
+const (
+	DetectionFlagged = "flagged"
+	DetectionBlocked = "blocked"
+)
+
+const (
+	DetectionPending   = "pending"
+	DetectionConfirmed = "confirmed"
+	DetectionDismissed = "dismissed"
+)
+
+// Subject holds everything an account can be matched on
+type Subject struct {
+	User         string
+	Email        string
+	Username     string
+	RobloxIDs    []string
+	Fingerprints []string
+}
+
+func envScore(key string, fallback float64) float64 {
+	score, err := strconv.ParseFloat(os.Getenv(key), 64)
+
+	if err != nil {
+		return fallback
+	}
+
+	return score
+}
+
+// Thresholds returns the scores above which a match is flagged for review and the account is blocked, 0 disables either
+func Thresholds() (flag float64, block float64) {
+	return envScore("DETECTION_FLAG_SCORE", 0.5), envScore("DETECTION_BLOCK_SCORE", 0.9)
+}
+
+// NormalizeEmail lowercases an address, drops +tags and, for gmail, the dots in the local part
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+
+	if at < 1 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+
+	return local + "@" + domain
+}
+
+func normalizeUsername(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// Similarity returns how alike two usernames are, from 0 to 1, using their edit distance
+func Similarity(a string, b string) float64 {
+	x, y := []rune(normalizeUsername(a)), []rune(normalizeUsername(b))
+
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	longest := len(x)
+
+	if len(y) > longest {
+		longest = len(y)
+	}
+
+	return 1 - float64(previous[len(y)])/float64(longest)
+}
+
+func minimum(values ...int) int {
+	m := values[0]
+
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+func shared(a []string, b []string) string {
+	for _, x := range a {
+		for _, y := range b {
+			if x != "" && x == y {
+				return x
+			}
+		}
+	}
+
+	return ""
+}
+
+// Match compares an account against a blacklisted one on the factors of its entry, every factor of an entry
+// without factors is used. The score combines the factors as independent evidence.
+func Match(subject Subject, blacklisted Subject, factors []string) (float64, []utils.IAltFactorMatch) {
+	if len(factors) == 0 {
+		for factor := range Factors {
+			factors = append(factors, factor)
+		}
+	}
+
+	matches := []utils.IAltFactorMatch{}
+	unlikely := 1.0
+
+	for _, factor := range factors {
+		weight, ok := Factors[factor]
+
+		if !ok {
+			continue
+		}
+
+		match := utils.IAltFactorMatch{Factor: factor, Weight: weight}
+
+		switch factor {
+		case FactorRoblox:
+			if id := shared(subject.RobloxIDs, blacklisted.RobloxIDs); id != "" {
+				match.Similarity = 1
+				match.Explanation = fmt.Sprintf("Both accounts have linked Roblox account %s", id)
+			}
+		case FactorEmail:
+			if subject.Email != "" && NormalizeEmail(subject.Email) == NormalizeEmail(blacklisted.Email) {
+				match.Similarity = 1
+				match.Explanation = fmt.Sprintf("%s and %s are the same address once normalised", subject.Email, blacklisted.Email)
+			}
+		case FactorPayment:
+			if fingerprint := shared(subject.Fingerprints, blacklisted.Fingerprints); fingerprint != "" {
+				match.Similarity = 1
+				match.Explanation = "Both accounts have saved the same card"
+			}
+		case FactorUsername:
+			if similarity := Similarity(subject.Username, blacklisted.Username); similarity >= usernameThreshold {
+				match.Similarity = math.Round(similarity*100) / 100
+				match.Explanation = fmt.Sprintf("%q is %.0f%% similar to %q", subject.Username, similarity*100, blacklisted.Username)
+			}
+		}
+
+		if match.Similarity > 0 {
+			matches = append(matches, match)
+			unlikely *= 1 - match.Weight*match.Similarity
+		}
+	}
+
+	return math.Round((1-unlikely)*100) / 100, matches
+}
+
+// LoadSubject reads the matchable details of a user. Card fingerprints come from payment_fingerprints, which the
+// Stripe webhook fills as cards are saved.
+func LoadSubject(database *supabase.Client, user string) (*Subject, error) {
+	var profiles []utils.IProfile
+
+	err := database.DB.From("profiles").Select("id, email, preferred_name").Eq("id", user).Execute(&profiles)
+
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+
+	subject := Subject{User: user, Email: profiles[0].Email, Username: profiles[0].PreferredName}
+
+	var providers []utils.IProvider
+
+	err = database.DB.From("providers").Select("*").Eq("user", user).Eq("type", "roblox").Execute(&providers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range providers {
+		if provider.ProviderData["status"] == "verified" {
+			subject.RobloxIDs = append(subject.RobloxIDs, provider.ProviderID)
+		}
+	}
+
+	var fingerprints []utils.IPaymentFingerprint
+
+	err = database.DB.From("payment_fingerprints").Select("*").Eq("user", user).Execute(&fingerprints)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fingerprint := range fingerprints {
+		subject.Fingerprints = append(subject.Fingerprints, fingerprint.Fingerprint)
+	}
+
+	return &subject, nil
+}
+
+// candidates returns the active entries the user shares an email, Roblox account or card with, or has a similar
+// username to, leaving out the ones the user was already matched against
+func candidates(database *supabase.Client, user string) ([]utils.IBlacklist, error) {
+	var entries []utils.IBlacklist
+
+	err := utils.Rpc(database, "alt_detection_candidates", map[string]interface{}{
+		"p_user":                user,
+		"p_username_similarity": usernameThreshold,
+	}, &entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []utils.IBlacklist{}
+
+	for _, entry := range entries {
+		if entry.Active(now) {
+			active = append(active, entry)
+		}
+	}
+
+	return active, nil
+}
+
+// Detect compares a user against the active blacklist entries it has something in common with, records the
+// matches above the flag threshold for staff review and blacklists the user if a match is above the block threshold.
+// An account is only matched against each entry once, later triggers only look at new entries.
+func Detect(database *supabase.Client, user string, trigger string) ([]utils.IAltDetection, error) {
+	detections := []utils.IAltDetection{}
+
+	blacklisted, err := utils.GetActiveBlacklist(database, user)
+
+	// the user is already blacklisted, there is nothing left to detect
+	if err != nil || blacklisted != nil {
+		return detections, err
+	}
+
+	entries, err := candidates(database, user)
+
+	if err != nil || len(entries) == 0 {
+		return detections, err
+	}
+
+	subject, err := LoadSubject(database, user)
+
+	if err != nil || subject == nil {
+		return detections, err
+	}
+
+	flag, block := Thresholds()
+
+	for _, entry := range entries {
+		other, err := LoadSubject(database, entry.User)
+
+		if err != nil {
+			return detections, err
+		}
+
+		if other == nil {
+			continue
+		}
+
+		score, matches := Match(*subject, *other, entry.FactorMatching)
+
+		if flag <= 0 || score < flag {
+			continue
+		}
+
+		detection := utils.IAltDetection{
+			User:        user,
+			Blacklist:   entry.ID,
+			MatchedUser: entry.User,
+			Trigger:     trigger,
+			Score:       score,
+			Matches:     matches,
+			Action:      DetectionFlagged,
+			Status:      DetectionPending,
+		}
+
+		if block > 0 && score >= block {
+			detection.Action = DetectionBlocked
+		}
+
+		detections = append(detections, detection)
+	}
+
+	if len(detections) == 0 {
+		return detections, nil
+	}
+
+	// a single entry covers every blocking match, pointing at the strongest one
+	var strongest *utils.IAltDetection
+
+	for i := range detections {
+		if detections[i].Action == DetectionBlocked && (strongest == nil || detections[i].Score > strongest.Score) {
+			strongest = &detections[i]
+		}
+	}
+
+	if strongest != nil {
+		entry, err := blockAlt(database, *strongest)
+
+		if err != nil {
+			return detections, err
+		}
+
+		for i := range detections {
+			if detections[i].Action == DetectionBlocked {
+				detections[i].Entry = &entry.ID
+			}
+		}
+	}
+
+	err = database.DB.From("alt_detections").Insert(detections).Execute(&detections)
+
+	return detections, err
+}
+
+// RecordPaymentMethod stores the fingerprint of a card a customer saved and runs detection for its owner, whose
+// card could not be compared at sign-up. Customers that are not linked to a profile are ignored.
+func RecordPaymentMethod(database *supabase.Client, method *stripe.PaymentMethod) ([]utils.IAltDetection, error) {
+	if method.Card == nil || method.Card.Fingerprint == "" || method.Customer == nil || method.Customer.ID == "" {
+		return nil, nil
+	}
+
+	var profiles []utils.IProfile
+
+	err := database.DB.From("profiles").Select("id").Eq("stripe_customer_id", method.Customer.ID).Execute(&profiles)
+
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+
+	user := profiles[0].ID
+
+	// cards stay recorded after they are detached, removing a card should not clear a match
+	err = database.DB.From("payment_fingerprints").Upsert(utils.IPaymentFingerprint{
+		PaymentMethod: method.ID,
+		User:          user,
+		Fingerprint:   method.Card.Fingerprint,
+	}).Execute(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Detect(database, user, TriggerPaymentMethod)
+}
+
+func blockAlt(database *supabase.Client, detection utils.IAltDetection) (utils.IBlacklist, error) {
+	var entries []utils.IBlacklist
+
+	factors := []string{}
+
+	for _, match := range detection.Matches {
+		factors = append(factors, match.Factor)
+	}
+
+	var profiles []utils.IProfile
+
+	err := database.DB.From("profiles").Select("id, discord_id").Eq("id", detection.User).Execute(&profiles)
+
+	if err != nil || len(profiles) == 0 {
+		return utils.IBlacklist{}, err
+	}
+
+	err = database.DB.From("blacklist").Insert(map[string]interface{}{
+		"user":            detection.User,
+		"discord_id":      profiles[0].DiscordID,
+		"moderator":       "system",
+		"reason":          fmt.Sprintf("Suspected alternate account of a blacklisted user (entry %d)", detection.Blacklist),
+		"expires":         false,
+		"factor_matching": factors,
+		"notes":           AppendNote("", "system", fmt.Sprintf("Blocked by alt detection with a score of %.2f", detection.Score)),
+	}).Execute(&entries)
+
+	if err != nil {
+		return utils.IBlacklist{}, err
+	}
+
+	return entries[0], nil
+}
+
+func GetDetection(database *supabase.Client, id string) (*utils.IAltDetection, error) {
+	var detections []utils.IAltDetection
+
+	err := database.DB.From("alt_detections").Select("*").Eq("id", id).Execute(&detections)
+
+	if err != nil || len(detections) == 0 {
+		return nil, err
+	}
+
+	return &detections[0], nil
+}
+
+// ListDetections returns the detections with a status, strongest first
+func ListDetections(database *supabase.Client, status string) ([]utils.IAltDetection, error) {
+	var detections []utils.IAltDetection
+
+	query := database.DB.From("alt_detections").Select("*")
+
+	if status != "" {
+		query.Eq("status", status)
+	}
+
+	query.Filter("order", "score", "desc")
+
+	err := query.Execute(&detections)
+
+	return detections, err
+}
+
+// ReviewDetection confirms or dismisses a detection. Confirming blacklists the account if it was only flagged,
+// dismissing lifts the entry that was created when it was blocked.
+func ReviewDetection(database *supabase.Client, detection utils.IAltDetection, confirm bool, reviewer string) (utils.IAltDetection, error) {
+	if detection.Status != DetectionPending {
+		return detection, fmt.Errorf("this detection has already been %s", detection.Status)
+	}
+
+	changes := map[string]interface{}{
+		"status":      DetectionDismissed,
+		"reviewer":    reviewer,
+		"reviewed_at": time.Now().UTC(),
+	}
+
+	if confirm {
+		changes["status"] = DetectionConfirmed
+
+		if detection.Entry == nil {
+			entry, err := utils.GetActiveBlacklist(database, detection.User)
+
+			if err != nil {
+				return detection, err
+			}
+
+			if entry == nil {
+				created, err := blockAlt(database, detection)
+
+				if err != nil {
+					return detection, err
+				}
+
+				entry = &created
+			}
+
+			changes["entry"] = entry.ID
+		}
+	} else if detection.Entry != nil {
+		entry, err := Get(database, strconv.FormatInt(*detection.Entry, 10))
+
+		if err != nil {
+			return detection, err
+		}
+
+		if entry != nil && !entry.Lifted {
+			if _, err = Lift(database, *entry, reviewer, "Alt detection dismissed"); err != nil && !errors.Is(err, ErrLifted) {
+				return detection, err
+			}
+		}
+	}
+
+	var detections []utils.IAltDetection
+
+	err := database.DB.From("alt_detections").Update(changes).Eq("id", *detection.ID).Execute(&detections)
+
+	if err != nil {
+		return detection, err
+	}
+
+	if len(detections) > 0 {
+		detection = detections[0]
+	}
+
+	return detection, nil
+}
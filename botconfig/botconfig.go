@@ -0,0 +1,229 @@
+package botconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+	"github.com/nqd/flat"
+)
+
+// Config is the part of a bot that is versioned, the token is left out so it never ends up in the history
+type Config = utils.IBotVersionConfig
+
+type Change = utils.IBotVersionChange
+
+// Snapshot returns the versioned config of a bot. The activity rotation state changes on its own as the
+// presence job runs, so it is left out of snapshots and diffs.
+func Snapshot(bot utils.IBot) Config {
+	settings := bot.Settings
+	settings.CurrentActivity, settings.ActivitySchedule, settings.ActivityUpdatedAt = 0, "", nil
+
+	return Config{
+		Region:      bot.Region,
+		Settings:    settings,
+		Permissions: bot.Permissions,
+		Commands:    bot.Commands,
+	}
+}
+
+func flatten(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var nested map[string]interface{}
+
+	if err = json.Unmarshal(data, &nested); err != nil {
+		return nil, err
+	}
+
+	flattened, err := flat.Flatten(nested, nil)
+
+	// null and empty values are treated alike, so a nil list and an empty one are not a change
+	for path, value := range flattened {
+		if value == nil {
+			delete(flattened, path)
+		}
+	}
+
+	return flattened, err
+}
+
+// Diff lists every value that differs between two configs by its dotted path, sorted by path
+func Diff(from Config, to Config) ([]Change, error) {
+	return diff(from, to)
+}
+
+func diff(from interface{}, to interface{}) ([]Change, error) {
+	a, err := flatten(from)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := flatten(to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+
+	for path, value := range a {
+		if other, ok := b[path]; !ok || !reflect.DeepEqual(value, other) {
+			changes = append(changes, Change{Path: path, From: value, To: b[path]})
+		}
+	}
+
+	for path, value := range b {
+		if _, ok := a[path]; !ok {
+			changes = append(changes, Change{Path: path, To: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// apply returns the bot as it is once changes are saved. Changes replace whole columns, as updates do.
+func apply(bot utils.IBot, changes interface{}) (utils.IBot, error) {
+	var columns, changed map[string]json.RawMessage
+
+	data, err := json.Marshal(bot)
+
+	if err != nil {
+		return bot, err
+	}
+
+	if err = json.Unmarshal(data, &columns); err != nil {
+		return bot, err
+	}
+
+	if data, err = json.Marshal(changes); err != nil {
+		return bot, err
+	}
+
+	if err = json.Unmarshal(data, &changed); err != nil {
+		return bot, err
+	}
+
+	for column, value := range changed {
+		columns[column] = value
+	}
+
+	if data, err = json.Marshal(columns); err != nil {
+		return bot, err
+	}
+
+	var after utils.IBot
+
+	return after, json.Unmarshal(data, &after)
+}
+
+// Save applies changes, anything the bots table accepts as an update, to a bot's row and records the resulting
+// config as a new version, in one transaction. Bots saved before versioning existed get their previous config
+// recorded first, so the very first change can be undone. Changes that leave the config as it was do not create a
+// version. Versions that fall outside the workspace's plan retention are deleted, the latest is always kept.
+func Save(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot, changes interface{}, author string, summary string) (utils.IBot, error) {
+	return save(database, workspace, bot, changes, nil, author, summary)
+}
+
+// save is Save that also replaces the settings of workspace integrations, by integration ID, in the same transaction
+func save(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot, changes interface{}, integrations map[int]interface{}, author string, summary string) (utils.IBot, error) {
+	after, err := apply(bot, changes)
+
+	if err != nil {
+		return bot, err
+	}
+
+	before := Snapshot(bot)
+
+	diff, err := Diff(before, Snapshot(after))
+
+	if err != nil {
+		return bot, err
+	}
+
+	plan, err := utils.GetWorkspacePlan(workspace)
+
+	if err != nil {
+		return bot, err
+	}
+
+	params := map[string]interface{}{
+		"p_bot":          *bot.ID,
+		"p_changes":      changes,
+		"p_initial":      nil,
+		"p_config":       nil,
+		"p_diff":         nil,
+		"p_author":       author,
+		"p_summary":      summary,
+		"p_retention":    plan.VersionRetention,
+		"p_integrations": nil,
+	}
+
+	if len(integrations) > 0 {
+		params["p_integrations"] = integrations
+	}
+
+	if len(diff) > 0 {
+		params["p_initial"], params["p_config"], params["p_diff"] = before, Snapshot(after), diff
+	}
+
+	var bots []utils.IBot
+
+	if err = utils.Rpc(database, "save_bot", params, &bots); err != nil {
+		return bot, err
+	}
+
+	if len(bots) == 0 {
+		return bot, errors.New("bot not found")
+	}
+
+	return bots[0], nil
+}
+
+// List returns a bot's versions newest first, without their configs
+func List(database *supabase.Client, bot string) ([]utils.IBotVersion, error) {
+	var versions []utils.IBotVersion
+
+	query := database.DB.From("bot_versions").Select("id, created_at, bot, version, author, summary, diff").Eq("bot", bot)
+	query.Filter("order", "version", "desc")
+
+	err := query.Execute(&versions)
+
+	return versions, err
+}
+
+func Get(database *supabase.Client, bot string, version string) (*utils.IBotVersion, error) {
+	var versions []utils.IBotVersion
+
+	err := database.DB.From("bot_versions").Select("*").Eq("bot", bot).Eq("version", version).Execute(&versions)
+
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+
+	return &versions[0], nil
+}
+
+// Restore saves the config of an earlier version as a new version, so the restore itself can be undone. Callers
+// check the config first, as it is saved again. Versions hold no activity rotation state, saving keeps the current one.
+func Restore(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot, version utils.IBotVersion, author string) (utils.IBot, error) {
+	return Save(database, workspace, bot, map[string]interface{}{
+		"region":      version.Config.Region,
+		"settings":    version.Config.Settings,
+		"permissions": version.Config.Permissions,
+		"commands":    version.Config.Commands,
+	}, author, fmt.Sprintf("Restored version %d", version.Version))
+}
@@ -0,0 +1,356 @@
+package botconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+	"gopkg.in/yaml.v2"
+)
+
+// the version of the document format, bumped whenever a change would break older documents
+const DocumentVersion = 1
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToLower(value) {
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	}
+
+	return "", errors.New("format must be one of json or yaml")
+}
+
+func (f Format) ContentType() string {
+	if f == FormatYAML {
+		return "application/yaml"
+	}
+
+	return "application/json"
+}
+
+// Document is a bot's configuration as it is exported and imported. The bot's token and any secret
+// integration settings are left out, importing a document keeps their current values.
+type Document struct {
+	Version     int                   `json:"version"`
+	Region      string                `json:"region"`
+	Settings    utils.IBotSettings    `json:"settings"`
+	Permissions utils.IBotPermissions `json:"permissions"`
+	Commands    []utils.IBotCommand   `json:"commands"`
+	// the settings of the workspace's enabled integrations by integration ID
+	Integrations map[string]map[string]interface{} `json:"integrations"`
+}
+
+// setting names that hold credentials, webhook URLs carry their own token
+var secretNames = []string{"token", "secret", "password", "webhook", "apikey"}
+
+// IsSecret reports whether a setting holds a credential by its name
+func IsSecret(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact removes the secret settings of an integration, at any depth
+func Redact(settings interface{}) interface{} {
+	switch v := settings.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+
+		for key, value := range v {
+			if !IsSecret(key) {
+				out[key] = Redact(value)
+			}
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+
+		for i, value := range v {
+			out[i] = Redact(value)
+		}
+
+		return out
+	}
+
+	return settings
+}
+
+// secrets returns the dotted paths of the secret settings in a value
+func secrets(prefix string, settings interface{}) []string {
+	var paths []string
+
+	switch v := settings.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if IsSecret(key) {
+				paths = append(paths, prefix+key)
+			} else {
+				paths = append(paths, secrets(prefix+key+".", value)...)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			paths = append(paths, secrets(prefix+strconv.Itoa(i)+".", value)...)
+		}
+	}
+
+	return paths
+}
+
+// keepSecrets copies the current secret settings of an integration into imported settings
+func keepSecrets(current interface{}, imported map[string]interface{}) map[string]interface{} {
+	existing, _ := current.(map[string]interface{})
+
+	out := make(map[string]interface{}, len(imported))
+
+	for key, value := range imported {
+		out[key] = value
+	}
+
+	for key, value := range existing {
+		if IsSecret(key) {
+			out[key] = value
+			continue
+		}
+
+		if nested, ok := out[key].(map[string]interface{}); ok {
+			out[key] = keepSecrets(value, nested)
+		}
+	}
+
+	return out
+}
+
+// EnabledIntegrations returns the workspace's enabled integrations by integration ID
+func EnabledIntegrations(database *supabase.Client, workspace string) (map[string]utils.IWorkspaceIntegration, error) {
+	var integrations []utils.IWorkspaceIntegration
+
+	err := database.DB.From("workspace_integrations").Select("*").Eq("workspace", workspace).Eq("enabled", "true").Execute(&integrations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := map[string]utils.IWorkspaceIntegration{}
+
+	for _, integration := range integrations {
+		enabled[integration.Integration] = integration
+	}
+
+	return enabled, nil
+}
+
+func Export(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot) (Document, error) {
+	config := Snapshot(bot)
+	config.Settings.Modules = modules.Normalize(config.Settings.Modules)
+
+	document := Document{
+		Version:      DocumentVersion,
+		Region:       config.Region,
+		Settings:     config.Settings,
+		Permissions:  config.Permissions,
+		Commands:     config.Commands,
+		Integrations: map[string]map[string]interface{}{},
+	}
+
+	if document.Commands == nil {
+		document.Commands = []utils.IBotCommand{}
+	}
+
+	integrations, err := EnabledIntegrations(database, *workspace.ID)
+
+	if err != nil {
+		return document, err
+	}
+
+	for id, integration := range integrations {
+		settings, _ := Redact(integration.Settings).(map[string]interface{})
+
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+
+		document.Integrations[id] = settings
+	}
+
+	return document, nil
+}
+
+// generic converts a value to the maps, slices and scalars its JSON encoding decodes to
+func generic(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out interface{}
+
+	return out, json.Unmarshal(data, &out)
+}
+
+// fromYAML converts the maps yaml.v2 decodes to, which are keyed by interface{}, to maps JSON can encode
+func fromYAML(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			name, ok := key.(string)
+
+			if !ok {
+				return nil, fmt.Errorf("keys must be strings, found %v", key)
+			}
+
+			converted, err := fromYAML(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			out[name] = converted
+		}
+
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+
+		for i, item := range v {
+			converted, err := fromYAML(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			out[i] = converted
+		}
+
+		return out, nil
+	}
+
+	return value, nil
+}
+
+func Marshal(document Document, format Format) ([]byte, error) {
+	if format == FormatJSON {
+		return json.MarshalIndent(document, "", "  ")
+	}
+
+	// going through JSON first keeps the field names of the JSON format
+	value, err := generic(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(value)
+}
+
+// Parse reads a document, rejecting unknown fields so typos are not silently dropped
+func Parse(data []byte, format Format) (Document, error) {
+	var document Document
+
+	if format == FormatYAML {
+		var value interface{}
+
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return document, fmt.Errorf("invalid YAML: %w", err)
+		}
+
+		value, err := fromYAML(value)
+
+		if err != nil {
+			return document, err
+		}
+
+		if data, err = json.Marshal(value); err != nil {
+			return document, err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&document); err != nil {
+		return document, fmt.Errorf("invalid document: %w", err)
+	}
+
+	if document.Version != DocumentVersion {
+		return document, fmt.Errorf("unsupported document version %d, expected %d", document.Version, DocumentVersion)
+	}
+
+	if document.Integrations == nil {
+		document.Integrations = map[string]map[string]interface{}{}
+	}
+
+	var paths []string
+
+	for id, settings := range document.Integrations {
+		paths = append(paths, secrets("integrations."+id+".", settings)...)
+	}
+
+	if len(paths) > 0 {
+		sort.Strings(paths)
+
+		return document, fmt.Errorf("%s is a secret and can not be imported, it keeps its current value", paths[0])
+	}
+
+	return document, nil
+}
+
+// DiffDocuments lists every value that differs between two documents by its dotted path
+func DiffDocuments(from Document, to Document) ([]Change, error) {
+	return diff(from, to)
+}
+
+// Import applies a validated document to a bot and the workspace's integrations in one transaction, so a document
+// is applied entirely or not at all. Integrations the document does not mention are left as they are.
+func Import(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot, document Document, author string) (utils.IBot, error) {
+	integrations, err := EnabledIntegrations(database, *workspace.ID)
+
+	if err != nil {
+		return bot, err
+	}
+
+	settings := map[int]interface{}{}
+
+	for id, values := range document.Integrations {
+		integration, ok := integrations[id]
+
+		if !ok {
+			return bot, fmt.Errorf("the %s integration is not enabled", id)
+		}
+
+		settings[integration.ID] = keepSecrets(integration.Settings, values)
+	}
+
+	return save(database, workspace, bot, map[string]interface{}{
+		"region":      document.Region,
+		"settings":    document.Settings,
+		"permissions": document.Permissions,
+		"commands":    document.Commands,
+	}, settings, author, "Imported a configuration document")
+}
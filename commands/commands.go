@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"github.com/astralservices/api/botconfig"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	// Discord's limit for the number of chat input commands of an application
+	MaxCommands = 100
+	// the longest cooldown a command can have, one day
+	MaxCooldown = 86400
+	// Discord's limit for the length of a message
+	MaxResponseLength = 2000
+)
+
+// names follow Discord's rules for chat input commands
+var commandName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// the variables custom command responses can use, with an example of each
+var TemplateVariables = map[string]string{
+	"User":        "Astral#0001",
+	"UserID":      "123456789012345678",
+	"UserMention": "<@123456789012345678>",
+	"Server":      "Astral",
+	"Channel":     "general",
+	"Args":        "the rest of the message",
+}
+
+// ValidateTemplate checks that a custom command response can be rendered
+func ValidateTemplate(response string) error {
+	if response == "" {
+		return errors.New("custom commands need a response")
+	}
+
+	if len(response) > MaxResponseLength {
+		return fmt.Errorf("responses must be at most %d characters", MaxResponseLength)
+	}
+
+	if _, err := template.New("response").Parse(response); err != nil {
+		return fmt.Errorf("invalid response template: %w", err)
+	}
+
+	return nil
+}
+
+func validateIDs(kind string, ids []string) ([]string, error) {
+	if ids == nil {
+		return []string{}, nil
+	}
+
+	for _, id := range ids {
+		if !IsSnowflake(id) {
+			return nil, fmt.Errorf("%q is not a Discord %s ID", id, kind)
+		}
+	}
+
+	return ids, nil
+}
+
+// Validate checks a command before it is saved and normalises its options and restrictions
+func Validate(command utils.IBotCommand) (utils.IBotCommand, error) {
+	if !commandName.MatchString(command.ID) {
+		return command, errors.New("command names must be 1 to 32 lowercase letters, numbers, dashes or underscores")
+	}
+
+	if command.Cooldown < 0 || command.Cooldown > MaxCooldown {
+		return command, fmt.Errorf("cooldown must be between 0 and %d seconds", MaxCooldown)
+	}
+
+	var err error
+	r := &command.Restrictions
+
+	if r.AllowedChannels, err = validateIDs("channel", r.AllowedChannels); err != nil {
+		return command, err
+	}
+
+	if r.DeniedChannels, err = validateIDs("channel", r.DeniedChannels); err != nil {
+		return command, err
+	}
+
+	if r.AllowedRoles, err = validateIDs("role", r.AllowedRoles); err != nil {
+		return command, err
+	}
+
+	if r.DeniedRoles, err = validateIDs("role", r.DeniedRoles); err != nil {
+		return command, err
+	}
+
+	builtin, isBuiltin := Registry[command.ID]
+
+	if command.Custom {
+		if isBuiltin {
+			return command, fmt.Errorf("%s is a built-in command", command.ID)
+		}
+
+		if len(command.Description) > 100 {
+			return command, errors.New("descriptions must be at most 100 characters")
+		}
+
+		command.Options = nil
+
+		return command, ValidateTemplate(command.Response)
+	}
+
+	if !isBuiltin {
+		return command, fmt.Errorf("unknown command %q", command.ID)
+	}
+
+	options, ok := command.Options.(map[string]any)
+
+	if !ok && command.Options != nil {
+		return command, errors.New("options must be an object")
+	}
+
+	if command.Options, err = builtin.ValidateOptions(options); err != nil {
+		return command, err
+	}
+
+	command.Description, command.Response = "", ""
+
+	return command, nil
+}
+
+// Find returns the index of a bot's command, -1 if the bot has not configured it
+func Find(commands []utils.IBotCommand, id string) int {
+	for i, command := range commands {
+		if command.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Resolve returns a bot's command, built-in commands the bot has not configured use their defaults
+func Resolve(commands []utils.IBotCommand, id string) (utils.IBotCommand, bool) {
+	if i := Find(commands, id); i >= 0 {
+		return commands[i], true
+	}
+
+	builtin, ok := Registry[id]
+
+	if !ok {
+		return utils.IBotCommand{}, false
+	}
+
+	// commands with required options stay disabled until they are configured
+	options, err := builtin.ValidateOptions(map[string]any{})
+
+	return utils.IBotCommand{ID: id, Enabled: err == nil, Options: options}, true
+}
+
+// Save replaces the commands of a bot, and the other columns in changes that change with them, recording the change
+// in the bot's version history
+func Save(database *supabase.Client, workspace utils.IWorkspace, bot utils.IBot, commands []utils.IBotCommand, changes map[string]interface{}, author string, summary string) (utils.IBot, error) {
+	if len(commands) > MaxCommands {
+		return bot, fmt.Errorf("bots can have at most %d commands", MaxCommands)
+	}
+
+	update := map[string]interface{}{
+		"commands": commands,
+	}
+
+	for column, value := range changes {
+		update[column] = value
+	}
+
+	return botconfig.Save(database, workspace, bot, update, author, summary)
+}
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	OptionString  = "string"
+	OptionInteger = "integer"
+	OptionNumber  = "number"
+	OptionBoolean = "boolean"
+	OptionChannel = "channel"
+	OptionRole    = "role"
+	OptionUser    = "user"
+)
+
+// Option describes a setting of a built-in command that workspaces can configure
+type Option struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	Required    bool     `json:"required,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	MaxLength   int      `json:"maxLength,omitempty"`
+}
+
+// Command is a built-in command that runners know how to carry out
+type Command struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Module      string   `json:"module"`
+	Permission  string   `json:"permission"`
+	Options     []Option `json:"options"`
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func command(module string, name string, description string, options ...Option) Command {
+	if options == nil {
+		options = []Option{}
+	}
+
+	return Command{
+		Name:        name,
+		Description: description,
+		Module:      module,
+		Permission:  module + "." + name,
+		Options:     options,
+	}
+}
+
+// Registry holds every built-in command by name
+var Registry = map[string]Command{}
+
+func register(commands ...Command) {
+	for _, c := range commands {
+		Registry[c.Name] = c
+	}
+}
+
+func init() {
+	register(
+		command("utility", "ping", "Shows the bot's latency"),
+		command("utility", "help", "Lists the commands available to you",
+			Option{Name: "ephemeral", Type: OptionBoolean, Description: "Only show the list to whoever asked", Default: true},
+		),
+		command("utility", "avatar", "Shows a user's avatar"),
+		command("utility", "userinfo", "Shows information about a user"),
+		command("utility", "serverinfo", "Shows information about the server"),
+
+		command("fun", "8ball", "Answers a question",
+			Option{Name: "answers", Type: OptionString, Description: "Comma separated answers to pick from", MaxLength: 1000},
+		),
+		command("fun", "coinflip", "Flips a coin"),
+		command("fun", "roll", "Rolls a die",
+			Option{Name: "sides", Type: OptionInteger, Description: "The number of sides of the die", Default: 6, Min: bound(2), Max: bound(1000)},
+		),
+
+		command("moderation", "warn", "Warns a user",
+			Option{Name: "dm", Type: OptionBoolean, Description: "Send the user a direct message", Default: true},
+		),
+		command("moderation", "kick", "Kicks a user",
+			Option{Name: "dm", Type: OptionBoolean, Description: "Send the user a direct message", Default: true},
+		),
+		command("moderation", "ban", "Bans a user",
+			Option{Name: "dm", Type: OptionBoolean, Description: "Send the user a direct message", Default: true},
+			Option{Name: "deleteMessageDays", Type: OptionInteger, Description: "Days of messages to delete", Default: 0, Min: bound(0), Max: bound(7)},
+		),
+		command("moderation", "mute", "Mutes a user",
+			Option{Name: "role", Type: OptionRole, Description: "The role given to muted users", Required: true},
+			Option{Name: "defaultDuration", Type: OptionInteger, Description: "Minutes a mute lasts when no duration is given, 0 is permanent", Default: 0, Min: bound(0), Max: bound(40320)},
+		),
+		command("moderation", "timeout", "Times a user out",
+			Option{Name: "defaultDuration", Type: OptionInteger, Description: "Minutes a timeout lasts when no duration is given", Default: 60, Min: bound(1), Max: bound(40320)},
+		),
+		command("moderation", "purge", "Deletes recent messages in a channel",
+			Option{Name: "max", Type: OptionInteger, Description: "The most messages one purge may delete", Default: 100, Min: bound(1), Max: bound(1000)},
+		),
+		command("moderation", "modlog", "Shows a user's moderation history",
+			Option{Name: "channel", Type: OptionChannel, Description: "Restrict the output to this channel"},
+		),
+	)
+}
+
+// Catalog returns the built-in commands sorted by module and name
+func Catalog() []Command {
+	catalog := make([]Command, 0, len(Registry))
+
+	for _, c := range Registry {
+		catalog = append(catalog, c)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Module == catalog[j].Module {
+			return catalog[i].Name < catalog[j].Name
+		}
+		return catalog[i].Module < catalog[j].Module
+	})
+
+	return catalog
+}
+
+// Permissions returns the permission node of every built-in command
+func Permissions() []string {
+	nodes := make([]string, 0, len(Registry))
+
+	for _, c := range Registry {
+		nodes = append(nodes, c.Permission)
+	}
+
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+var snowflake = regexp.MustCompile(`^\d{17,20}$`)
+
+// IsSnowflake reports whether value looks like a Discord ID
+func IsSnowflake(value string) bool {
+	return snowflake.MatchString(value)
+}
+
+func (o Option) validate(value any) (any, error) {
+	switch o.Type {
+	case OptionBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+
+		return nil, fmt.Errorf("%s must be true or false", o.Name)
+	case OptionInteger, OptionNumber:
+		n, ok := value.(float64)
+
+		if !ok {
+			if i, isInt := value.(int); isInt {
+				n, ok = float64(i), true
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be a number", o.Name)
+		}
+
+		if o.Type == OptionInteger && n != math.Trunc(n) {
+			return nil, fmt.Errorf("%s must be a whole number", o.Name)
+		}
+
+		if o.Min != nil && n < *o.Min {
+			return nil, fmt.Errorf("%s must be at least %v", o.Name, *o.Min)
+		}
+
+		if o.Max != nil && n > *o.Max {
+			return nil, fmt.Errorf("%s must be at most %v", o.Name, *o.Max)
+		}
+
+		if o.Type == OptionInteger {
+			return int(n), nil
+		}
+
+		return n, nil
+	default:
+		s, ok := value.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", o.Name)
+		}
+
+		switch o.Type {
+		case OptionChannel, OptionRole, OptionUser:
+			if !IsSnowflake(s) {
+				return nil, fmt.Errorf("%s must be a Discord %s ID", o.Name, o.Type)
+			}
+		}
+
+		if o.MaxLength > 0 && len(s) > o.MaxLength {
+			return nil, fmt.Errorf("%s must be at most %d characters", o.Name, o.MaxLength)
+		}
+
+		if len(o.Choices) > 0 {
+			for _, choice := range o.Choices {
+				if s == choice {
+					return s, nil
+				}
+			}
+
+			return nil, fmt.Errorf("%s must be one of %s", o.Name, strings.Join(o.Choices, ", "))
+		}
+
+		return s, nil
+	}
+}
+
+// ValidateOptions checks configured options against the command's schema and fills in defaults
+func (c Command) ValidateOptions(options map[string]any) (map[string]any, error) {
+	known := map[string]Option{}
+
+	for _, o := range c.Options {
+		known[o.Name] = o
+	}
+
+	for name := range options {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("%s has no option %q", c.Name, name)
+		}
+	}
+
+	validated := map[string]any{}
+
+	for _, o := range c.Options {
+		value, ok := options[o.Name]
+
+		if !ok || value == nil {
+			if o.Required {
+				return nil, fmt.Errorf("%s is required", o.Name)
+			}
+
+			if o.Default != nil {
+				validated[o.Name] = o.Default
+			}
+
+			continue
+		}
+
+		v, err := o.validate(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		validated[o.Name] = v
+	}
+
+	return validated, nil
+}
@@ -0,0 +1,192 @@
+package discordapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	DefaultBaseURL = "https://discord.com/api/v10"
+	// requests are tried this many times in total before giving up
+	DefaultAttempts = 4
+	// the longest a rate limit is waited out, longer limits fail the request instead
+	MaxRetryAfter = 30 * time.Second
+)
+
+// sleep is replaced to keep retries fast when testing against a fake server
+var sleep = time.Sleep
+
+// BaseURL is Discord's API, DISCORD_API_URL points it at another server such as a local fake
+func BaseURL() string {
+	if url := os.Getenv("DISCORD_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+
+	return DefaultBaseURL
+}
+
+// Error is a response from Discord that is not a success
+type Error struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// seconds to wait before trying again, only set for rate limits
+	RetryAfter float64 `json:"retry_after,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("discord responded with %d", e.Status)
+	}
+
+	return fmt.Sprintf("discord responded with %d: %s (%d)", e.Status, e.Message, e.Code)
+}
+
+// Retryable reports whether trying the request again may succeed
+func (e *Error) Retryable() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+type Client struct {
+	BaseURL string
+	Token   string
+	// Bot for bot tokens, Bearer for users' OAuth access tokens
+	Scheme   string
+	Attempts int
+}
+
+// New returns a client that authenticates as a bot
+func New(token string) *Client {
+	return &Client{BaseURL: BaseURL(), Token: token, Scheme: "Bot", Attempts: DefaultAttempts}
+}
+
+// NewBearer returns a client that acts for a user with their OAuth access token
+func NewBearer(token string) *Client {
+	return &Client{BaseURL: BaseURL(), Token: token, Scheme: "Bearer", Attempts: DefaultAttempts}
+}
+
+// Result describes how a request went, including failed ones
+type Result struct {
+	Attempts int
+}
+
+func (c *Client) do(method string, path string, body any) (int, []byte, error) {
+	client := fiber.AcquireClient()
+	defer fiber.ReleaseClient(client)
+
+	url := c.BaseURL + path
+
+	var agent *fiber.Agent
+
+	switch method {
+	case http.MethodPost:
+		agent = client.Post(url)
+	case http.MethodPatch:
+		agent = client.Patch(url)
+	case http.MethodPut:
+		agent = client.Put(url)
+	case http.MethodDelete:
+		agent = client.Delete(url)
+	default:
+		agent = client.Get(url)
+	}
+
+	agent.Add("Authorization", c.Scheme+" "+c.Token)
+	agent.Add("User-Agent", "DiscordBot (https://astralapp.io, 1.0)")
+	agent.Timeout(10 * time.Second)
+
+	if body != nil {
+		agent.JSON(body)
+	}
+
+	status, data, errs := agent.Bytes()
+
+	if len(errs) > 0 {
+		return 0, nil, errs[0]
+	}
+
+	return status, data, nil
+}
+
+// Request calls the API, retrying rate limits, server errors and network errors with a backoff.
+// out is filled with the response body when it is not nil.
+func (c *Client) Request(method string, path string, body any, out any) (Result, error) {
+	result := Result{}
+	backoff := 500 * time.Millisecond
+
+	for {
+		result.Attempts++
+
+		status, data, err := c.do(method, path, body)
+
+		if err == nil && status >= 200 && status < 300 {
+			if out != nil && len(data) > 0 {
+				err = json.Unmarshal(data, out)
+			}
+
+			return result, err
+		}
+
+		wait := backoff
+		backoff *= 2
+
+		if err == nil {
+			apiErr := &Error{Status: status}
+
+			// the body is not always JSON, a missing message falls back to the status
+			_ = json.Unmarshal(data, apiErr)
+
+			err = apiErr
+
+			if !apiErr.Retryable() {
+				return result, err
+			}
+
+			if status == http.StatusTooManyRequests {
+				wait = time.Duration(apiErr.RetryAfter * float64(time.Second))
+
+				if wait > MaxRetryAfter {
+					return result, err
+				}
+			}
+		}
+
+		if result.Attempts >= c.Attempts {
+			return result, err
+		}
+
+		sleep(wait)
+	}
+}
+
+type MessageCreate struct {
+	Content string        `json:"content,omitempty"`
+	Embeds  []utils.Embed `json:"embeds,omitempty"`
+	// mentions in logs are for display, nobody should be pinged by them
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type Message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// CreateMessage posts a message to a channel
+func (c *Client) CreateMessage(channel string, message MessageCreate) (Message, Result, error) {
+	var out Message
+
+	result, err := c.Request(http.MethodPost, "/channels/"+channel+"/messages", message, &out)
+
+	return out, result, err
+}
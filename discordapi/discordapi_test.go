@@ -0,0 +1,176 @@
+package discordapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// reply is one response of the fake Discord API
+type reply struct {
+	status int
+	body   string
+}
+
+// fake serves the replies in order, repeating the last one, and records what it was sent
+func fake(t *testing.T, replies ...reply) (*int, *http.Header) {
+	t.Helper()
+
+	requests := 0
+	header := &http.Header{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/channels/123/messages" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		*header = r.Header.Clone()
+
+		reply := replies[len(replies)-1]
+
+		if requests < len(replies) {
+			reply = replies[requests]
+		}
+
+		requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(reply.status)
+		fmt.Fprint(w, reply.body)
+	}))
+
+	t.Cleanup(server.Close)
+	t.Setenv("DISCORD_API_URL", server.URL+"/")
+
+	return &requests, header
+}
+
+// waits replaces sleep for the test, recording the waits instead of sleeping
+func waits(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	slept := &[]time.Duration{}
+
+	sleep = func(d time.Duration) {
+		*slept = append(*slept, d)
+	}
+
+	t.Cleanup(func() {
+		sleep = time.Sleep
+	})
+
+	return slept
+}
+
+func TestCreateMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []reply
+		// the status of the error, 0 for success
+		status   int
+		attempts int
+		waits    []time.Duration
+	}{
+		{
+			name:     "success",
+			replies:  []reply{{200, `{"id":"456","channel_id":"123"}`}},
+			attempts: 1,
+		},
+		{
+			name: "rate limit waits retry_after",
+			replies: []reply{
+				{429, `{"message":"You are being rate limited.","retry_after":1.5,"global":false}`},
+				{200, `{"id":"456","channel_id":"123"}`},
+			},
+			attempts: 2,
+			waits:    []time.Duration{1500 * time.Millisecond},
+		},
+		{
+			name:     "rate limit longer than the maximum fails",
+			replies:  []reply{{429, `{"message":"You are being rate limited.","retry_after":60}`}},
+			status:   429,
+			attempts: 1,
+		},
+		{
+			name:     "server errors are retried with a backoff until the attempts run out",
+			replies:  []reply{{502, `bad gateway`}},
+			status:   502,
+			attempts: DefaultAttempts,
+			waits:    []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second},
+		},
+		{
+			name: "server errors that recover",
+			replies: []reply{
+				{500, `{"message":"internal error","code":0}`},
+				{200, `{"id":"456","channel_id":"123"}`},
+			},
+			attempts: 2,
+			waits:    []time.Duration{500 * time.Millisecond},
+		},
+		{
+			name:     "client errors are not retried",
+			replies:  []reply{{403, `{"message":"Missing Permissions","code":50013}`}},
+			status:   403,
+			attempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests, header := fake(t, test.replies...)
+			slept := waits(t)
+
+			message, result, err := New("token").CreateMessage("123", MessageCreate{Content: "hello"})
+
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if message.ID != "456" {
+					t.Errorf("message ID = %q, want 456", message.ID)
+				}
+			} else {
+				var apiErr *Error
+
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("error = %v, want an *Error", err)
+				}
+
+				if apiErr.Status != test.status {
+					t.Errorf("status = %d, want %d", apiErr.Status, test.status)
+				}
+			}
+
+			if result.Attempts != test.attempts || *requests != test.attempts {
+				t.Errorf("attempts = %d with %d requests, want %d", result.Attempts, *requests, test.attempts)
+			}
+
+			if fmt.Sprint(*slept) != fmt.Sprint(test.waits) {
+				t.Errorf("waits = %v, want %v", *slept, test.waits)
+			}
+
+			if got := header.Get("Authorization"); got != "Bot token" {
+				t.Errorf("Authorization = %q, want %q", got, "Bot token")
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	requests, _ := fake(t, reply{404, `{"message":"Unknown Channel","code":10003}`})
+	waits(t)
+
+	_, _, err := New("token").CreateMessage("123", MessageCreate{Content: "hello"})
+
+	if err == nil || err.Error() != "discord responded with 404: Unknown Channel (10003)" {
+		t.Errorf("error = %v", err)
+	}
+
+	if *requests != 1 {
+		t.Errorf("requests = %d, want 1", *requests)
+	}
+}
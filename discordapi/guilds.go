@@ -0,0 +1,124 @@
+package discordapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Permission bits, only the ones the API needs to know about
+var Permissions = map[string]int64{
+	"KICK_MEMBERS":         1 << 1,
+	"BAN_MEMBERS":          1 << 2,
+	"ADMINISTRATOR":        1 << 3,
+	"MANAGE_GUILD":         1 << 5,
+	"ADD_REACTIONS":        1 << 6,
+	"VIEW_CHANNEL":         1 << 10,
+	"SEND_MESSAGES":        1 << 11,
+	"MANAGE_MESSAGES":      1 << 13,
+	"EMBED_LINKS":          1 << 14,
+	"READ_MESSAGE_HISTORY": 1 << 16,
+	"MENTION_EVERYONE":     1 << 17,
+	"MANAGE_ROLES":         1 << 28,
+	"MODERATE_MEMBERS":     1 << 40,
+}
+
+// PermissionBits combines permissions by name, unknown names are skipped
+func PermissionBits(names []string) int64 {
+	var bits int64
+
+	for _, name := range names {
+		bits |= Permissions[name]
+	}
+
+	return bits
+}
+
+// the most guilds Discord returns at once
+const guildsPageSize = 200
+
+// Guild is a guild as listed for the current user, Permissions is only set for users
+type Guild struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Icon        *string `json:"icon"`
+	Owner       bool    `json:"owner"`
+	Permissions string  `json:"permissions,omitempty"`
+}
+
+// CanManage reports whether the user the guild was listed for has Manage Server in it
+func (g Guild) CanManage() bool {
+	if g.Owner {
+		return true
+	}
+
+	bits, err := strconv.ParseInt(g.Permissions, 10, 64)
+
+	if err != nil {
+		return false
+	}
+
+	return bits&(Permissions["ADMINISTRATOR"]|Permissions["MANAGE_GUILD"]) != 0
+}
+
+// CurrentUserGuilds lists every guild the client's user or bot is in
+func (c *Client) CurrentUserGuilds() ([]Guild, Result, error) {
+	guilds := []Guild{}
+	total := Result{}
+	after := ""
+
+	for {
+		var page []Guild
+
+		query := url.Values{"limit": {strconv.Itoa(guildsPageSize)}}
+
+		if after != "" {
+			query.Set("after", after)
+		}
+
+		result, err := c.Request(http.MethodGet, "/users/@me/guilds?"+query.Encode(), nil, &page)
+		total.Attempts += result.Attempts
+
+		if err != nil {
+			return guilds, total, err
+		}
+
+		guilds = append(guilds, page...)
+
+		if len(page) < guildsPageSize {
+			return guilds, total, nil
+		}
+
+		after = page[len(page)-1].ID
+	}
+}
+
+type Application struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CurrentApplication returns the application of the client's bot
+func (c *Client) CurrentApplication() (Application, Result, error) {
+	var out Application
+
+	result, err := c.Request(http.MethodGet, "/oauth2/applications/@me", nil, &out)
+
+	return out, result, err
+}
+
+// InviteURL returns the link that adds a bot to a guild with the given permissions
+func InviteURL(application string, permissions int64, guild string) string {
+	query := url.Values{
+		"client_id":   {application},
+		"scope":       {"bot applications.commands"},
+		"permissions": {strconv.FormatInt(permissions, 10)},
+	}
+
+	if guild != "" {
+		query.Set("guild_id", guild)
+		query.Set("disable_guild_select", "true")
+	}
+
+	return "https://discord.com/oauth2/authorize?" + query.Encode()
+}
@@ -5,8 +5,10 @@ go 1.18
 require (
 	github.com/astralservices/goblox v1.1.0
 	github.com/aybabtme/orderedjson v0.1.0
+	github.com/getsentry/sentry-go v0.13.0
 	github.com/goccy/go-json v0.9.7
 	github.com/gofiber/fiber/v2 v2.34.0
+	github.com/gofiber/storage/postgres v0.0.0-20220523092334-6d96fb56afb5
 	github.com/golang-jwt/jwt/v4 v4.4.1
 	github.com/gorilla/context v1.1.1
 	github.com/gorilla/handlers v1.5.1
@@ -15,6 +17,7 @@ require (
 	github.com/markbates/goth v1.72.0
 	github.com/nedpals/supabase-go v0.1.8
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	github.com/nqd/flat v0.1.1
 	github.com/shareed2k/goth_fiber v0.2.6
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stripe/stripe-go/v72 v72.114.0
@@ -23,14 +26,11 @@ require (
 require (
 	github.com/andybalholm/brotli v1.0.4 // indirect
 	github.com/aybabtme/flatjson v0.1.1 // indirect
-	github.com/getsentry/sentry-go v0.13.0 // indirect
-	github.com/gofiber/storage/postgres v0.0.0-20220523092334-6d96fb56afb5 // indirect
 	github.com/gorilla/securecookie v1.1.1 // indirect
 	github.com/gorilla/sessions v1.1.1 // indirect
 	github.com/imdario/mergo v0.3.12 // indirect
 	github.com/klauspost/compress v1.15.0 // indirect
 	github.com/lib/pq v1.10.6 // indirect
-	github.com/nqd/flat v0.1.1 // indirect
 	github.com/swaggo/files v0.0.0-20210815190702-a29dd2bc99b2 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.37.0 // indirect
@@ -52,7 +52,7 @@ require (
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
-	github.com/nedpals/postgrest-go v0.1.3 // indirect
+	github.com/nedpals/postgrest-go v0.1.3
 	github.com/russross/blackfriday/v2 v2.0.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
 	github.com/swaggo/http-swagger v1.2.8
@@ -61,9 +61,9 @@ require (
 	golang.org/x/net v0.0.0-20220516155154-20f960328961 // indirect
 	golang.org/x/oauth2 v0.0.0-20220411215720-9780585627b5 // indirect
 	golang.org/x/sys v0.0.0-20220513210249-45d2b4557a2a // indirect
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/text v0.3.7
 	golang.org/x/tools v0.1.10 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
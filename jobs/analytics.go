@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/astralservices/api/analytics"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// AnalyticsRetention downsamples and purges the analytics of every bot according to its workspace's plan
+func AnalyticsRetention() error {
+	database := db.New()
+
+	var plans []utils.IPlan
+
+	err := database.DB.From("plans").Select("*").Execute(&plans)
+
+	if err != nil {
+		return err
+	}
+
+	plansByNumber := map[int64]utils.IPlan{}
+
+	for _, plan := range plans {
+		plansByNumber[utils.Plans[plan.ID]] = plan
+	}
+
+	var bots []utils.IBot
+
+	err = database.DB.From("bots").Select("id, workspace(plan)").Execute(&bots)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	for _, bot := range bots {
+		if bot.Workspace == nil {
+			continue
+		}
+
+		policy := analytics.PolicyForPlan(plansByNumber[bot.Workspace.Plan])
+
+		report, err := analytics.ApplyRetention(database, *bot.ID, policy, now, false)
+
+		if err != nil {
+			// the next run picks up where this one stopped
+			log.Errorf("analytics retention for bot %s failed: %v", *bot.ID, err)
+			continue
+		}
+
+		for _, step := range report.Steps {
+			if step.Deleted > 0 {
+				log.Infof("analytics retention for bot %s: %s on %s deleted %d rows and wrote %d", *bot.ID, step.Stage, step.Table, step.Deleted, step.Written)
+			}
+		}
+	}
+
+	return nil
+}
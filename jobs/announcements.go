@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/astralservices/api/announcements"
+	"github.com/astralservices/api/modules"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// AnnouncementScheduler queues the announcements whose next run has passed for their bots' runners
+func AnnouncementScheduler() error {
+	database := db.New()
+
+	now := time.Now().UTC()
+
+	due, err := announcements.Due(database, now)
+
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	ids := []string{}
+
+	for _, announcement := range due {
+		ids = append(ids, announcement.Bot)
+	}
+
+	var bots []utils.IBot
+
+	err = database.DB.From("bots").Select("id, settings").In("id", ids).Execute(&bots)
+
+	if err != nil {
+		return err
+	}
+
+	enabled := map[string]bool{}
+
+	for _, bot := range bots {
+		module, _ := modules.Resolve(bot.Settings.Modules, "announcements")
+		enabled[*bot.ID] = module.Enabled
+	}
+
+	for _, announcement := range due {
+		// announcements of bots with the module off are skipped rather than sent late once it is turned on
+		if !enabled[announcement.Bot] {
+			err = announcements.Advance(database, announcement, now, nil)
+		} else {
+			err = announcements.Send(database, announcement, now)
+		}
+
+		if err != nil {
+			log.Errorf("scheduling announcement %s of bot %s failed: %v", *announcement.ID, announcement.Bot, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/astralservices/api/blacklist"
+	db "github.com/astralservices/api/supabase"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlacklistExpiry lifts blacklist entries whose expiry has passed
+func BlacklistExpiry() error {
+	database := db.New()
+
+	entries, err := blacklist.DueForLift(database, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		// lifted meanwhile, by staff or by another replica
+		if _, err := blacklist.Lift(database, entry, "system", "Expired"); err != nil && !errors.Is(err, blacklist.ErrLifted) {
+			log.Errorf("lifting blacklist entry %d failed: %v", entry.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"time"
+
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Start runs every background job on its own interval for the lifetime of the process
+func Start() {
+	go every(24*time.Hour, "analytics retention", exclusive("analytics retention", 23*time.Hour, AnalyticsRetention))
+	go every(time.Minute, "moderation expiry", exclusive("moderation expiry", 50*time.Second, ModerationExpiry))
+	go every(5*time.Minute, "blacklist expiry", exclusive("blacklist expiry", 4*time.Minute, BlacklistExpiry))
+	go every(time.Minute, "announcement scheduler", AnnouncementScheduler)
+
+	go run("module migration", once("module migration", ModuleMigration))
+	go every(time.Minute, "presence rotation", exclusive("presence rotation", 50*time.Second, PresenceRotation))
+}
+
+// jobs that go through every bot read them this many at a time, a single read is cut off at PostgREST's row limit
+const botPageSize = 1000
+
+func run(name string, job func() error) {
+	start := time.Now()
+
+	if err := job(); err != nil {
+		log.Errorf("job %s failed: %v", name, err)
+	} else {
+		log.Debugf("job %s finished in %s", name, time.Since(start))
+	}
+}
+
+func every(interval time.Duration, name string, job func() error) {
+	for {
+		run(name, job)
+
+		time.Sleep(interval)
+	}
+}
+
+// exclusive runs a job on one replica at a time. The lease is kept until it expires rather than released when the
+// job finishes, so a job on a long interval runs once per lease across every replica instead of once per replica.
+func exclusive(name string, lease time.Duration, job func() error) func() error {
+	return func() error {
+		var taken bool
+
+		err := utils.Rpc(db.New(), "try_job_lock", map[string]interface{}{
+			"p_name":    name,
+			"p_seconds": int(lease.Seconds()),
+		}, &taken)
+
+		if err != nil || !taken {
+			return err
+		}
+
+		return job()
+	}
+}
+
+// once runs a job that only has to succeed a single time, across every replica and restart. Finished jobs are
+// recorded in completed_jobs. A job that fails is tried again by the next process to start once its hour long lease
+// runs out.
+func once(name string, job func() error) func() error {
+	return exclusive(name, time.Hour, func() error {
+		database := db.New()
+
+		var completed []struct {
+			Name string `json:"name"`
+		}
+
+		err := database.DB.From("completed_jobs").Select("name").Eq("name", name).Execute(&completed)
+
+		if err != nil || len(completed) > 0 {
+			return err
+		}
+
+		if err = job(); err != nil {
+			return err
+		}
+
+		return database.DB.From("completed_jobs").Insert(map[string]interface{}{"name": name}).Execute(nil)
+	})
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/astralservices/api/moderation"
+	db "github.com/astralservices/api/supabase"
+	log "github.com/sirupsen/logrus"
+)
+
+// ModerationExpiry asks runners to undo temporary actions whose expiry has passed
+func ModerationExpiry() error {
+	database := db.New()
+
+	actions, err := moderation.DueForExpiry(database, time.Now().UTC())
+
+	if err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		if err := moderation.Expire(database, action); err != nil {
+			log.Errorf("expiring moderation action %s failed: %v", action.ID, err)
+		}
+	}
+
+	return nil
+}
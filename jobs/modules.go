@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/astralservices/api/modules"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// ModuleMigration rewrites the module settings of every bot into the shape of the module registry. Options that do
+// not fit a module's schema any more are kept as they are and logged, instead of being reset to the defaults.
+// Bots are only written if their modules were not saved while the migration ran, the ones that were, or that could
+// not be written, fail the run so it is tried again.
+func ModuleMigration() error {
+	database := db.New()
+
+	failed := 0
+
+	for offset := 0; ; offset += botPageSize {
+		var bots []struct {
+			ID      string          `json:"id"`
+			Modules json.RawMessage `json:"modules"`
+		}
+
+		query := database.DB.From("bots").Select("id, settings->modules").LimitWithOffset(botPageSize, offset)
+
+		query.Filter("order", "id", "asc")
+
+		if err := query.Execute(&bots); err != nil {
+			return err
+		}
+
+		for _, bot := range bots {
+			if !migrateModules(database, bot.ID, bot.Modules) {
+				failed++
+			}
+		}
+
+		if len(bots) < botPageSize {
+			break
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("the modules of %d bots were not migrated", failed)
+	}
+
+	return nil
+}
+
+// migrateModules rewrites the module settings of one bot, reporting whether they are in the registry's shape now
+// or were left as they are on purpose
+func migrateModules(database *supabase.Client, bot string, modulesJSON json.RawMessage) bool {
+	var settings utils.IBotModules
+
+	if len(modulesJSON) > 0 {
+		if err := json.Unmarshal(modulesJSON, &settings); err != nil {
+			log.Errorf("the modules of bot %s cannot be read, they are left as they are: %v", bot, err)
+			return true
+		}
+	}
+
+	migrated := utils.IBotModules{}
+
+	for id, module := range settings {
+		migrated[id] = module
+	}
+
+	for id, m := range modules.Registry {
+		configured := settings[id]
+
+		options, err := m.Validate(configured.Options)
+
+		if err != nil {
+			log.Warnf("the %s options of bot %s do not fit the module's schema and are kept as they are: %v", id, bot, err)
+			continue
+		}
+
+		migrated[id] = utils.IBotModule[map[string]any]{Enabled: configured.Enabled, Options: options}
+	}
+
+	// compared as JSON, options decoded from the database hold float64 where migrated ones hold int
+	before, _ := json.Marshal(settings)
+	after, _ := json.Marshal(migrated)
+
+	if bytes.Equal(before, after) {
+		return true
+	}
+
+	from := modulesJSON
+
+	if len(from) == 0 {
+		from = json.RawMessage("null")
+	}
+
+	var replaced bool
+
+	err := utils.Rpc(database, "set_bot_modules", map[string]interface{}{
+		"p_bot":  bot,
+		"p_from": from,
+		"p_to":   migrated,
+	}, &replaced)
+
+	if err != nil {
+		log.Errorf("migrating the modules of bot %s failed: %v", bot, err)
+		return false
+	}
+
+	if !replaced {
+		log.Infof("the modules of bot %s changed while they were migrated", bot)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/astralservices/api/presence"
+	db "github.com/astralservices/api/supabase"
+	"github.com/astralservices/api/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// PresenceRotation advances the activity of every bot whose interval has passed or whose schedule has changed
+func PresenceRotation() error {
+	database := db.New()
+
+	now := time.Now().UTC()
+
+	for offset := 0; ; offset += botPageSize {
+		var bots []utils.IBot
+
+		query := database.DB.From("bots").Select("id, settings, commands").LimitWithOffset(botPageSize, offset)
+
+		query.Filter("order", "id", "asc")
+
+		if err := query.Execute(&bots); err != nil {
+			return err
+		}
+
+		for _, bot := range bots {
+			if !presence.Due(bot.Settings, now) {
+				continue
+			}
+
+			if err := presence.Rotate(database, bot, now); err != nil {
+				log.Errorf("rotating the activity of bot %s failed: %v", *bot.ID, err)
+			}
+		}
+
+		if len(bots) < botPageSize {
+			return nil
+		}
+	}
+}
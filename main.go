@@ -12,6 +12,7 @@ import (
 	v1 "github.com/astralservices/api/api/v1"
 	"github.com/astralservices/api/api/v1/auth"
 	_ "github.com/astralservices/api/docs"
+	"github.com/astralservices/api/jobs"
 	"github.com/astralservices/api/utils"
 	"github.com/getsentry/sentry-go"
 	"github.com/goccy/go-json"
@@ -67,6 +68,8 @@ func main() {
 
 	auth.InitGoth()
 
+	jobs.Start()
+
 	v1.V1Handler(api.Group("/v1", func(c *fiber.Ctx) error {
 		c.Set("Version", "v1")
 		return c.Next()
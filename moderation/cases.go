@@ -0,0 +1,267 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	CaseOpen        = "open"
+	CaseUnderReview = "under_review"
+	CaseClosed      = "closed"
+)
+
+const (
+	AppealPending  = "pending"
+	AppealApproved = "approved"
+	AppealDenied   = "denied"
+)
+
+var CaseStatuses = map[string]bool{
+	CaseOpen:        true,
+	CaseUnderReview: true,
+	CaseClosed:      true,
+}
+
+type CaseDetails struct {
+	utils.IModerationCase
+	Timeline []utils.IModerationCaseEvent `json:"timeline"`
+	Appeals  []utils.IModerationAppeal    `json:"appeals"`
+}
+
+// AddEvent appends an event to a case's timeline
+func AddEvent(database *supabase.Client, caseId string, eventType string, actor string, data any) error {
+	return database.DB.From("moderation_case_events").Insert(utils.IModerationCaseEvent{
+		Case:  caseId,
+		Type:  eventType,
+		Actor: actor,
+		Data:  data,
+	}).Execute(nil)
+}
+
+func CreateCase(database *supabase.Client, c utils.IModerationCase, actor string) (utils.IModerationCase, error) {
+	var cases []utils.IModerationCase
+
+	if c.Actions == nil {
+		c.Actions = []string{}
+	}
+
+	c.Status = CaseOpen
+	c.Notes = []utils.IModerationCaseNote{}
+	c.Evidence = []utils.IModerationCaseEvidence{}
+
+	err := database.DB.From("moderation_cases").Insert(c).Execute(&cases)
+
+	if err != nil {
+		return c, err
+	}
+
+	c = cases[0]
+
+	return c, AddEvent(database, *c.ID, "created", actor, map[string]any{"title": c.Title, "actions": c.Actions})
+}
+
+func GetCase(database *supabase.Client, bot string, id string) (*utils.IModerationCase, error) {
+	var cases []utils.IModerationCase
+
+	err := database.DB.From("moderation_cases").Select("*").Eq("bot", bot).Eq("id", id).Execute(&cases)
+
+	if err != nil || len(cases) == 0 {
+		return nil, err
+	}
+
+	return &cases[0], nil
+}
+
+func GetCaseDetails(database *supabase.Client, c utils.IModerationCase) (CaseDetails, error) {
+	details := CaseDetails{IModerationCase: c}
+
+	query := database.DB.From("moderation_case_events").Select("*").Eq("case", *c.ID)
+	query.Filter("order", "created_at", "asc")
+
+	err := query.Execute(&details.Timeline)
+
+	if err != nil {
+		return details, err
+	}
+
+	err = database.DB.From("moderation_appeals").Select("*").Eq("case", *c.ID).Execute(&details.Appeals)
+
+	return details, err
+}
+
+// UpdateCase saves changes to a case and records them on its timeline
+func UpdateCase(database *supabase.Client, c utils.IModerationCase, changes map[string]interface{}, eventType string, actor string, data any) (utils.IModerationCase, error) {
+	var cases []utils.IModerationCase
+
+	err := database.DB.From("moderation_cases").Update(changes).Eq("id", *c.ID).Execute(&cases)
+
+	if err != nil {
+		return c, err
+	}
+
+	if len(cases) > 0 {
+		c = cases[0]
+	}
+
+	return c, AddEvent(database, *c.ID, eventType, actor, data)
+}
+
+// appendTo appends a value to one of the list columns of a case in the database, so concurrent appends are all kept,
+// and records it on the timeline. Distinct values that are already in the list are not added or recorded again.
+func appendTo(database *supabase.Client, c utils.IModerationCase, column string, value any, distinct bool, eventType string, actor string, data any) (utils.IModerationCase, error) {
+	var cases []utils.IModerationCase
+
+	err := utils.Rpc(database, "append_moderation_case", map[string]interface{}{
+		"p_case":     *c.ID,
+		"p_column":   column,
+		"p_value":    value,
+		"p_distinct": distinct,
+	}, &cases)
+
+	if err != nil {
+		return c, err
+	}
+
+	if len(cases) == 0 {
+		return c, nil
+	}
+
+	return cases[0], AddEvent(database, *c.ID, eventType, actor, data)
+}
+
+// LinkAction adds a moderation action of the same bot to a case
+func LinkAction(database *supabase.Client, c utils.IModerationCase, actionId string, actor string) (utils.IModerationCase, error) {
+	action, err := Get(database, c.Bot, actionId)
+
+	if err != nil {
+		return c, err
+	}
+
+	if action == nil {
+		return c, errors.New("moderation action not found")
+	}
+
+	return appendTo(database, c, "actions", actionId, true, "action_linked", actor, map[string]any{"action": actionId, "type": action.Action})
+}
+
+func AddNote(database *supabase.Client, c utils.IModerationCase, author string, content string) (utils.IModerationCase, error) {
+	if content == "" {
+		return c, errors.New("notes cannot be empty")
+	}
+
+	note := utils.IModerationCaseNote{Author: author, Content: content, CreatedAt: time.Now().UTC()}
+
+	return appendTo(database, c, "notes", note, false, "note_added", author, note)
+}
+
+func AddEvidence(database *supabase.Client, c utils.IModerationCase, evidence utils.IModerationCaseEvidence) (utils.IModerationCase, error) {
+	return appendTo(database, c, "evidence", evidence, false, "evidence_added", evidence.UploadedBy, evidence)
+}
+
+// FileAppeal lets the moderated user appeal a case, once at a time
+func FileAppeal(database *supabase.Client, c utils.IModerationCase, user string, statement string) (utils.IModerationAppeal, error) {
+	appeal := utils.IModerationAppeal{Case: *c.ID, User: user, Statement: statement, Status: AppealPending}
+
+	if c.User != user {
+		return appeal, errors.New("only the moderated user can appeal this case")
+	}
+
+	if statement == "" {
+		return appeal, errors.New("a statement is required")
+	}
+
+	var appeals []utils.IModerationAppeal
+
+	err := database.DB.From("moderation_appeals").Select("*").Eq("case", *c.ID).Eq("status", AppealPending).Execute(&appeals)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	if len(appeals) > 0 {
+		return appeal, errors.New("this case already has a pending appeal")
+	}
+
+	err = database.DB.From("moderation_appeals").Insert(appeal).Execute(&appeals)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	appeal = appeals[0]
+
+	return appeal, AddEvent(database, *c.ID, "appeal_filed", user, map[string]any{"appeal": *appeal.ID})
+}
+
+func GetAppeal(database *supabase.Client, caseId string, id string) (*utils.IModerationAppeal, error) {
+	var appeals []utils.IModerationAppeal
+
+	err := database.DB.From("moderation_appeals").Select("*").Eq("case", caseId).Eq("id", id).Execute(&appeals)
+
+	if err != nil || len(appeals) == 0 {
+		return nil, err
+	}
+
+	return &appeals[0], nil
+}
+
+// ReviewAppeal approves or denies an appeal, approving it revokes every action of the case and closes it
+func ReviewAppeal(database *supabase.Client, c utils.IModerationCase, appeal utils.IModerationAppeal, approve bool, reviewer string, response string) (utils.IModerationAppeal, error) {
+	if appeal.Status != AppealPending {
+		return appeal, fmt.Errorf("this appeal has already been %s", appeal.Status)
+	}
+
+	status := AppealDenied
+
+	if approve {
+		status = AppealApproved
+
+		for _, actionId := range c.Actions {
+			action, err := Get(database, c.Bot, actionId)
+
+			if err != nil {
+				return appeal, err
+			}
+
+			if action == nil || action.Revoked {
+				continue
+			}
+
+			if _, err = Revoke(database, *action, reviewer, "Appeal approved"); err != nil {
+				return appeal, err
+			}
+		}
+	}
+
+	var appeals []utils.IModerationAppeal
+
+	err := database.DB.From("moderation_appeals").Update(map[string]interface{}{
+		"status":      status,
+		"reviewer":    reviewer,
+		"response":    response,
+		"reviewed_at": time.Now().UTC(),
+	}).Eq("id", *appeal.ID).Execute(&appeals)
+
+	if err != nil {
+		return appeal, err
+	}
+
+	if len(appeals) > 0 {
+		appeal = appeals[0]
+	}
+
+	event := map[string]any{"appeal": *appeal.ID, "response": response}
+
+	if approve {
+		_, err = UpdateCase(database, c, map[string]interface{}{"status": CaseClosed}, "appeal_approved", reviewer, event)
+	} else {
+		err = AddEvent(database, *c.ID, "appeal_denied", reviewer, event)
+	}
+
+	return appeal, err
+}
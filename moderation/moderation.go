@@ -0,0 +1,194 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+// the actions a bot can take, mapped to whether they can be temporary
+var Actions = map[string]bool{
+	"warn":    false,
+	"kick":    false,
+	"mute":    true,
+	"timeout": true,
+	"ban":     true,
+}
+
+var ErrRevoked = errors.New("This action has already been revoked")
+
+// the runner command that undoes a temporary action
+var reversals = map[string]string{
+	"mute":    "moderation.unmute",
+	"timeout": "moderation.untimeout",
+	"ban":     "moderation.unban",
+}
+
+type CommandPayload struct {
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	Guild  string `json:"guild"`
+	User   string `json:"user"`
+	Reason string `json:"reason"`
+}
+
+func payload(action utils.IBotModerationAction, reason string) CommandPayload {
+	return CommandPayload{
+		Action: action.ID,
+		Type:   action.Action,
+		Guild:  action.Guild,
+		User:   action.User,
+		Reason: reason,
+	}
+}
+
+// Validate checks an action before it is created
+func Validate(action utils.IBotModerationAction, now time.Time) error {
+	temporary, ok := Actions[action.Action]
+
+	if !ok {
+		return fmt.Errorf("unknown action %q", action.Action)
+	}
+
+	if action.User == "" {
+		return errors.New("a user is required")
+	}
+
+	if action.Guild == "" {
+		return errors.New("the bot has no guild configured")
+	}
+
+	if action.Expires {
+		if !temporary {
+			return fmt.Errorf("%s actions cannot expire", action.Action)
+		}
+
+		if !action.Expiry.After(now) {
+			return errors.New("expiry must be in the future")
+		}
+	}
+
+	return nil
+}
+
+// Create stores an action and asks the bot's runner to carry it out, in one transaction
+func Create(database *supabase.Client, action utils.IBotModerationAction) (utils.IBotModerationAction, error) {
+	var actions []utils.IBotModerationAction
+
+	row := map[string]interface{}{
+		"bot":       action.Bot,
+		"guild":     action.Guild,
+		"action":    action.Action,
+		"moderator": action.Moderator,
+		"reason":    action.Reason,
+		"expires":   action.Expires,
+		"user":      action.User,
+	}
+
+	if action.Expires {
+		row["expiry"] = action.Expiry.UTC()
+	}
+
+	err := utils.Rpc(database, "create_moderation_action", map[string]interface{}{
+		"p_action":       row,
+		"p_command_type": "moderation." + action.Action,
+	}, &actions)
+
+	if err != nil {
+		return action, err
+	}
+
+	if len(actions) == 0 {
+		return action, errors.New("the action was not stored")
+	}
+
+	action = actions[0]
+
+	logAsync(database, action, EventCreated)
+
+	return action, nil
+}
+
+// Get returns an action of a bot
+func Get(database *supabase.Client, bot string, id string) (*utils.IBotModerationAction, error) {
+	var actions []utils.IBotModerationAction
+
+	err := database.DB.From("moderation_actions").Select("*").Eq("bot", bot).Eq("id", id).Execute(&actions)
+
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+
+	return &actions[0], nil
+}
+
+// Revoke marks an action as revoked, keeping it in the history, and undoes it if it is still in effect
+func Revoke(database *supabase.Client, action utils.IBotModerationAction, by string, reason string) (utils.IBotModerationAction, error) {
+	if action.Revoked {
+		return action, ErrRevoked
+	}
+
+	var actions []utils.IBotModerationAction
+
+	// only one of two requests revoking the same action undoes it
+	err := database.DB.From("moderation_actions").Update(map[string]interface{}{
+		"revoked":       true,
+		"revoked_at":    time.Now().UTC(),
+		"revoked_by":    by,
+		"revoke_reason": reason,
+	}).Eq("id", action.ID).Eq("revoked", "false").Execute(&actions)
+
+	if err != nil {
+		return action, err
+	}
+
+	if len(actions) == 0 {
+		return action, ErrRevoked
+	}
+
+	action = actions[0]
+
+	if command, ok := reversals[action.Action]; ok && !action.Expired {
+		err = utils.QueueRunnerCommand(database, action.Bot, "moderation-revoke-"+action.ID, command, payload(action, reason))
+	}
+
+	return action, err
+}
+
+// Expire undoes a temporary action whose expiry has passed
+func Expire(database *supabase.Client, action utils.IBotModerationAction) error {
+	if command, ok := reversals[action.Action]; ok {
+		err := utils.QueueRunnerCommand(database, action.Bot, "moderation-expiry-"+action.ID, command, payload(action, "Expired"))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	var actions []utils.IBotModerationAction
+
+	// only the run that marks the action as expired logs it, every replica runs the expiry job
+	err := database.DB.From("moderation_actions").Update(map[string]interface{}{
+		"expired": true,
+	}).Eq("id", action.ID).Eq("expired", "false").Execute(&actions)
+
+	if err != nil || len(actions) == 0 {
+		return err
+	}
+
+	logAsync(database, action, EventExpired)
+
+	return nil
+}
+
+// DueForExpiry returns the temporary actions whose expiry has passed
+func DueForExpiry(database *supabase.Client, now time.Time) ([]utils.IBotModerationAction, error) {
+	var actions []utils.IBotModerationAction
+
+	err := database.DB.From("moderation_actions").Select("*").Eq("expires", "true").Eq("expired", "false").Eq("revoked", "false").Lte("expiry", now.UTC().Format(time.RFC3339)).Execute(&actions)
+
+	return actions, err
+}
@@ -0,0 +1,184 @@
+package moderation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/modules"
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventCreated = "created"
+	EventExpired = "expired"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+var actionTitles = map[string]string{
+	"warn":    "Member warned",
+	"kick":    "Member kicked",
+	"mute":    "Member muted",
+	"timeout": "Member timed out",
+	"ban":     "Member banned",
+}
+
+var actionColors = map[string]int{
+	"warn":    0xfee75c,
+	"kick":    0xe67e22,
+	"mute":    0x99aab5,
+	"timeout": 0x99aab5,
+	"ban":     0xed4245,
+}
+
+var expiredTitles = map[string]string{
+	"mute":    "Mute expired",
+	"timeout": "Timeout expired",
+	"ban":     "Ban expired",
+}
+
+// the color of log entries for actions that ended
+const expiredColor = 0x57f287
+
+// LogChannel returns the channel a bot posts its moderation log to, empty if logging is off
+func LogChannel(bot utils.IBot) string {
+	module, _ := modules.Resolve(bot.Settings.Modules, "moderation")
+
+	logging, _ := module.Options["logging"].(map[string]any)
+
+	if !module.Enabled || logging == nil || logging["enabled"] != true {
+		return ""
+	}
+
+	channel, _ := logging["channel"].(string)
+
+	return channel
+}
+
+// Embed renders the log entry of an action
+func Embed(action utils.IBotModerationAction, event string) utils.Embed {
+	reason := action.Reason
+
+	if reason == "" {
+		reason = "No reason given"
+	}
+
+	embed := utils.Embed{
+		Title: actionTitles[action.Action],
+		Color: actionColors[action.Action],
+		Fields: []utils.EmbedField{
+			{Name: "User", Value: fmt.Sprintf("<@%s> (%s)", action.User, action.User), Inline: true},
+			{Name: "Moderator", Value: fmt.Sprintf("<@%s>", action.Moderator), Inline: true},
+			{Name: "Reason", Value: reason},
+		},
+		Footer:    &utils.EmbedFooter{Text: "Action " + action.ID},
+		Timestamp: action.CreatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if action.Expires {
+		embed.Fields = append(embed.Fields, utils.EmbedField{Name: "Expires", Value: fmt.Sprintf("<t:%d:R>", action.Expiry.Unix()), Inline: true})
+	}
+
+	// expiry logs only say who the action was against, the rest is in the log entry of the action
+	if event == EventExpired {
+		embed.Title = expiredTitles[action.Action]
+		embed.Color = expiredColor
+		embed.Fields = embed.Fields[:1]
+		embed.Timestamp = action.Expiry.UTC().Format(time.RFC3339)
+	}
+
+	return embed
+}
+
+// Log posts an action to the bot's moderation log channel and records how the delivery went. Bots without
+// logging configured are skipped without a record, as are events of the action that were already logged.
+func Log(database *supabase.Client, action utils.IBotModerationAction, event string) (*utils.IModlogDelivery, error) {
+	var bots []utils.IBot
+
+	err := database.DB.From("bots").Select("id, token, settings").Eq("id", action.Bot).Execute(&bots)
+
+	if err != nil || len(bots) == 0 {
+		return nil, err
+	}
+
+	channel := LogChannel(bots[0])
+
+	if channel == "" {
+		return nil, nil
+	}
+
+	var deliveries []utils.IModlogDelivery
+
+	err = database.DB.From("modlog_deliveries").Insert(utils.IModlogDelivery{
+		Action:  action.ID,
+		Bot:     action.Bot,
+		Event:   event,
+		Channel: channel,
+		Status:  DeliveryPending,
+	}).Execute(&deliveries)
+
+	// deliveries are unique by action and event, so an event is only posted once, as runner commands are
+	if utils.IsUniqueViolation(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := deliveries[0]
+
+	message, result, sendErr := discordapi.New(bots[0].Token).CreateMessage(channel, discordapi.MessageCreate{
+		Embeds:          []utils.Embed{Embed(action, event)},
+		AllowedMentions: &discordapi.AllowedMentions{Parse: []string{}},
+	})
+
+	update := map[string]interface{}{
+		"attempts": result.Attempts,
+	}
+
+	if sendErr != nil {
+		update["status"] = DeliveryFailed
+		update["error"] = sendErr.Error()
+	} else {
+		update["status"] = DeliveryDelivered
+		update["message"] = message.ID
+		update["delivered_at"] = time.Now().UTC()
+	}
+
+	err = database.DB.From("modlog_deliveries").Update(update).Eq("id", fmt.Sprint(*delivery.ID)).Execute(&deliveries)
+
+	if err != nil {
+		return &delivery, err
+	}
+
+	return &deliveries[0], sendErr
+}
+
+// Deliveries returns the log deliveries of an action, oldest first
+func Deliveries(database *supabase.Client, action string) ([]utils.IModlogDelivery, error) {
+	var deliveries []utils.IModlogDelivery
+
+	query := database.DB.From("modlog_deliveries").Select("*").Eq("action", action)
+	query.Filter("order", "created_at", "asc")
+
+	err := query.Execute(&deliveries)
+
+	return deliveries, err
+}
+
+// logAsync posts an action to the moderation log without holding up the caller, the delivery records the outcome
+func logAsync(database *supabase.Client, action utils.IBotModerationAction, event string) {
+	go func() {
+		if _, err := Log(database, action, event); err != nil {
+			log.Errorf("moderation log for action %s (%s) failed: %v", action.ID, event, err)
+		}
+	}()
+}
@@ -0,0 +1,314 @@
+package modules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/astralservices/api/reactionroles"
+	"github.com/astralservices/api/utils"
+	"github.com/astralservices/api/welcome"
+)
+
+// Module is a feature of the bot that workspaces can enable and configure
+type Module struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+	// the options of a module that has not been configured
+	Defaults map[string]any `json:"defaults"`
+	// the Discord permissions the bot needs for the module to work
+	Permissions []string `json:"permissions"`
+	// the lowest plan the module can be enabled on, every plan if empty
+	Plan string `json:"plan,omitempty"`
+	// checks what the schema cannot, such as templates, after the schema has been applied
+	Check func(options map[string]any) error `json:"-"`
+}
+
+// Registry holds every module by ID
+var Registry = map[string]Module{}
+
+func register(modules ...Module) {
+	for _, m := range modules {
+		if m.Permissions == nil {
+			m.Permissions = []string{}
+		}
+
+		defaults, err := m.Schema.Validate("", m.Defaults)
+
+		if err != nil {
+			panic(fmt.Sprintf("module %s has invalid defaults: %v", m.ID, err))
+		}
+
+		m.Defaults = defaults.(map[string]any)
+
+		Registry[m.ID] = m
+	}
+}
+
+func init() {
+	register(
+		Module{
+			ID:          "utility",
+			Name:        "Utility",
+			Description: "Commands for looking up users, the server and the bot",
+			Schema:      &Schema{Type: TypeObject},
+			Permissions: []string{"SEND_MESSAGES", "EMBED_LINKS"},
+		},
+		Module{
+			ID:          "fun",
+			Name:        "Fun",
+			Description: "Games and toys for your members",
+			Schema:      &Schema{Type: TypeObject},
+			Permissions: []string{"SEND_MESSAGES"},
+		},
+		Module{
+			ID:          "moderation",
+			Name:        "Moderation",
+			Description: "Warnings, mutes, kicks and bans with a moderation log",
+			Schema: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+				"logging": {Type: TypeObject, Title: "Logging", Properties: map[string]*Schema{
+					"enabled": {Type: TypeBoolean, Title: "Post moderation actions to a channel", Default: false},
+					"channel": {Type: TypeString, Title: "Log channel", Pattern: SnowflakePattern, Default: ""},
+				}},
+			}},
+			Permissions: []string{"KICK_MEMBERS", "BAN_MEMBERS", "MODERATE_MEMBERS", "MANAGE_ROLES", "MANAGE_MESSAGES"},
+		},
+		Module{
+			ID:          "automod",
+			Name:        "Automod",
+			Description: "Deletes spam, invites, links and banned words and acts against their authors",
+			Schema: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+				"spam": automodRule("Spam", map[string]*Schema{
+					"maxMessages": {Type: TypeInteger, Title: "Messages allowed", Minimum: bound(2), Maximum: bound(50), Default: 5},
+					"perSeconds":  {Type: TypeInteger, Title: "Within seconds", Minimum: bound(1), Maximum: bound(60), Default: 5},
+				}),
+				"mentions": automodRule("Mass mentions", map[string]*Schema{
+					"max": {Type: TypeInteger, Title: "Mentions allowed per message", Minimum: bound(1), Maximum: bound(50), Default: 5},
+				}),
+				"invites": automodRule("Invite links", map[string]*Schema{
+					"allowed": {Type: TypeArray, Title: "Allowed invite codes", MaxItems: 50, Items: &Schema{Type: TypeString, MaxLength: 32}},
+				}),
+				"links": automodRule("Links", map[string]*Schema{
+					"mode":    {Type: TypeString, Title: "Mode", Enum: []string{"allow", "deny"}, Default: "deny"},
+					"domains": {Type: TypeArray, Title: "Domains", MaxItems: 200, Items: &Schema{Type: TypeString, MaxLength: 253}},
+				}),
+				"caps": automodRule("Excessive caps", map[string]*Schema{
+					"minLength": {Type: TypeInteger, Title: "Only check messages with at least this many letters", Minimum: bound(1), Maximum: bound(2000), Default: 10},
+					"percent":   {Type: TypeInteger, Title: "Percentage of capital letters", Minimum: bound(1), Maximum: bound(100), Default: 70},
+				}),
+				"words": automodRule("Banned words", map[string]*Schema{
+					"words": {Type: TypeArray, Title: "Words, a trailing * matches words starting with it", MaxItems: 500, Items: &Schema{Type: TypeString, MaxLength: 64}},
+				}),
+				"exemptRoles":    {Type: TypeArray, Title: "Exempt roles", MaxItems: 50, Items: &Schema{Type: TypeString, Pattern: SnowflakePattern}},
+				"exemptChannels": {Type: TypeArray, Title: "Exempt channels", MaxItems: 50, Items: &Schema{Type: TypeString, Pattern: SnowflakePattern}},
+			}},
+			Permissions: []string{"MANAGE_MESSAGES", "MODERATE_MEMBERS", "KICK_MEMBERS", "BAN_MEMBERS"},
+			Plan:        "starter",
+		},
+		Module{
+			ID:          "welcome",
+			Name:        "Welcome",
+			Description: "Greets members when they join, says goodbye when they leave and gives new members roles",
+			Schema: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+				"join":      welcomeMessage("Join message", true),
+				"leave":     welcomeMessage("Leave message", false),
+				"autoRoles": {Type: TypeArray, Title: "Roles given to new members", MaxItems: 10, Items: &Schema{Type: TypeString, Pattern: SnowflakePattern}},
+			}},
+			Permissions: []string{"SEND_MESSAGES", "EMBED_LINKS", "MANAGE_ROLES"},
+			Check:       welcome.Check,
+		},
+		Module{
+			ID:          "reactionroles",
+			Name:        "Reaction Roles",
+			Description: "Menus of reactions or buttons members pick their roles from",
+			Schema: &Schema{Type: TypeObject, Properties: map[string]*Schema{
+				"menus": {Type: TypeArray, Title: "Menus", MaxItems: reactionroles.MaxMenus, Items: &Schema{
+					Type:     TypeObject,
+					Required: []string{"id", "channel", "type"},
+					Properties: map[string]*Schema{
+						"id":      {Type: TypeString, Title: "ID", MaxLength: 32},
+						"channel": {Type: TypeString, Title: "Channel", Pattern: SnowflakePattern},
+						"content": {Type: TypeString, Title: "Message", MaxLength: reactionroles.MaxContentLength, Default: ""},
+						"type":    {Type: TypeString, Title: "Type", Enum: reactionroles.Types},
+						"mode":    {Type: TypeString, Title: "Mode", Enum: reactionroles.Modes, Default: reactionroles.ModeToggle},
+						"entries": {Type: TypeArray, Title: "Roles", MaxItems: reactionroles.MaxButtons, Items: &Schema{
+							Type:     TypeObject,
+							Required: []string{"role"},
+							Properties: map[string]*Schema{
+								"emoji": {Type: TypeString, Title: "Emoji", MaxLength: 64, Default: ""},
+								"label": {Type: TypeString, Title: "Button label", MaxLength: reactionroles.MaxLabelLength, Default: ""},
+								"style": {Type: TypeString, Title: "Button style", Enum: []string{"primary", "secondary", "success", "danger"}, Default: "secondary"},
+								"role":  {Type: TypeString, Title: "Role", Pattern: SnowflakePattern},
+							},
+						}},
+					},
+				}},
+			}},
+			Permissions: []string{"SEND_MESSAGES", "ADD_REACTIONS", "READ_MESSAGE_HISTORY", "MANAGE_ROLES"},
+			Check:       reactionroles.Check,
+		},
+		Module{
+			ID:          "announcements",
+			Name:        "Announcements",
+			Description: "Posts scheduled and recurring messages",
+			Schema:      &Schema{Type: TypeObject},
+			Permissions: []string{"SEND_MESSAGES", "EMBED_LINKS", "MENTION_EVERYONE"},
+		},
+	)
+}
+
+// welcomeMessage is the schema of a welcome or leave message
+func welcomeMessage(title string, dm bool) *Schema {
+	properties := map[string]*Schema{
+		"enabled": {Type: TypeBoolean, Title: "Enabled", Default: false},
+		"channel": {Type: TypeString, Title: "Channel", Pattern: SnowflakePattern, Default: ""},
+		"message": {Type: TypeString, Title: "Message", MaxLength: welcome.MaxContentLength, Default: ""},
+		"embed": {Type: TypeObject, Title: "Embed", Properties: map[string]*Schema{
+			"enabled":     {Type: TypeBoolean, Title: "Enabled", Default: false},
+			"title":       {Type: TypeString, Title: "Title", MaxLength: utils.MaxEmbedTitle, Default: ""},
+			"description": {Type: TypeString, Title: "Description", MaxLength: utils.MaxEmbedDescription, Default: ""},
+			"color":       {Type: TypeInteger, Title: "Color", Minimum: bound(0), Maximum: bound(0xffffff), Default: 0},
+			"image":       {Type: TypeString, Title: "Image URL", MaxLength: 2048, Default: ""},
+		}},
+	}
+
+	if dm {
+		properties["dm"] = &Schema{Type: TypeBoolean, Title: "Send as a direct message", Default: false}
+	}
+
+	return &Schema{Type: TypeObject, Title: title, Properties: properties}
+}
+
+// automodRule is the schema of an automod rule, the fields every rule has plus its own
+func automodRule(title string, properties map[string]*Schema) *Schema {
+	properties["enabled"] = &Schema{Type: TypeBoolean, Title: "Enabled", Default: false}
+	properties["actions"] = &Schema{Type: TypeArray, Title: "Actions", MaxItems: 5, Items: &Schema{Type: TypeString, Enum: []string{"delete", "warn", "timeout", "kick", "ban"}}, Default: []any{"delete"}}
+	properties["timeoutDuration"] = &Schema{Type: TypeInteger, Title: "Timeout length in seconds", Minimum: bound(60), Maximum: bound(2419200), Default: 600}
+
+	return &Schema{Type: TypeObject, Title: title, Properties: properties}
+}
+
+// Catalog returns every module sorted by ID
+func Catalog() []Module {
+	catalog := make([]Module, 0, len(Registry))
+
+	for _, m := range Registry {
+		catalog = append(catalog, m)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool {
+		return catalog[i].ID < catalog[j].ID
+	})
+
+	return catalog
+}
+
+// Available reports whether a workspace's plan allows the module
+func (m Module) Available(workspace utils.IWorkspace) bool {
+	return m.Plan == "" || workspace.Plan >= utils.Plans[m.Plan]
+}
+
+// Validate checks a module's options against its schema and fills in defaults
+func (m Module) Validate(options map[string]any) (map[string]any, error) {
+	validated, err := m.Schema.Validate("", options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Check != nil {
+		if err = m.Check(validated.(map[string]any)); err != nil {
+			return nil, err
+		}
+	}
+
+	return validated.(map[string]any), nil
+}
+
+// Resolve returns a bot's settings for a module, modules the bot has not configured are disabled with their defaults
+func Resolve(settings utils.IBotModules, id string) (utils.IBotModule[map[string]any], bool) {
+	m, ok := Registry[id]
+
+	if !ok {
+		return utils.IBotModule[map[string]any]{}, false
+	}
+
+	configured := settings[id]
+
+	options, err := m.Validate(configured.Options)
+
+	// options saved before the module's schema changed fall back to the defaults
+	if err != nil {
+		options, _ = m.Validate(nil)
+	}
+
+	return utils.IBotModule[map[string]any]{Enabled: configured.Enabled, Options: options}, true
+}
+
+// Normalize brings a bot's module settings into the shape of the registry, modules that are no longer
+// registered are kept as they are so nothing is lost
+func Normalize(settings utils.IBotModules) utils.IBotModules {
+	normalized := utils.IBotModules{}
+
+	for id, module := range settings {
+		normalized[id] = module
+	}
+
+	for id := range Registry {
+		normalized[id], _ = Resolve(settings, id)
+	}
+
+	return normalized
+}
+
+// ValidateAll checks the options of every registered module in a bot's settings and that the workspace's plan
+// allows the enabled ones, returning the settings in the shape of the registry
+func ValidateAll(workspace utils.IWorkspace, settings utils.IBotModules) (utils.IBotModules, error) {
+	validated := Normalize(settings)
+
+	for id, module := range settings {
+		m, ok := Registry[id]
+
+		if !ok {
+			continue
+		}
+
+		options, err := m.Validate(module.Options)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+
+		if module.Enabled && !m.Available(workspace) {
+			return nil, fmt.Errorf("the %s module needs the %s plan", m.Name, m.Plan)
+		}
+
+		validated[id] = utils.IBotModule[map[string]any]{Enabled: module.Enabled, Options: options}
+	}
+
+	return validated, nil
+}
+
+// Permissions returns the Discord permissions the bot's enabled modules need, sorted and without duplicates
+func Permissions(settings utils.IBotModules) []string {
+	seen := map[string]bool{}
+	permissions := []string{}
+
+	for _, m := range Catalog() {
+		if !settings[m.ID].Enabled {
+			continue
+		}
+
+		for _, permission := range m.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	sort.Strings(permissions)
+
+	return permissions
+}
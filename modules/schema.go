@@ -0,0 +1,217 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Schema is the subset of JSON Schema module options are described with. Objects never allow
+// properties they do not declare.
+type Schema struct {
+	Type        string             `json:"type"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MaxLength   int                `json:"maxLength,omitempty"`
+	MaxItems    int                `json:"maxItems,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Default     any                `json:"default,omitempty"`
+}
+
+// Discord IDs, as a pattern for string schemas
+const SnowflakePattern = `^\d{17,20}$`
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func at(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Validate checks a value against the schema and returns it with the defaults of missing properties
+// filled in. Numbers are returned as float64, or int for integer schemas.
+func (s *Schema) Validate(path string, value any) (any, error) {
+	label := path
+
+	if label == "" {
+		label = "options"
+	}
+
+	switch s.Type {
+	case TypeObject:
+		if value == nil {
+			value = map[string]any{}
+		}
+
+		object, ok := value.(map[string]any)
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be an object", label)
+		}
+
+		for name := range object {
+			if _, ok := s.Properties[name]; !ok {
+				return nil, fmt.Errorf("%s has no property %q", label, name)
+			}
+		}
+
+		for _, name := range s.Required {
+			if v, ok := object[name]; !ok || v == nil {
+				return nil, fmt.Errorf("%s is required", at(path, name))
+			}
+		}
+
+		names := make([]string, 0, len(s.Properties))
+
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+
+		// validated in a stable order so the same input always reports the same error
+		sort.Strings(names)
+
+		validated := map[string]any{}
+
+		for _, name := range names {
+			property := s.Properties[name]
+			v, ok := object[name]
+
+			if !ok || v == nil {
+				if property.Default != nil {
+					v = property.Default
+				} else if property.Type != TypeObject {
+					continue
+				}
+			}
+
+			v, err := property.Validate(at(path, name), v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			validated[name] = v
+		}
+
+		return validated, nil
+	case TypeArray:
+		if value == nil {
+			return []any{}, nil
+		}
+
+		items, ok := value.([]any)
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list", label)
+		}
+
+		if s.MaxItems > 0 && len(items) > s.MaxItems {
+			return nil, fmt.Errorf("%s can have at most %d items", label, s.MaxItems)
+		}
+
+		validated := make([]any, len(items))
+
+		for i, item := range items {
+			v, err := s.Items.Validate(fmt.Sprintf("%s.%d", label, i), item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			validated[i] = v
+		}
+
+		return validated, nil
+	case TypeBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+
+		return nil, fmt.Errorf("%s must be true or false", label)
+	case TypeInteger, TypeNumber:
+		n, ok := value.(float64)
+
+		if !ok {
+			if i, isInt := value.(int); isInt {
+				n, ok = float64(i), true
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be a number", label)
+		}
+
+		if s.Type == TypeInteger && n != math.Trunc(n) {
+			return nil, fmt.Errorf("%s must be a whole number", label)
+		}
+
+		if s.Minimum != nil && n < *s.Minimum {
+			return nil, fmt.Errorf("%s must be at least %v", label, *s.Minimum)
+		}
+
+		if s.Maximum != nil && n > *s.Maximum {
+			return nil, fmt.Errorf("%s must be at most %v", label, *s.Maximum)
+		}
+
+		if s.Type == TypeInteger {
+			return int(n), nil
+		}
+
+		return n, nil
+	case TypeString:
+		str, ok := value.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", label)
+		}
+
+		if s.MaxLength > 0 && len(str) > s.MaxLength {
+			return nil, fmt.Errorf("%s must be at most %d characters", label, s.MaxLength)
+		}
+
+		// empty strings mean "not set", patterns only apply to values
+		if s.Pattern != "" && str != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			if s.Pattern == SnowflakePattern {
+				return nil, fmt.Errorf("%s must be a Discord ID", label)
+			}
+
+			return nil, fmt.Errorf("%s must match %s", label, s.Pattern)
+		}
+
+		if len(s.Enum) > 0 {
+			for _, choice := range s.Enum {
+				if str == choice {
+					return str, nil
+				}
+			}
+
+			return nil, fmt.Errorf("%s must be one of %s", label, strings.Join(s.Enum, ", "))
+		}
+
+		return str, nil
+	}
+
+	return nil, fmt.Errorf("%s has an unknown schema type %q", label, s.Type)
+}
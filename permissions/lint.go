@@ -0,0 +1,105 @@
+package permissions
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/astralservices/api/utils"
+)
+
+const (
+	IssueInvalid   = "invalid"
+	IssueDuplicate = "duplicate"
+	IssueRedundant = "redundant"
+	IssueShadowed  = "shadowed"
+)
+
+// Issue is a problem with a rule that does not stop it from being saved, or, for invalid rules, that does
+type Issue struct {
+	Kind     string `json:"kind"`
+	Source   string `json:"source"`
+	SourceID string `json:"sourceId,omitempty"`
+	Rule     string `json:"rule"`
+	// the rule that makes this one redundant or shadows it
+	By      string `json:"by,omitempty"`
+	Message string `json:"message"`
+}
+
+func lintList(source string, id string, raw []string, known []string) []Issue {
+	issues := []Issue{}
+	rules := []Rule{}
+	seen := map[string]bool{}
+
+	issue := func(kind string, rule string, by string, message string) {
+		issues = append(issues, Issue{Kind: kind, Source: source, SourceID: id, Rule: rule, By: by, Message: message})
+	}
+
+	for _, r := range raw {
+		rule, err := Parse(r)
+
+		if err == nil {
+			err = rule.Check(known)
+		}
+
+		if err != nil {
+			message := err.Error()
+
+			// rules for a custom command outlive it when they were saved before commands pruned their rules
+			if !rule.Wildcard && len(rule.Prefix) == 2 && rule.Prefix[0] == "custom" {
+				message = fmt.Sprintf("%q is for a custom command that no longer exists, remove it", r)
+			}
+
+			issue(IssueInvalid, r, "", message)
+			continue
+		}
+
+		key := fmt.Sprint(rule.Negated, rule.Node())
+
+		if seen[key] {
+			issue(IssueDuplicate, r, "", "the rule is listed more than once")
+			continue
+		}
+
+		seen[key] = true
+		rules = append(rules, rule)
+	}
+
+	for _, rule := range rules {
+		for _, other := range rules {
+			if other.Raw == rule.Raw || !other.Covers(rule) {
+				continue
+			}
+
+			if other.Negated && !rule.Negated {
+				issue(IssueShadowed, rule.Raw, other.Raw, fmt.Sprintf("%s never applies because %s denies everything it allows", rule.Raw, other.Raw))
+				break
+			}
+
+			if other.Negated == rule.Negated {
+				issue(IssueRedundant, rule.Raw, other.Raw, fmt.Sprintf("%s is already covered by %s", rule.Raw, other.Raw))
+				break
+			}
+		}
+	}
+
+	return issues
+}
+
+// Lint reports invalid, duplicate, redundant and shadowed rules within each rule list of a bot's permissions
+func Lint(permissions utils.IBotPermissions, known []string) []Issue {
+	issues := []Issue{}
+
+	each(permissions, func(source string, id string, rules []string) {
+		issues = append(issues, lintList(source, id, rules, known)...)
+	})
+
+	// users and roles are kept in maps, so the report is sorted to stay stable
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Source != issues[j].Source {
+			return issues[i].Source < issues[j].Source
+		}
+		return issues[i].SourceID < issues[j].SourceID
+	})
+
+	return issues
+}
@@ -0,0 +1,125 @@
+package permissions
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/astralservices/api/utils"
+)
+
+const (
+	SourceUser         = "user"
+	SourceRole         = "role"
+	SourceDefaultAdmin = "defaultAdminRules"
+	SourceDefaultUser  = "defaultUserRules"
+	SourceNone         = "none"
+)
+
+// Request describes a member of a guild asking to use a permission node, such as the node of a command
+type Request struct {
+	User  string   `json:"user" form:"user"`
+	Roles []string `json:"roles" form:"roles"`
+	// whether the member has Discord's Administrator permission, which selects the default admin rules
+	Admin bool   `json:"admin" form:"admin"`
+	Node  string `json:"node" form:"node"`
+}
+
+// Decision is the outcome of an evaluation and the rule that decided it
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Node    string `json:"node"`
+	Rule    string `json:"rule,omitempty"`
+	Source  string `json:"source"`
+	// the user or role the deciding rule belongs to
+	SourceID string `json:"sourceId,omitempty"`
+}
+
+func (r Request) Validate() error {
+	if r.User == "" {
+		return errors.New("a user is required")
+	}
+
+	if strings.TrimSpace(r.Node) == "" {
+		return errors.New("a permission node is required")
+	}
+
+	if node, err := Parse(r.Node); err != nil || node.Negated || node.Wildcard {
+		return errors.New("the node must be a single permission node such as fun.8ball")
+	}
+
+	return nil
+}
+
+// decide looks for the rules of one level that match node, a matching deny wins over a matching allow
+func decide(rules []string, node string) (Rule, bool) {
+	var allow *Rule
+
+	for _, rule := range ParseAll(rules) {
+		if !rule.Matches(node) {
+			continue
+		}
+
+		if rule.Negated {
+			return rule, true
+		}
+
+		if allow == nil {
+			r := rule
+			allow = &r
+		}
+	}
+
+	if allow != nil {
+		return *allow, true
+	}
+
+	return Rule{}, false
+}
+
+// Evaluate decides whether a member may use a node. The member's own rules come first, then the rules of all
+// of their roles together, then the default rules. The first of those levels with a matching rule decides,
+// and within a level a deny wins over an allow. Anything no rule grants is denied.
+func Evaluate(permissions utils.IBotPermissions, request Request) Decision {
+	found := func(rule Rule, source string, id string) Decision {
+		return Decision{Allowed: !rule.Negated, Node: request.Node, Rule: rule.Raw, Source: source, SourceID: id}
+	}
+
+	if rule, ok := decide(permissions.Users[request.User], request.Node); ok {
+		return found(rule, SourceUser, request.User)
+	}
+
+	var allow *Decision
+
+	for _, role := range request.Roles {
+		rule, ok := decide(permissions.Roles[role], request.Node)
+
+		if !ok {
+			continue
+		}
+
+		if rule.Negated {
+			return found(rule, SourceRole, role)
+		}
+
+		if allow == nil {
+			d := found(rule, SourceRole, role)
+			allow = &d
+		}
+	}
+
+	if allow != nil {
+		return *allow
+	}
+
+	defaults, source := permissions.DefaultUserRules, SourceDefaultUser
+
+	if request.Admin {
+		defaults, source = permissions.DefaultAdminRules, SourceDefaultAdmin
+	}
+
+	if rule, ok := decide(defaults, request.Node); ok {
+		return found(rule, source, "")
+	}
+
+	return Decision{Node: request.Node, Source: SourceNone}
+}
@@ -0,0 +1,107 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/astralservices/api/utils"
+)
+
+func TestEvaluate(t *testing.T) {
+	permissions := utils.IBotPermissions{
+		DefaultAdminRules: []string{"*"},
+		DefaultUserRules:  []string{"fun.*", "-fun.8ball"},
+		Users: map[string][]string{
+			"banned":  {"-*"},
+			"trusted": {"moderation.kick"},
+		},
+		Roles: map[string][]string{
+			"mods":    {"moderation.*"},
+			"muted":   {"-moderation.kick", "-fun.*"},
+			"helpers": {"moderation.kick"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		request  Request
+		allowed  bool
+		source   string
+		sourceID string
+		rule     string
+	}{
+		{
+			name:    "default user rules",
+			request: Request{User: "1", Node: "fun.coinflip"},
+			allowed: true,
+			source:  SourceDefaultUser,
+			rule:    "fun.*",
+		},
+		{
+			name:    "a deny wins over an allow in the same level",
+			request: Request{User: "1", Node: "fun.8ball"},
+			source:  SourceDefaultUser,
+			rule:    "-fun.8ball",
+		},
+		{
+			name:    "admins get the default admin rules",
+			request: Request{User: "1", Admin: true, Node: "moderation.ban"},
+			allowed: true,
+			source:  SourceDefaultAdmin,
+			rule:    "*",
+		},
+		{
+			name:    "nothing grants the node",
+			request: Request{User: "1", Node: "moderation.ban"},
+			source:  SourceNone,
+		},
+		{
+			name:     "role rules come before the defaults",
+			request:  Request{User: "1", Roles: []string{"mods"}, Node: "moderation.ban"},
+			allowed:  true,
+			source:   SourceRole,
+			sourceID: "mods",
+			rule:     "moderation.*",
+		},
+		{
+			name:     "a deny of any role wins over the allows of the others",
+			request:  Request{User: "1", Roles: []string{"helpers", "muted"}, Node: "moderation.kick"},
+			source:   SourceRole,
+			sourceID: "muted",
+			rule:     "-moderation.kick",
+		},
+		{
+			name:     "user rules come before role rules",
+			request:  Request{User: "trusted", Roles: []string{"muted"}, Node: "moderation.kick"},
+			allowed:  true,
+			source:   SourceUser,
+			sourceID: "trusted",
+			rule:     "moderation.kick",
+		},
+		{
+			name:     "user rules come before the default admin rules",
+			request:  Request{User: "banned", Admin: true, Node: "fun.coinflip"},
+			source:   SourceUser,
+			sourceID: "banned",
+			rule:     "-*",
+		},
+		{
+			name:    "roles without a matching rule fall through",
+			request: Request{User: "1", Roles: []string{"helpers"}, Node: "fun.coinflip"},
+			allowed: true,
+			source:  SourceDefaultUser,
+			rule:    "fun.*",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := Evaluate(permissions, test.request)
+
+			want := Decision{Allowed: test.allowed, Node: test.request.Node, Rule: test.rule, Source: test.source, SourceID: test.sourceID}
+
+			if decision != want {
+				t.Errorf("decision = %+v, want %+v", decision, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package permissions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/astralservices/api/commands"
+	"github.com/astralservices/api/reactionroles"
+	"github.com/astralservices/api/utils"
+)
+
+// a node is made of dot separated segments, the last of which may be a * wildcard
+var segment = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Rule is a parsed permission rule such as fun.8ball, moderation.* or -moderation.ban
+type Rule struct {
+	Raw     string
+	Negated bool
+	// the segments before the wildcard, or every segment of an exact rule
+	Prefix   []string
+	Wildcard bool
+}
+
+func Parse(raw string) (Rule, error) {
+	rule := Rule{Raw: raw}
+	node := strings.TrimSpace(raw)
+
+	if strings.HasPrefix(node, "-") {
+		rule.Negated = true
+		node = node[1:]
+	}
+
+	if node == "" {
+		return rule, fmt.Errorf("%q is empty", raw)
+	}
+
+	segments := strings.Split(node, ".")
+
+	for i, s := range segments {
+		if s == "*" && i == len(segments)-1 {
+			rule.Wildcard = true
+			break
+		}
+
+		if !segment.MatchString(s) {
+			return rule, fmt.Errorf("%q is not a valid node, segments are lowercase letters, numbers, dashes or underscores and only the last may be *", raw)
+		}
+
+		rule.Prefix = append(rule.Prefix, s)
+	}
+
+	return rule, nil
+}
+
+// Node returns the rule without its negation
+func (r Rule) Node() string {
+	node := strings.Join(r.Prefix, ".")
+
+	if r.Wildcard {
+		if node == "" {
+			return "*"
+		}
+		return node + ".*"
+	}
+
+	return node
+}
+
+func (r Rule) Matches(node string) bool {
+	segments := strings.Split(node, ".")
+
+	if r.Wildcard {
+		if len(segments) <= len(r.Prefix) {
+			return false
+		}
+	} else if len(segments) != len(r.Prefix) {
+		return false
+	}
+
+	for i, s := range r.Prefix {
+		if segments[i] != s {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Covers reports whether r matches every node other matches
+func (r Rule) Covers(other Rule) bool {
+	if !r.Wildcard {
+		return !other.Wildcard && r.Node() == other.Node()
+	}
+
+	if len(other.Prefix) < len(r.Prefix) || (len(other.Prefix) == len(r.Prefix) && !other.Wildcard) {
+		return false
+	}
+
+	for i, s := range r.Prefix {
+		if other.Prefix[i] != s {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nodes that are not commands, such as who may manage reaction role menus from Discord
+var Nodes = []string{reactionroles.ManageNode}
+
+// KnownNodes returns every node a bot's rules can refer to, the built-in commands, the bot's custom commands
+// and the other nodes
+func KnownNodes(botCommands []utils.IBotCommand) []string {
+	nodes := append(commands.Permissions(), Nodes...)
+
+	for _, command := range botCommands {
+		if command.Custom {
+			nodes = append(nodes, "custom."+command.ID)
+		}
+	}
+
+	return nodes
+}
+
+// Check rejects rules that cannot match any known node
+func (r Rule) Check(known []string) error {
+	for _, node := range known {
+		if r.Matches(node) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%q does not match any command or permission node", r.Raw)
+}
+
+// ParseAll parses a list of rules, skipping the ones that do not parse
+func ParseAll(raw []string) []Rule {
+	rules := []Rule{}
+
+	for _, r := range raw {
+		if rule, err := Parse(r); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+func each(permissions utils.IBotPermissions, fn func(source string, id string, rules []string)) {
+	fn(SourceDefaultAdmin, "", permissions.DefaultAdminRules)
+	fn(SourceDefaultUser, "", permissions.DefaultUserRules)
+
+	for user, rules := range permissions.Users {
+		fn(SourceUser, user, rules)
+	}
+
+	for role, rules := range permissions.Roles {
+		fn(SourceRole, role, rules)
+	}
+}
+
+func listKey(source string, id string) string {
+	return source + " " + id
+}
+
+// Validate checks the rules of a bot's permissions against the known nodes. Rules that were already saved in the
+// same list are not checked again, so rules saved before nodes were checked, or left behind by a deleted command,
+// do not block unrelated changes. Lint still reports them.
+func Validate(previous utils.IBotPermissions, permissions utils.IBotPermissions, known []string) error {
+	saved := map[string]map[string]bool{}
+
+	each(previous, func(source string, id string, rules []string) {
+		set := map[string]bool{}
+
+		for _, raw := range rules {
+			set[raw] = true
+		}
+
+		saved[listKey(source, id)] = set
+	})
+
+	var err error
+
+	each(permissions, func(source string, id string, rules []string) {
+		if err != nil {
+			return
+		}
+
+		for _, raw := range rules {
+			if saved[listKey(source, id)][raw] {
+				continue
+			}
+
+			rule, parseErr := Parse(raw)
+
+			if parseErr == nil {
+				parseErr = rule.Check(known)
+			}
+
+			if parseErr != nil {
+				where := source
+
+				if id != "" {
+					where += " " + id
+				}
+
+				err = fmt.Errorf("%s: %w", where, parseErr)
+				return
+			}
+		}
+	})
+
+	return err
+}
+
+// Clone copies a bot's permissions, so the copy can be changed and validated against the original
+func Clone(permissions utils.IBotPermissions) utils.IBotPermissions {
+	clone := func(rules map[string][]string) map[string][]string {
+		cloned := make(map[string][]string, len(rules))
+
+		for id, list := range rules {
+			cloned[id] = append([]string(nil), list...)
+		}
+
+		return cloned
+	}
+
+	return utils.IBotPermissions{
+		DefaultAdminRules: append([]string(nil), permissions.DefaultAdminRules...),
+		DefaultUserRules:  append([]string(nil), permissions.DefaultUserRules...),
+		Users:             clone(permissions.Users),
+		Roles:             clone(permissions.Roles),
+	}
+}
+
+// Prune removes the rules for exactly node, allowing or denying it, from every list. Wildcards are kept, they may
+// still match other nodes. It reports whether anything was removed.
+func Prune(permissions utils.IBotPermissions, node string) (utils.IBotPermissions, bool) {
+	removed := false
+
+	prune := func(rules []string) []string {
+		kept := []string{}
+
+		for _, raw := range rules {
+			if rule, err := Parse(raw); err == nil && !rule.Wildcard && rule.Node() == node {
+				removed = true
+				continue
+			}
+
+			kept = append(kept, raw)
+		}
+
+		return kept
+	}
+
+	pruned := utils.IBotPermissions{
+		DefaultAdminRules: prune(permissions.DefaultAdminRules),
+		DefaultUserRules:  prune(permissions.DefaultUserRules),
+		Users:             map[string][]string{},
+		Roles:             map[string][]string{},
+	}
+
+	for user, rules := range permissions.Users {
+		pruned.Users[user] = prune(rules)
+	}
+
+	for role, rules := range permissions.Roles {
+		pruned.Roles[role] = prune(rules)
+	}
+
+	return pruned, removed
+}
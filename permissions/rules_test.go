@@ -0,0 +1,86 @@
+package permissions
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/astralservices/api/utils"
+)
+
+var known = []string{"fun.8ball", "moderation.ban", "moderation.kick"}
+
+func TestValidate(t *testing.T) {
+	previous := utils.IBotPermissions{
+		DefaultUserRules: []string{"fun.*"},
+		Users:            map[string][]string{"1": {"moderation.ban"}},
+		// saved before nodes were checked
+		Roles: map[string][]string{"2": {"music.play"}},
+	}
+
+	tests := []struct {
+		name   string
+		change func(permissions *utils.IBotPermissions)
+		valid  bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(permissions *utils.IBotPermissions) {},
+			valid:  true,
+		},
+		{
+			name: "known rule added to a role",
+			change: func(permissions *utils.IBotPermissions) {
+				permissions.Roles["2"] = append(permissions.Roles["2"], "-moderation.*")
+			},
+			valid: true,
+		},
+		{
+			name: "unknown rule added to a role",
+			change: func(permissions *utils.IBotPermissions) {
+				permissions.Roles["2"] = append(permissions.Roles["2"], "music.skip")
+			},
+		},
+		{
+			name: "unknown rule for a new user",
+			change: func(permissions *utils.IBotPermissions) {
+				permissions.Users["3"] = []string{"music.play"}
+			},
+		},
+		{
+			name: "saved rule moved to another list",
+			change: func(permissions *utils.IBotPermissions) {
+				permissions.Roles["4"] = []string{"music.play"}
+			},
+		},
+		{
+			name: "invalid rule in the defaults",
+			change: func(permissions *utils.IBotPermissions) {
+				permissions.DefaultAdminRules = []string{"Moderation.Ban"}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := Clone(previous)
+			permissions := Clone(previous)
+
+			test.change(&permissions)
+
+			err := Validate(previous, permissions, known)
+
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !test.valid && err == nil {
+				t.Error("expected an error")
+			}
+
+			// the previous rules are checked as their own value, changing the copy must not change them
+			if !reflect.DeepEqual(previous, before) {
+				t.Errorf("previous = %v, want %v", previous, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package presence
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	// Discord rate limits presence updates, so activities rotate at most once a minute
+	MinInterval = 60
+	// the longest an activity name can be
+	MaxNameLength = 128
+)
+
+var ActivityTypes = []string{"PLAYING", "STREAMING", "LISTENING", "WATCHING", "COMPETING"}
+
+var Statuses = []string{"online", "idle", "dnd", "invisible"}
+
+// the variables activity names can use, with an example of each
+var TemplateVariables = map[string]any{
+	"members":  1234,
+	"commands": 12,
+	"prefix":   "!",
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseClock parses an HH:MM time of day to minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day, use HH:MM", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// errNameLength is returned with the rendered name when it is longer than Discord allows
+var errNameLength = fmt.Errorf("activity names must render to at most %d characters", MaxNameLength)
+
+func render(name string, variables map[string]any) (string, error) {
+	tmpl, err := template.New("activity").Option("missingkey=error").Parse(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+
+	if err = tmpl.Execute(&out, variables); err != nil {
+		return "", err
+	}
+
+	if utf8.RuneCount(out.Bytes()) > MaxNameLength {
+		return out.String(), errNameLength
+	}
+
+	return out.String(), nil
+}
+
+// truncate cuts a name down to the characters Discord allows
+func truncate(name string) string {
+	if utf8.RuneCountInString(name) <= MaxNameLength {
+		return name
+	}
+
+	return string([]rune(name)[:MaxNameLength])
+}
+
+// validateActivities renders the names with the example variables and the bot's own prefix
+func validateActivities(activities []utils.IBotActivity, prefix string) error {
+	variables := map[string]any{}
+
+	for key, value := range TemplateVariables {
+		variables[key] = value
+	}
+
+	if prefix != "" {
+		variables["prefix"] = prefix
+	}
+
+	for _, activity := range activities {
+		if !contains(ActivityTypes, activity.Type) {
+			return fmt.Errorf("activity types must be one of %s", strings.Join(ActivityTypes, ", "))
+		}
+
+		if activity.Name == "" || utf8.RuneCountInString(activity.Name) > MaxNameLength {
+			return fmt.Errorf("activity names must be 1 to %d characters", MaxNameLength)
+		}
+
+		if _, err := render(activity.Name, variables); errors.Is(err, errNameLength) {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("invalid activity name %q: %w", activity.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the status, activities and schedules of a bot's settings
+func Validate(settings utils.IBotSettings) error {
+	if settings.Status != "" && !contains(Statuses, settings.Status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(Statuses, ", "))
+	}
+
+	// bots without an interval rotate as often as they are allowed to
+	if settings.ActivityInterval != 0 && settings.ActivityInterval < MinInterval {
+		return fmt.Errorf("activities must rotate at most once every %d seconds", MinInterval)
+	}
+
+	if err := validateActivities(settings.Activities, settings.Prefix); err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+
+	for _, schedule := range settings.ActivitySchedules {
+		if schedule.Name == "" || names[schedule.Name] {
+			return errors.New("schedules need a unique name")
+		}
+
+		names[schedule.Name] = true
+
+		for _, day := range schedule.Days {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("%s: days must be between 0 (Sunday) and 6 (Saturday)", schedule.Name)
+			}
+		}
+
+		start, err := parseClock(schedule.Start)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", schedule.Name, err)
+		}
+
+		end, err := parseClock(schedule.End)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", schedule.Name, err)
+		}
+
+		if start == end {
+			return fmt.Errorf("%s: start and end must differ", schedule.Name)
+		}
+
+		if _, err = time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("%s: unknown time zone %q", schedule.Name, schedule.Timezone)
+		}
+
+		if schedule.Status != "" && !contains(Statuses, schedule.Status) {
+			return fmt.Errorf("%s: status must be one of %s", schedule.Name, strings.Join(Statuses, ", "))
+		}
+
+		if err = validateActivities(schedule.Activities, settings.Prefix); err != nil {
+			return fmt.Errorf("%s: %w", schedule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// activeAt reports whether a schedule is active at a moment, in the schedule's time zone
+func activeAt(schedule utils.IBotActivitySchedule, now time.Time) bool {
+	location, err := time.LoadLocation(schedule.Timezone)
+
+	if err != nil {
+		return false
+	}
+
+	start, startErr := parseClock(schedule.Start)
+	end, endErr := parseClock(schedule.End)
+
+	if startErr != nil || endErr != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	day := int(local.Weekday())
+
+	// windows past midnight belong to the day they started on
+	if end < start && minute < end {
+		day = (day + 6) % 7
+	}
+
+	if len(schedule.Days) > 0 {
+		found := false
+
+		for _, d := range schedule.Days {
+			found = found || d == day
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if start < end {
+		return minute >= start && minute < end
+	}
+
+	return minute >= start || minute < end
+}
+
+// Current returns the status and activities a bot should show at a moment, and the name of the schedule
+// they come from, empty for the defaults
+func Current(settings utils.IBotSettings, now time.Time) (string, []utils.IBotActivity, string) {
+	for _, schedule := range settings.ActivitySchedules {
+		if !activeAt(schedule, now) {
+			continue
+		}
+
+		status, activities := schedule.Status, schedule.Activities
+
+		if status == "" {
+			status = settings.Status
+		}
+
+		if len(activities) == 0 {
+			activities = settings.Activities
+		}
+
+		return status, activities, schedule.Name
+	}
+
+	return settings.Status, settings.Activities, ""
+}
+
+// Due reports whether a bot's presence should be updated
+func Due(settings utils.IBotSettings, now time.Time) bool {
+	_, activities, schedule := Current(settings, now)
+
+	if schedule != settings.ActivitySchedule || settings.ActivityUpdatedAt == nil {
+		return true
+	}
+
+	// a single activity never rotates, it is only pushed again when the schedule changes
+	if len(activities) < 2 {
+		return false
+	}
+
+	interval := settings.ActivityInterval
+
+	if interval < MinInterval {
+		interval = MinInterval
+	}
+
+	return !now.Before(settings.ActivityUpdatedAt.Add(time.Duration(interval) * time.Second))
+}
+
+// Next picks the index of the activity to show after the current one
+func Next(settings utils.IBotSettings, activities []utils.IBotActivity, schedule string) int {
+	// activities of a different schedule start from the beginning
+	if schedule != settings.ActivitySchedule || settings.ActivityUpdatedAt == nil {
+		if settings.RandomizeActivities && len(activities) > 0 {
+			return rand.Intn(len(activities))
+		}
+
+		return 0
+	}
+
+	// the list may have shrunk since the current activity was picked
+	if len(activities) < 2 || settings.CurrentActivity >= len(activities) {
+		return 0
+	}
+
+	if settings.RandomizeActivities {
+		// never show the same activity twice in a row
+		next := rand.Intn(len(activities) - 1)
+
+		if next >= settings.CurrentActivity {
+			next++
+		}
+
+		return next
+	}
+
+	return (settings.CurrentActivity + 1) % len(activities)
+}
+
+// Variables returns the values activity names of a bot can use
+func Variables(database *supabase.Client, bot utils.IBot) (map[string]any, error) {
+	var samples []utils.IBotAnalytics
+
+	query := database.DB.From("bot_analytics").Select("members").Limit(1).Eq("bot", *bot.ID)
+	query.Filter("order", "timestamp", "desc")
+
+	if err := query.Execute(&samples); err != nil {
+		return nil, err
+	}
+
+	variables := map[string]any{
+		"members":  0,
+		"commands": 0,
+		"prefix":   bot.Settings.Prefix,
+	}
+
+	if len(samples) > 0 {
+		variables["members"] = samples[0].Members
+	}
+
+	enabled := 0
+
+	for _, command := range bot.Commands {
+		if command.Enabled {
+			enabled++
+		}
+	}
+
+	variables["commands"] = enabled
+
+	return variables, nil
+}
+
+type Activity struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Payload is what runners receive with a presence.update command
+type Payload struct {
+	Status   string    `json:"status"`
+	Activity *Activity `json:"activity,omitempty"`
+	Schedule string    `json:"schedule,omitempty"`
+}
+
+// Rotate advances a bot's activity and queues the new presence for its runner
+func Rotate(database *supabase.Client, bot utils.IBot, now time.Time) error {
+	settings := bot.Settings
+
+	status, activities, schedule := Current(settings, now)
+
+	payload := Payload{Status: status, Schedule: schedule}
+
+	settings.CurrentActivity = Next(settings, activities, schedule)
+
+	if len(activities) > 0 {
+		activity := activities[settings.CurrentActivity]
+
+		variables, err := Variables(database, bot)
+
+		if err != nil {
+			return err
+		}
+
+		name, err := render(activity.Name, variables)
+
+		// names are checked when they are saved, this only happens for settings saved before that. Names can
+		// still render longer than they did with the example variables, those are cut down.
+		if err != nil && !errors.Is(err, errNameLength) {
+			name = activity.Name
+		}
+
+		payload.Activity = &Activity{Name: truncate(name), Type: activity.Type}
+	}
+
+	id := fmt.Sprintf("presence-%s-%d", *bot.ID, now.Unix())
+
+	if err := utils.QueueRunnerCommand(database, *bot.ID, id, "presence.update", payload); err != nil {
+		return err
+	}
+
+	// only the rotation fields are written, the rest of the settings may have been saved since the bot was read
+	return utils.Rpc(database, "set_bot_rotation", map[string]interface{}{
+		"p_bot":              *bot.ID,
+		"p_current_activity": settings.CurrentActivity,
+		"p_schedule":         schedule,
+		"p_updated_at":       now.UTC(),
+	}, nil)
+}
@@ -0,0 +1,193 @@
+package reactionroles
+
+import (
+	"time"
+
+	"github.com/astralservices/api/utils"
+	"github.com/nedpals/supabase-go"
+)
+
+// Binding is what a runner does when a member picks an entry of a menu
+type Binding struct {
+	Menu string `json:"menu"`
+	Mode string `json:"mode"`
+	Role string `json:"role"`
+	// roles to take away when the role is given, the other roles of unique menus
+	Remove []string `json:"remove"`
+}
+
+type ButtonEmoji struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Animated bool   `json:"animated,omitempty"`
+}
+
+// Button is a button component as Discord takes it
+type Button struct {
+	Type     int          `json:"type"`
+	Style    int          `json:"style"`
+	Label    string       `json:"label,omitempty"`
+	Emoji    *ButtonEmoji `json:"emoji,omitempty"`
+	CustomID string       `json:"custom_id"`
+}
+
+// ActionRow is a row of up to five buttons
+type ActionRow struct {
+	Type       int      `json:"type"`
+	Components []Button `json:"components"`
+}
+
+// CompiledMenu is a menu ready to be posted
+type CompiledMenu struct {
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	// the posted message, empty until the runner has posted the menu
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	// the emojis to react with, in the form the reactions endpoint takes
+	Reactions  []string    `json:"reactions"`
+	Components []ActionRow `json:"components"`
+}
+
+// Lookup is everything a runner needs to run a bot's menus
+type Lookup struct {
+	Menus []CompiledMenu `json:"menus"`
+	// reaction bindings by message ID and then by emoji, see EmojiKey
+	Reactions map[string]map[string]Binding `json:"reactions"`
+	// button bindings by custom ID
+	Buttons map[string]Binding `json:"buttons"`
+}
+
+// CustomID is the custom ID of a menu's button for a role
+func CustomID(menu string, role string) string {
+	return "rr:" + menu + ":" + role
+}
+
+// reaction returns an emoji in the form the reactions endpoint takes, name:id for custom emojis
+func reaction(emoji string) string {
+	if match := customEmoji.FindStringSubmatch(emoji); match != nil {
+		return match[2] + ":" + match[3]
+	}
+
+	return emoji
+}
+
+func buttonEmoji(emoji string) *ButtonEmoji {
+	if emoji == "" {
+		return nil
+	}
+
+	if match := customEmoji.FindStringSubmatch(emoji); match != nil {
+		return &ButtonEmoji{ID: match[3], Name: match[2], Animated: match[1] == "a"}
+	}
+
+	return &ButtonEmoji{Name: emoji}
+}
+
+func bindings(menu Menu, role string) Binding {
+	binding := Binding{Menu: menu.ID, Mode: menu.Mode, Role: role, Remove: []string{}}
+
+	if menu.Mode == ModeUnique {
+		for _, entry := range menu.Entries {
+			if entry.Role != role {
+				binding.Remove = append(binding.Remove, entry.Role)
+			}
+		}
+	}
+
+	return binding
+}
+
+// Compile builds the lookup of a bot's menus, messages are the menus the runner has posted
+func Compile(config Config, messages []utils.IReactionRoleMessage) Lookup {
+	lookup := Lookup{Menus: []CompiledMenu{}, Reactions: map[string]map[string]Binding{}, Buttons: map[string]Binding{}}
+
+	posted := map[string]utils.IReactionRoleMessage{}
+
+	for _, message := range messages {
+		posted[message.Menu] = message
+	}
+
+	for _, menu := range config.Menus {
+		compiled := CompiledMenu{ID: menu.ID, Channel: menu.Channel, Type: menu.Type, Content: menu.Content, Reactions: []string{}, Components: []ActionRow{}}
+
+		// a menu moved to another channel has to be posted again
+		if message, ok := posted[menu.ID]; ok && message.Channel == menu.Channel {
+			compiled.Message = message.Message
+		}
+
+		switch menu.Type {
+		case TypeReaction:
+			reactions := map[string]Binding{}
+
+			for _, entry := range menu.Entries {
+				compiled.Reactions = append(compiled.Reactions, reaction(entry.Emoji))
+				reactions[EmojiKey(entry.Emoji)] = bindings(menu, entry.Role)
+			}
+
+			// reactions can only be told apart by the message they are on
+			if compiled.Message != "" {
+				lookup.Reactions[compiled.Message] = reactions
+			}
+		case TypeButton:
+			for i, entry := range menu.Entries {
+				if i%5 == 0 {
+					compiled.Components = append(compiled.Components, ActionRow{Type: 1, Components: []Button{}})
+				}
+
+				style := Styles[entry.Style]
+
+				if style == 0 {
+					style = Styles["secondary"]
+				}
+
+				row := &compiled.Components[len(compiled.Components)-1]
+				row.Components = append(row.Components, Button{
+					Type:     2,
+					Style:    style,
+					Label:    entry.Label,
+					Emoji:    buttonEmoji(entry.Emoji),
+					CustomID: CustomID(menu.ID, entry.Role),
+				})
+
+				lookup.Buttons[CustomID(menu.ID, entry.Role)] = bindings(menu, entry.Role)
+			}
+		}
+
+		lookup.Menus = append(lookup.Menus, compiled)
+	}
+
+	return lookup
+}
+
+// Messages returns the menus a bot's runner has posted
+func Messages(database *supabase.Client, bot string) ([]utils.IReactionRoleMessage, error) {
+	var messages []utils.IReactionRoleMessage
+
+	err := database.DB.From("reaction_role_messages").Select("*").Eq("bot", bot).Execute(&messages)
+
+	return messages, err
+}
+
+// SetMessage records the message a runner posted a menu as, replacing the one it was posted as before
+func SetMessage(database *supabase.Client, bot string, menu string, channel string, message string) (utils.IReactionRoleMessage, error) {
+	now := time.Now().UTC()
+
+	var saved []utils.IReactionRoleMessage
+
+	// bot and menu are the table's primary key, so posting a menu again replaces its row
+	err := database.DB.From("reaction_role_messages").Upsert(utils.IReactionRoleMessage{
+		Bot:       bot,
+		Menu:      menu,
+		Channel:   channel,
+		Message:   message,
+		UpdatedAt: &now,
+	}).Execute(&saved)
+
+	if err != nil || len(saved) == 0 {
+		return utils.IReactionRoleMessage{}, err
+	}
+
+	return saved[0], nil
+}
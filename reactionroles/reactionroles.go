@@ -0,0 +1,273 @@
+package reactionroles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	TypeReaction = "reaction"
+	TypeButton   = "button"
+)
+
+const (
+	// members can take and give back any of the menu's roles
+	ModeToggle = "toggle"
+	// members hold at most one of the menu's roles, picking one removes the others
+	ModeUnique = "unique"
+	// roles are only ever given, such as a role for accepting the rules
+	ModeVerify = "verify"
+)
+
+// Discord's limits for the parts of a menu
+const (
+	// five rows of five buttons
+	MaxButtons = 25
+	// the most different reactions a message can have
+	MaxReactions     = 20
+	MaxContentLength = 2000
+	MaxLabelLength   = 80
+	// menus a bot can have
+	MaxMenus = 25
+)
+
+// ManageNode is the permission node for managing menus from Discord
+const ManageNode = "reactionroles.manage"
+
+var (
+	Types  = []string{TypeReaction, TypeButton}
+	Modes  = []string{ModeToggle, ModeUnique, ModeVerify}
+	Styles = map[string]int{"primary": 1, "secondary": 2, "success": 3, "danger": 4}
+)
+
+var (
+	menuID      = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+	customEmoji = regexp.MustCompile(`^<(a?):([A-Za-z0-9_]{2,32}):(\d{17,20})>$`)
+)
+
+type Entry struct {
+	// a unicode emoji or a custom emoji such as <:name:id>
+	Emoji string `json:"emoji"`
+	// the text of a button, unused by reaction menus
+	Label string `json:"label"`
+	Style string `json:"style"`
+	Role  string `json:"role"`
+}
+
+type Menu struct {
+	ID      string  `json:"id"`
+	Channel string  `json:"channel"`
+	Content string  `json:"content"`
+	Type    string  `json:"type"`
+	Mode    string  `json:"mode"`
+	Entries []Entry `json:"entries"`
+}
+
+// Config is the options of the reaction roles module
+type Config struct {
+	Menus []Menu `json:"menus"`
+}
+
+// ParseConfig reads the reaction roles module's options, which have already been checked against its schema
+func ParseConfig(options map[string]any) (Config, error) {
+	var config Config
+
+	data, err := json.Marshal(options)
+
+	if err != nil {
+		return config, err
+	}
+
+	return config, json.Unmarshal(data, &config)
+}
+
+// Options turns a config back into module options
+func (c Config) Options() (map[string]any, error) {
+	var options map[string]any
+
+	data, err := json.Marshal(c)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return options, json.Unmarshal(data, &options)
+}
+
+// Find returns the index of a menu, -1 if there is none with the ID
+func (c Config) Find(id string) int {
+	for i, menu := range c.Menus {
+		if menu.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// EmojiKey returns what a runner sees of an emoji, the ID of custom emojis and the emoji itself otherwise
+func EmojiKey(emoji string) string {
+	if match := customEmoji.FindStringSubmatch(emoji); match != nil {
+		return match[3]
+	}
+
+	return emoji
+}
+
+func validEmoji(emoji string) bool {
+	if customEmoji.MatchString(emoji) {
+		return true
+	}
+
+	if emoji == "" || len(emoji) > 32 {
+		return false
+	}
+
+	symbol := false
+
+	// anything with plain text in it is a mistyped custom emoji or not an emoji at all
+	for _, c := range emoji {
+		if unicode.IsLetter(c) || unicode.IsSpace(c) || strings.ContainsRune("<>:", c) {
+			return false
+		}
+
+		if c > unicode.MaxASCII {
+			symbol = true
+		}
+	}
+
+	return symbol
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks a menu against Discord's limits
+func Validate(menu Menu) error {
+	if !menuID.MatchString(menu.ID) {
+		return errors.New("menu IDs must be 1 to 32 lowercase letters, numbers, dashes or underscores")
+	}
+
+	if menu.Channel == "" {
+		return fmt.Errorf("%s: a channel is required", menu.ID)
+	}
+
+	if strings.TrimSpace(menu.Content) == "" {
+		return fmt.Errorf("%s: the menu needs a message", menu.ID)
+	}
+
+	if len(menu.Content) > MaxContentLength {
+		return fmt.Errorf("%s: messages must be at most %d characters", menu.ID, MaxContentLength)
+	}
+
+	if !contains(Types, menu.Type) {
+		return fmt.Errorf("%s: type must be one of %s", menu.ID, strings.Join(Types, ", "))
+	}
+
+	if !contains(Modes, menu.Mode) {
+		return fmt.Errorf("%s: mode must be one of %s", menu.ID, strings.Join(Modes, ", "))
+	}
+
+	if len(menu.Entries) == 0 {
+		return fmt.Errorf("%s: the menu needs at least one role", menu.ID)
+	}
+
+	if menu.Type == TypeReaction && len(menu.Entries) > MaxReactions {
+		return fmt.Errorf("%s: reaction menus can have at most %d reactions", menu.ID, MaxReactions)
+	}
+
+	if menu.Type == TypeButton && len(menu.Entries) > MaxButtons {
+		return fmt.Errorf("%s: button menus can have at most %d buttons", menu.ID, MaxButtons)
+	}
+
+	roles := map[string]bool{}
+	emojis := map[string]bool{}
+
+	for i, entry := range menu.Entries {
+		where := fmt.Sprintf("%s.entries[%d]", menu.ID, i)
+
+		if entry.Role == "" {
+			return fmt.Errorf("%s: a role is required", where)
+		}
+
+		// buttons are identified by their role, and a reaction giving the same role twice is a mistake either way
+		if roles[entry.Role] {
+			return fmt.Errorf("%s: the role %s is already in the menu", where, entry.Role)
+		}
+
+		roles[entry.Role] = true
+
+		if entry.Emoji != "" && !validEmoji(entry.Emoji) {
+			return fmt.Errorf("%s: %q is not an emoji", where, entry.Emoji)
+		}
+
+		switch menu.Type {
+		case TypeReaction:
+			if entry.Emoji == "" {
+				return fmt.Errorf("%s: reactions need an emoji", where)
+			}
+
+			key := EmojiKey(entry.Emoji)
+
+			if emojis[key] {
+				return fmt.Errorf("%s: the emoji %s is already in the menu", where, entry.Emoji)
+			}
+
+			emojis[key] = true
+		case TypeButton:
+			if entry.Label == "" && entry.Emoji == "" {
+				return fmt.Errorf("%s: buttons need a label or an emoji", where)
+			}
+
+			if len(entry.Label) > MaxLabelLength {
+				return fmt.Errorf("%s: labels must be at most %d characters", where, MaxLabelLength)
+			}
+
+			if _, ok := Styles[entry.Style]; entry.Style != "" && !ok {
+				return fmt.Errorf("%s: unknown button style %q", where, entry.Style)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check validates every menu of the reaction roles module's options
+func Check(options map[string]any) error {
+	config, err := ParseConfig(options)
+
+	if err != nil {
+		return err
+	}
+
+	if len(config.Menus) > MaxMenus {
+		return fmt.Errorf("bots can have at most %d menus", MaxMenus)
+	}
+
+	ids := map[string]bool{}
+
+	for _, menu := range config.Menus {
+		if ids[menu.ID] {
+			return fmt.Errorf("there is more than one menu called %s", menu.ID)
+		}
+
+		ids[menu.ID] = true
+
+		if err = Validate(menu); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
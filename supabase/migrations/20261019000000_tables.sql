@@ -0,0 +1,228 @@
+-- The tables and columns the API uses that the original schema does not have. Functions in the later migrations
+-- are checked against these when they are created, so this runs first.
+
+-- Analytics rolled up per bot and hour or day, ingestion and downsampling fold samples into them
+create table if not exists bot_analytics_hourly (
+  bot uuid not null references bots (id) on delete cascade,
+  bucket timestamptz not null,
+  members int not null default 0,
+  -- the time of the sample members was taken from, the latest sample wins
+  members_at timestamptz not null,
+  messages int not null default 0,
+  commands jsonb not null default '{}'::jsonb,
+  samples int not null default 0,
+  primary key (bot, bucket)
+);
+
+create table if not exists bot_analytics_daily (
+  bot uuid not null references bots (id) on delete cascade,
+  bucket timestamptz not null,
+  members int not null default 0,
+  members_at timestamptz not null,
+  messages int not null default 0,
+  commands jsonb not null default '{}'::jsonb,
+  samples int not null default 0,
+  primary key (bot, bucket)
+);
+
+alter table plans add column if not exists "analyticsRetention" int not null default 0;
+alter table plans add column if not exists "versionRetention" int not null default 0;
+
+-- Every saved config of a bot, numbered per bot. save_bot numbers versions from the latest, the key stops two saves
+-- from taking the same number.
+create table if not exists bot_versions (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  bot uuid not null references bots (id) on delete cascade,
+  version int not null,
+  -- empty for the config recorded before version history existed
+  author text not null default '',
+  summary text not null default '',
+  config jsonb not null,
+  diff jsonb not null default '[]'::jsonb,
+  unique (bot, version)
+);
+
+alter table profiles add column if not exists sessions_revoked_at timestamptz;
+
+alter table blacklist add column if not exists lifted boolean not null default false;
+alter table blacklist add column if not exists lifted_at timestamptz;
+-- a staff profile, or system for entries lifted by their expiry
+alter table blacklist add column if not exists lifted_by text;
+
+create table if not exists blacklist_appeals (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  blacklist bigint not null references blacklist (id) on delete cascade,
+  "user" uuid not null,
+  statement text not null,
+  status text not null default 'pending',
+  messages jsonb not null default '[]'::jsonb,
+  reviewer text,
+  reviewed_at timestamptz
+);
+
+create index if not exists blacklist_appeals_blacklist on blacklist_appeals (blacklist);
+
+create table if not exists alt_detections (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  "user" uuid not null,
+  blacklist bigint not null references blacklist (id) on delete cascade,
+  matched_user text not null,
+  trigger text not null,
+  score double precision not null,
+  matches jsonb not null default '[]'::jsonb,
+  action text not null,
+  -- the blacklist entry the detection created, if it created one
+  entry bigint references blacklist (id) on delete set null,
+  status text not null default 'pending',
+  reviewer text,
+  reviewed_at timestamptz
+);
+
+create table if not exists audit_logs (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  actor text not null,
+  action text not null,
+  target_type text not null,
+  target text not null,
+  data jsonb,
+  ip text not null default '',
+  method text not null default '',
+  path text not null default ''
+);
+
+create index if not exists audit_logs_created_at on audit_logs (created_at);
+
+create table if not exists impersonation_sessions (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  staff text not null,
+  "user" uuid not null,
+  reason text not null,
+  expires_at timestamptz not null,
+  ended_at timestamptz
+);
+
+alter table moderation_actions add column if not exists expired boolean not null default false;
+alter table moderation_actions add column if not exists revoked boolean not null default false;
+alter table moderation_actions add column if not exists revoked_at timestamptz;
+-- the Discord ID of the member who revoked it
+alter table moderation_actions add column if not exists revoked_by text;
+alter table moderation_actions add column if not exists revoke_reason text;
+
+create table if not exists bot_announcements (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  bot uuid not null references bots (id) on delete cascade,
+  name text not null,
+  enabled boolean not null default true,
+  channel text not null,
+  content text not null default '',
+  embed jsonb,
+  schedule_type text not null,
+  schedule text not null,
+  timezone text not null default 'UTC',
+  start_at timestamptz,
+  -- null once the schedule has no more runs
+  next_run_at timestamptz,
+  last_run_at timestamptz,
+  created_by text not null
+);
+
+create index if not exists bot_announcements_due on bot_announcements (next_run_at) where enabled;
+
+-- Runs of announcements by the ID of the runner command that sends them, so a run is only queued once
+create table if not exists bot_announcement_runs (
+  id text primary key,
+  created_at timestamptz not null default now(),
+  announcement uuid not null references bot_announcements (id) on delete cascade,
+  bot uuid not null references bots (id) on delete cascade,
+  scheduled_for timestamptz not null,
+  status text not null default 'pending',
+  error text,
+  completed_at timestamptz
+);
+
+create table if not exists reaction_role_messages (
+  bot uuid not null references bots (id) on delete cascade,
+  menu text not null,
+  channel text not null,
+  message text not null,
+  updated_at timestamptz not null default now(),
+  primary key (bot, menu)
+);
+
+-- Commands queued for a bot's runner. The ID is derived from what the command is for, so queueing it twice fails
+-- with a unique violation that QueueRunnerCommand treats as already queued.
+create table if not exists runner_commands (
+  id text primary key,
+  created_at timestamptz not null default now(),
+  bot uuid not null references bots (id) on delete cascade,
+  type text not null,
+  payload jsonb,
+  status text not null default 'pending',
+  attempts int not null default 0,
+  delivered_at timestamptz,
+  completed_at timestamptz,
+  error text
+);
+
+create index if not exists runner_commands_claimable on runner_commands (bot, created_at) where status in ('pending', 'delivered');
+
+create table if not exists moderation_cases (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  bot uuid not null references bots (id) on delete cascade,
+  "user" text not null,
+  title text not null,
+  status text not null default 'open',
+  assignee text,
+  -- append_moderation_case appends to these in the update itself
+  actions jsonb not null default '[]'::jsonb,
+  notes jsonb not null default '[]'::jsonb,
+  evidence jsonb not null default '[]'::jsonb
+);
+
+create index if not exists moderation_cases_bot on moderation_cases (bot);
+
+create table if not exists moderation_case_events (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  "case" uuid not null references moderation_cases (id) on delete cascade,
+  type text not null,
+  actor text not null,
+  data jsonb
+);
+
+create index if not exists moderation_case_events_case on moderation_case_events ("case");
+
+create table if not exists moderation_appeals (
+  id uuid primary key default gen_random_uuid(),
+  created_at timestamptz not null default now(),
+  "case" uuid not null references moderation_cases (id) on delete cascade,
+  "user" text not null,
+  statement text not null,
+  status text not null default 'pending',
+  reviewer text,
+  response text,
+  reviewed_at timestamptz
+);
+
+-- Posts of moderation actions to a bot's log channel, one per action and event
+create table if not exists modlog_deliveries (
+  id bigint generated by default as identity primary key,
+  created_at timestamptz not null default now(),
+  action uuid not null references moderation_actions (id) on delete cascade,
+  bot uuid not null references bots (id) on delete cascade,
+  event text not null,
+  channel text not null,
+  status text not null default 'pending',
+  attempts int not null default 0,
+  message text,
+  error text,
+  delivered_at timestamptz,
+  constraint modlog_deliveries_action_event unique (action, event)
+);
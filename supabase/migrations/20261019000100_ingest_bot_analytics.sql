@@ -0,0 +1,68 @@
+-- Analytics samples are unique per bot and timestamp, so a batch that is retried is only counted once
+create unique index if not exists bot_analytics_bot_timestamp_key on bot_analytics (bot, "timestamp");
+
+-- Adds up two objects of command counts
+create or replace function analytics_sum_commands(a jsonb, b jsonb) returns jsonb
+language sql immutable as $$
+  select coalesce(jsonb_object_agg(key, total), '{}'::jsonb)
+  from (
+    select key, sum(value::bigint) as total
+    from (
+      select * from jsonb_each_text(coalesce(a, '{}'::jsonb))
+      union all
+      select * from jsonb_each_text(coalesce(b, '{}'::jsonb))
+    ) counts
+    group by key
+  ) totals
+$$;
+
+create or replace aggregate analytics_commands_agg(jsonb) (
+  sfunc = analytics_sum_commands,
+  stype = jsonb,
+  initcond = '{}'
+);
+
+-- Stores a batch of samples and folds the ones that were not already stored into the hourly and daily rollups,
+-- all in one statement so concurrent batches for the same bucket add up instead of overwriting each other
+create or replace function ingest_bot_analytics(p_bot uuid, p_samples jsonb)
+returns table (accepted int, hourly int, daily int)
+language sql as $$
+  with inserted as (
+    insert into bot_analytics (bot, commands, "timestamp", members, messages)
+    select p_bot, s.commands, s."timestamp", s.members, s.messages
+    from jsonb_to_recordset(p_samples) as s(commands jsonb, "timestamp" timestamptz, members int, messages int)
+    on conflict (bot, "timestamp") do nothing
+    returning commands, "timestamp", members, messages
+  ),
+  hourly as (
+    insert into bot_analytics_hourly as r (bot, bucket, members, members_at, messages, commands, samples)
+    select p_bot, date_trunc('hour', "timestamp" at time zone 'UTC') at time zone 'UTC',
+      (array_agg(members order by "timestamp" desc))[1], max("timestamp"), sum(messages),
+      analytics_commands_agg(commands), count(*)
+    from inserted
+    group by 2
+    on conflict (bot, bucket) do update set
+      members = case when excluded.members_at >= r.members_at then excluded.members else r.members end,
+      members_at = greatest(r.members_at, excluded.members_at),
+      messages = r.messages + excluded.messages,
+      commands = analytics_sum_commands(r.commands, excluded.commands),
+      samples = r.samples + excluded.samples
+    returning 1
+  ),
+  daily as (
+    insert into bot_analytics_daily as r (bot, bucket, members, members_at, messages, commands, samples)
+    select p_bot, date_trunc('day', "timestamp" at time zone 'UTC') at time zone 'UTC',
+      (array_agg(members order by "timestamp" desc))[1], max("timestamp"), sum(messages),
+      analytics_commands_agg(commands), count(*)
+    from inserted
+    group by 2
+    on conflict (bot, bucket) do update set
+      members = case when excluded.members_at >= r.members_at then excluded.members else r.members end,
+      members_at = greatest(r.members_at, excluded.members_at),
+      messages = r.messages + excluded.messages,
+      commands = analytics_sum_commands(r.commands, excluded.commands),
+      samples = r.samples + excluded.samples
+    returning 1
+  )
+  select (select count(*) from inserted)::int, (select count(*) from hourly)::int, (select count(*) from daily)::int
+$$;
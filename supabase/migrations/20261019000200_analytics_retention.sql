@@ -0,0 +1,83 @@
+-- Samples are folded into the rollups as they are ingested. Samples stored before that have rolled_up unset, and
+-- are folded by the retention job when it deletes them.
+alter table bot_analytics add column if not exists rolled_up boolean not null default false;
+alter table bot_analytics alter column rolled_up set default true;
+
+update bot_analytics a set rolled_up = true
+where exists (
+  select 1 from bot_analytics_hourly h
+  where h.bot = a.bot and h.bucket = date_trunc('hour', a."timestamp" at time zone 'UTC') at time zone 'UTC'
+);
+
+-- Leases that keep a background job to one replica at a time
+create table if not exists job_locks (
+  name text primary key,
+  locked_until timestamptz not null
+);
+
+-- Takes the lease of a job for p_seconds if nobody holds it, returning whether it was taken
+create or replace function try_job_lock(p_name text, p_seconds int) returns boolean
+language sql as $$
+  with taken as (
+    insert into job_locks (name, locked_until) values (p_name, now() + make_interval(secs => p_seconds))
+    on conflict (name) do update set locked_until = excluded.locked_until
+    where job_locks.locked_until < now()
+    returning 1
+  )
+  select exists (select 1 from taken)
+$$;
+
+-- Deletes the raw samples of a bot in [p_from, p_to) and folds the ones that were never rolled up into the hourly
+-- and daily rollups. The delete and the fold are one statement, so samples ingested meanwhile are neither deleted
+-- uncounted nor counted twice.
+create or replace function downsample_bot_analytics(p_bot uuid, p_from timestamptz, p_to timestamptz)
+returns table (deleted int, written int)
+language sql as $$
+  with removed as (
+    delete from bot_analytics
+    where bot = p_bot and "timestamp" >= p_from and "timestamp" < p_to
+    returning commands, "timestamp", members, messages, rolled_up
+  ),
+  legacy as (
+    -- samples from before ingestion was validated may hold malformed commands, those are left out
+    select "timestamp", members, messages,
+      case when jsonb_typeof(commands) = 'object' then (
+        select coalesce(jsonb_object_agg(key, value), '{}'::jsonb)
+        from jsonb_each(commands)
+        where jsonb_typeof(value) = 'number' and value::text ~ '^[0-9]+$'
+      ) else '{}'::jsonb end as commands
+    from removed
+    where not rolled_up
+  ),
+  hourly as (
+    insert into bot_analytics_hourly as r (bot, bucket, members, members_at, messages, commands, samples)
+    select p_bot, date_trunc('hour', "timestamp" at time zone 'UTC') at time zone 'UTC',
+      (array_agg(members order by "timestamp" desc))[1], max("timestamp"), sum(messages),
+      analytics_commands_agg(commands), count(*)
+    from legacy
+    group by 2
+    on conflict (bot, bucket) do update set
+      members = case when excluded.members_at >= r.members_at then excluded.members else r.members end,
+      members_at = greatest(r.members_at, excluded.members_at),
+      messages = r.messages + excluded.messages,
+      commands = analytics_sum_commands(r.commands, excluded.commands),
+      samples = r.samples + excluded.samples
+    returning 1
+  ),
+  daily as (
+    insert into bot_analytics_daily as r (bot, bucket, members, members_at, messages, commands, samples)
+    select p_bot, date_trunc('day', "timestamp" at time zone 'UTC') at time zone 'UTC',
+      (array_agg(members order by "timestamp" desc))[1], max("timestamp"), sum(messages),
+      analytics_commands_agg(commands), count(*)
+    from legacy
+    group by 2
+    on conflict (bot, bucket) do update set
+      members = case when excluded.members_at >= r.members_at then excluded.members else r.members end,
+      members_at = greatest(r.members_at, excluded.members_at),
+      messages = r.messages + excluded.messages,
+      commands = analytics_sum_commands(r.commands, excluded.commands),
+      samples = r.samples + excluded.samples
+    returning 1
+  )
+  select (select count(*) from removed)::int, (select count(*) from hourly)::int
+$$;
@@ -0,0 +1,37 @@
+-- Hands out a bot's pending commands, and delivered commands whose visibility timeout has passed, marking them
+-- delivered in the same statement so two polls never get the same command. Commands handed out p_max_attempts
+-- times without an acknowledgement are dead-lettered instead, and returned with their dead status so callers can
+-- clean up after them.
+create or replace function claim_runner_commands(p_bot uuid, p_limit int, p_timeout_seconds int, p_max_attempts int)
+returns setof runner_commands
+language sql as $$
+  with dead as (
+    update runner_commands
+    set status = 'dead', completed_at = now(), error = 'not acknowledged after ' || attempts || ' deliveries'
+    where bot = p_bot and status = 'delivered' and attempts >= p_max_attempts
+      and delivered_at < now() - make_interval(secs => p_timeout_seconds)
+    returning *
+  ),
+  claimable as (
+    select id from runner_commands
+    where bot = p_bot and (
+      status = 'pending' or (
+        status = 'delivered' and attempts < p_max_attempts
+        and delivered_at < now() - make_interval(secs => p_timeout_seconds)
+      )
+    )
+    order by created_at
+    limit p_limit
+    for update skip locked
+  ),
+  claimed as (
+    update runner_commands c
+    set status = 'delivered', attempts = c.attempts + 1, delivered_at = now()
+    from claimable
+    where c.id = claimable.id
+    returning c.*
+  )
+  select * from dead
+  union all
+  select * from claimed
+$$;
@@ -0,0 +1,19 @@
+-- Appends a value to one of the JSON array columns of a moderation case in the update itself, so concurrent notes,
+-- evidence and linked actions do not overwrite each other. With p_distinct the value is only appended when the
+-- array does not contain it yet. Returns the updated case, or nothing when the case was not changed.
+create or replace function append_moderation_case(p_case uuid, p_column text, p_value jsonb, p_distinct boolean)
+returns setof moderation_cases
+language plpgsql as $$
+begin
+  if p_column not in ('actions', 'notes', 'evidence') then
+    raise exception 'cannot append to moderation_cases.%', p_column;
+  end if;
+
+  return query execute format(
+    'update moderation_cases set %1$I = coalesce(%1$I, ''[]''::jsonb) || jsonb_build_array($2)
+     where id = $1 and not ($3 and coalesce(%1$I, ''[]''::jsonb) @> jsonb_build_array($2))
+     returning *',
+    p_column
+  ) using p_case, p_value, p_distinct;
+end
+$$;
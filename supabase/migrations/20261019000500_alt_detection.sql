@@ -0,0 +1,71 @@
+create extension if not exists fuzzystrmatch;
+
+-- The same normalisation as blacklist.NormalizeEmail, so addresses can be matched through an index
+create or replace function normalize_email(p_email text) returns text
+language sql immutable as $$
+  select case
+    when coalesce(parts.local, '') = '' then parts.email
+    when parts.domain = 'gmail.com' then replace(split_part(parts.local, '+', 1), '.', '') || '@' || parts.domain
+    else split_part(parts.local, '+', 1) || '@' || parts.domain
+  end
+  from (
+    select e as email,
+      substring(e from '^(.*)@[^@]*$') as local,
+      case when substring(e from '@([^@]*)$') = 'googlemail.com' then 'gmail.com' else substring(e from '@([^@]*)$') end as domain
+    from (select lower(btrim(p_email)) as e) input
+  ) parts
+$$;
+
+create index if not exists profiles_normalized_email on profiles (normalize_email(email));
+create index if not exists providers_type_provider_id on providers (type, provider_id);
+create index if not exists blacklist_user_unlifted on blacklist ("user") where not lifted;
+create index if not exists alt_detections_user_blacklist on alt_detections ("user", blacklist);
+
+-- Card fingerprints of saved payment methods, recorded by the Stripe webhook so detection does not call Stripe
+create table if not exists payment_fingerprints (
+  payment_method text primary key,
+  "user" uuid not null,
+  fingerprint text not null,
+  created_at timestamptz not null default now()
+);
+
+create index if not exists payment_fingerprints_fingerprint on payment_fingerprints (fingerprint);
+create index if not exists payment_fingerprints_user on payment_fingerprints ("user");
+
+-- The active blacklist entries a user could be an alternate account of and has not been matched against yet. Emails,
+-- verified Roblox accounts and cards are looked up through their indexes. Usernames cannot be, so they are only
+-- compared with the usernames of blacklisted users, keeping those at least p_username_similarity alike.
+create or replace function alt_detection_candidates(p_user uuid, p_username_similarity float8)
+returns setof blacklist
+language sql stable as $$
+  with subject as (
+    select normalize_email(email) as email, lower(regexp_replace(coalesce(preferred_name, ''), '[^[:alnum:]]', '', 'g')) as username
+    from profiles
+    where id = p_user
+  ),
+  related as (
+    select p.id as "user" from profiles p, subject s where normalize_email(p.email) = s.email
+    union
+    select other."user" from providers own
+    join providers other on other.type = own.type and other.provider_id = own.provider_id
+    where own."user" = p_user and own.type = 'roblox'
+    union
+    select other."user" from payment_fingerprints own
+    join payment_fingerprints other on other.fingerprint = own.fingerprint
+    where own."user" = p_user
+  ),
+  active as (
+    select b.* from blacklist b
+    where not b.lifted and (not b.expires or b.expiry > now()) and b."user" is not null and b."user" <> p_user
+      and not exists (select 1 from alt_detections d where d."user" = p_user and d.blacklist = b.id)
+  )
+  select a.* from active a
+  cross join subject s
+  left join profiles p on p.id = a."user"
+  cross join lateral (select lower(regexp_replace(coalesce(p.preferred_name, ''), '[^[:alnum:]]', '', 'g')) as username) other
+  where a."user" in (select "user" from related)
+    or (
+      s.username <> '' and other.username <> ''
+      and 1 - levenshtein(s.username, other.username)::float8 / greatest(length(s.username), length(other.username)) >= p_username_similarity
+    )
+$$;
@@ -0,0 +1,72 @@
+-- Applies changes to a bot and records the resulting config as a new version in one transaction, so a bot is never
+-- changed without its version being recorded. The row lock makes concurrent saves of a bot take version numbers one
+-- at a time.
+--
+-- p_changes holds the columns to change. The activity rotation fields of settings belong to the presence job, so
+-- saving settings keeps their current values. Without p_config no version is recorded. p_initial is recorded as
+-- version 1 for bots that have no versions yet, so their first change can be undone. Versions older than the
+-- p_retention latest are deleted, 0 keeps every version. p_integrations optionally maps workspace integration IDs to
+-- new settings, so a configuration document is imported entirely or not at all.
+create or replace function save_bot(
+  p_bot uuid, p_changes jsonb, p_initial jsonb, p_config jsonb, p_diff jsonb, p_author text, p_summary text, p_retention int,
+  p_integrations jsonb
+)
+returns setof bots
+language plpgsql as $$
+declare
+  v_current bots;
+  v_bot bots;
+  v_number int;
+begin
+  select * into v_current from bots where id = p_bot for update;
+
+  if not found then
+    return;
+  end if;
+
+  v_bot := jsonb_populate_record(v_current, p_changes);
+
+  if p_changes ? 'settings' then
+    v_bot.settings := coalesce(v_bot.settings, '{}'::jsonb) || jsonb_strip_nulls(jsonb_build_object(
+      'currentActivity', v_current.settings->'currentActivity',
+      'activitySchedule', v_current.settings->'activitySchedule',
+      'activityUpdatedAt', v_current.settings->'activityUpdatedAt'
+    ));
+  end if;
+
+  update bots set
+    region = v_bot.region,
+    token = v_bot.token,
+    settings = v_bot.settings,
+    permissions = v_bot.permissions,
+    commands = v_bot.commands
+  where id = p_bot
+  returning * into v_bot;
+
+  if p_integrations is not null then
+    update workspace_integrations w set settings = i.value
+    from jsonb_each(p_integrations) i
+    where w.id = i.key::bigint;
+  end if;
+
+  if p_config is not null then
+    select coalesce(max(version), 0) + 1 into v_number from bot_versions where bot = p_bot;
+
+    if v_number = 1 and p_initial is not null then
+      insert into bot_versions (bot, version, author, summary, config, diff)
+      values (p_bot, 1, '', 'Configuration before version history', p_initial, '[]'::jsonb);
+
+      v_number := 2;
+    end if;
+
+    insert into bot_versions (bot, version, author, summary, config, diff)
+    values (p_bot, v_number, p_author, p_summary, p_config, coalesce(p_diff, '[]'::jsonb));
+
+    if p_retention > 0 then
+      delete from bot_versions where bot = p_bot and version < v_number - p_retention + 1;
+    end if;
+  end if;
+
+  return next v_bot;
+end
+$$;
@@ -0,0 +1,13 @@
+-- Writes the activity rotation fields of a bot's settings without touching the rest, so a rotation does not undo
+-- settings saved since the bot was read
+create or replace function set_bot_rotation(p_bot uuid, p_current_activity int, p_schedule text, p_updated_at timestamptz)
+returns void
+language sql as $$
+  update bots
+  set settings = coalesce(settings, '{}'::jsonb) || jsonb_build_object(
+    'currentActivity', p_current_activity,
+    'activitySchedule', p_schedule,
+    'activityUpdatedAt', p_updated_at
+  )
+  where id = p_bot
+$$;
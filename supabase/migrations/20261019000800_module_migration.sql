@@ -0,0 +1,18 @@
+-- One-off jobs that finished, so they are not run again by another replica or after a restart
+create table if not exists completed_jobs (
+  name text primary key,
+  completed_at timestamptz not null default now()
+);
+
+-- Replaces the module settings of a bot if they are still p_from, returning whether they were replaced. The rest of
+-- the settings is left alone, so settings saved meanwhile are kept.
+create or replace function set_bot_modules(p_bot uuid, p_from jsonb, p_to jsonb) returns boolean
+language sql as $$
+  with updated as (
+    update bots
+    set settings = jsonb_set(coalesce(settings, '{}'::jsonb), '{modules}', p_to)
+    where id = p_bot and coalesce(settings->'modules', 'null'::jsonb) = coalesce(p_from, 'null'::jsonb)
+    returning 1
+  )
+  select exists (select 1 from updated)
+$$;
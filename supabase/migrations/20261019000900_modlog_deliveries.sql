@@ -0,0 +1,2 @@
+-- An action is logged once per event, replicas racing to log the same event insert the same delivery
+create unique index if not exists modlog_deliveries_action_event on modlog_deliveries (action, event);
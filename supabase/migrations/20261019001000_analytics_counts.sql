@@ -0,0 +1,41 @@
+-- Deletes the analytics of a bot in [p_from, p_to) from one of the analytics tables in one statement, returning how
+-- many rows it deleted. A dry run only counts them.
+create or replace function purge_bot_analytics(p_table text, p_bot uuid, p_from timestamptz, p_to timestamptz, p_dry_run boolean)
+returns int
+language plpgsql as $$
+declare
+  v_column text;
+  v_count int;
+begin
+  v_column := case p_table
+    when 'bot_analytics' then 'timestamp'
+    when 'bot_analytics_hourly' then 'bucket'
+    when 'bot_analytics_daily' then 'bucket'
+  end;
+
+  if v_column is null then
+    raise exception '% is not an analytics table', p_table;
+  end if;
+
+  if p_dry_run then
+    execute format('select count(*) from %1$I where bot = $1 and %2$I >= $2 and %2$I < $3', p_table, v_column)
+    into v_count using p_bot, p_from, p_to;
+  else
+    execute format(
+      'with deleted as (delete from %1$I where bot = $1 and %2$I >= $2 and %2$I < $3 returning 1) select count(*) from deleted',
+      p_table, v_column
+    ) into v_count using p_bot, p_from, p_to;
+  end if;
+
+  return v_count;
+end;
+$$;
+
+-- Counts the hours of a bot's raw samples in [p_from, p_to) that were stored before ingestion rolled them up, the
+-- hourly rollups downsampling would write
+create or replace function count_unrolled_buckets(p_bot uuid, p_from timestamptz, p_to timestamptz) returns int
+language sql stable as $$
+  select count(distinct date_trunc('hour', "timestamp" at time zone 'UTC'))::int
+  from bot_analytics
+  where bot = p_bot and not rolled_up and "timestamp" >= p_from and "timestamp" < p_to
+$$;
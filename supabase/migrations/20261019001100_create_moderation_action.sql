@@ -0,0 +1,27 @@
+-- Stores a moderation action and queues the runner command that carries it out in one transaction, so an action is
+-- never stored without being applied. The payload has the shape of moderation.CommandPayload.
+create or replace function create_moderation_action(p_action jsonb, p_command_type text)
+returns setof moderation_actions
+language plpgsql as $$
+declare
+  v_action moderation_actions;
+begin
+  insert into moderation_actions (bot, guild, action, moderator, reason, expires, expiry, "user")
+  select bot, guild, action, moderator, reason, expires, expiry, "user"
+  from jsonb_populate_record(null::moderation_actions, p_action)
+  returning * into v_action;
+
+  insert into runner_commands (id, bot, type, payload, status)
+  values (
+    'moderation-apply-' || v_action.id, v_action.bot, p_command_type,
+    jsonb_build_object(
+      'action', v_action.id, 'type', v_action.action, 'guild', v_action.guild, 'user', v_action."user",
+      'reason', v_action.reason
+    ),
+    'pending'
+  )
+  on conflict (id) do nothing;
+
+  return next v_action;
+end;
+$$;
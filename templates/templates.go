@@ -0,0 +1,137 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// the longest template that is rendered at all, callers limit the output to what they can send
+const MaxTemplateLength = 8000
+
+// placeholders are a name between double braces, "{{ user.name }}", a leading dot is allowed for
+// templates written for text/template
+var name = regexp.MustCompile(`^\.?([A-Za-z][A-Za-z0-9_]*(?:\.[A-Za-z][A-Za-z0-9_]*)*)$`)
+
+// Error is a problem with a template, Pos is the byte offset it was found at
+type Error struct {
+	Pos     int    `json:"pos"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at %d: %s", e.Pos, e.Message)
+}
+
+// lookup resolves a dotted name in the variables, only scalars can be rendered
+func lookup(variables map[string]any, path string) (string, error) {
+	var value any = variables
+
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+
+		if !ok {
+			return "", fmt.Errorf("%s is not a variable", path)
+		}
+
+		if value, ok = object[part]; !ok {
+			return "", fmt.Errorf("%s is not a variable, use one of %s", path, strings.Join(Names(variables), ", "))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return "", fmt.Errorf("%s is a group of variables, use one of %s", path, strings.Join(Names(v), ", "))
+	case nil:
+		return "", nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// Names lists every variable that can be used, by its dotted name
+func Names(variables map[string]any) []string {
+	var names []string
+
+	for key, value := range variables {
+		if nested, ok := value.(map[string]any); ok {
+			for _, n := range Names(nested) {
+				names = append(names, key+"."+n)
+			}
+		} else {
+			names = append(names, key)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Render fills in the placeholders of a welcome or leave message. Templates can only use the variables they are
+// given, there are no functions, conditions or loops. maxLength limits the output, 0 for no limit. Custom
+// command responses and activity names stay text/template, saved ones may use its actions.
+func Render(template string, variables map[string]any, maxLength int) (string, error) {
+	if len(template) > MaxTemplateLength {
+		return "", &Error{Pos: 0, Message: fmt.Sprintf("templates must be at most %d characters", MaxTemplateLength)}
+	}
+
+	var out strings.Builder
+
+	rest, offset := template, 0
+
+	for {
+		start := strings.Index(rest, "{{")
+		end := strings.Index(rest, "}}")
+
+		if end >= 0 && (start < 0 || end < start) {
+			return "", &Error{Pos: offset + end, Message: "unexpected }}"}
+		}
+
+		if start < 0 {
+			out.WriteString(rest)
+			break
+		}
+
+		out.WriteString(rest[:start])
+
+		if end < 0 {
+			return "", &Error{Pos: offset + start, Message: "unclosed {{"}
+		}
+
+		placeholder := strings.TrimSpace(rest[start+2 : end])
+		match := name.FindStringSubmatch(placeholder)
+
+		if match == nil {
+			return "", &Error{Pos: offset + start, Message: fmt.Sprintf("%q is not a variable name", placeholder)}
+		}
+
+		value, err := lookup(variables, match[1])
+
+		if err != nil {
+			return "", &Error{Pos: offset + start, Message: err.Error()}
+		}
+
+		out.WriteString(value)
+
+		if maxLength > 0 && out.Len() > maxLength {
+			return "", &Error{Pos: offset + start, Message: fmt.Sprintf("the rendered text is longer than %d characters", maxLength)}
+		}
+
+		rest, offset = rest[end+2:], offset+end+2
+	}
+
+	if maxLength > 0 && out.Len() > maxLength {
+		return "", &Error{Pos: len(template), Message: fmt.Sprintf("the rendered text is longer than %d characters", maxLength)}
+	}
+
+	return out.String(), nil
+}
+
+// Validate checks a template by rendering it with example values of its variables
+func Validate(template string, examples map[string]any, maxLength int) error {
+	_, err := Render(template, examples, maxLength)
+
+	return err
+}
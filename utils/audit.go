@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nedpals/supabase-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// Audit records a privileged action in the audit log
+func Audit(database *supabase.Client, entry IAuditLog) error {
+	return database.DB.From("audit_logs").Insert(entry).Execute(nil)
+}
+
+// AuditRequest records an action taken by actor during a request. The action has already happened, so a failure
+// to record it is logged rather than returned.
+func AuditRequest(ctx *fiber.Ctx, database *supabase.Client, actor string, action string, targetType string, target string, data any) {
+	err := Audit(database, IAuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		Target:     target,
+		Data:       data,
+		IP:         ctx.IP(),
+		Method:     ctx.Method(),
+		Path:       ctx.Path(),
+	})
+
+	if err != nil {
+		log.Errorf("recording audit log %s by %s failed: %v", action, actor, err)
+	}
+}
@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"github.com/nedpals/supabase-go"
+)
+
+// Rpc calls a database function through PostgREST and decodes what it returns into out. postgrest-go's own Rpc
+// neither resolves the path against the API's URL nor returns the body, so the call is made as an insert into the
+// function's endpoint, which is the same POST /rpc/<function> request.
+func Rpc(database *supabase.Client, function string, params any, out any) error {
+	return database.DB.From("rpc/" + function).Insert(params).Execute(out)
+}
@@ -0,0 +1,368 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord's limits for embeds
+const (
+	MaxEmbedTitle       = 256
+	MaxEmbedDescription = 4096
+	MaxEmbedFields      = 25
+	MaxEmbedFieldName   = 256
+	MaxEmbedFieldValue  = 1024
+	MaxEmbedFooter      = 2048
+	MaxEmbedAuthor      = 256
+	// the characters of every embed of a message together
+	MaxEmbedTotal = 6000
+	// embeds a message can have
+	MaxEmbeds = 10
+)
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type EmbedFooter struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type EmbedMedia struct {
+	URL string `json:"url"`
+}
+
+type EmbedAuthor struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// Embed is a message embed in the shape Discord's API takes
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+	Image       *EmbedMedia  `json:"image,omitempty"`
+	Thumbnail   *EmbedMedia  `json:"thumbnail,omitempty"`
+	Author      *EmbedAuthor `json:"author,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+}
+
+func length(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// Length counts the characters of an embed the way Discord does for its total limit
+func (e Embed) Length() int {
+	n := length(e.Title) + length(e.Description)
+
+	for _, field := range e.Fields {
+		n += length(field.Name) + length(field.Value)
+	}
+
+	if e.Footer != nil {
+		n += length(e.Footer.Text)
+	}
+
+	if e.Author != nil {
+		n += length(e.Author.Name)
+	}
+
+	return n
+}
+
+// IsEmpty reports whether an embed has nothing Discord would show
+func (e Embed) IsEmpty() bool {
+	return e.Title == "" && e.Description == "" && len(e.Fields) == 0 && e.Image == nil && e.Thumbnail == nil &&
+		(e.Footer == nil || e.Footer.Text == "") && (e.Author == nil || e.Author.Name == "")
+}
+
+// checkURL accepts http and https URLs, and attachment:// references when media is true
+func checkURL(name string, value string, media bool) error {
+	if value == "" {
+		return nil
+	}
+
+	if media && strings.HasPrefix(value, "attachment://") && len(value) > len("attachment://") {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		if media {
+			return fmt.Errorf("%s must be an http, https or attachment:// URL", name)
+		}
+
+		return fmt.Errorf("%s must be an http or https URL", name)
+	}
+
+	return nil
+}
+
+func checkLength(name string, value string, max int) error {
+	if length(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", name, max)
+	}
+
+	return nil
+}
+
+// Validate checks an embed against Discord's limits
+func (e Embed) Validate() error {
+	if e.IsEmpty() {
+		return errors.New("embeds need a title, a description, fields or an image")
+	}
+
+	checks := []error{
+		checkLength("the title", e.Title, MaxEmbedTitle),
+		checkLength("the description", e.Description, MaxEmbedDescription),
+		checkURL("the URL", e.URL, false),
+	}
+
+	if e.Color < 0 || e.Color > 0xffffff {
+		checks = append(checks, errors.New("the color must be between 0 and 16777215"))
+	}
+
+	if e.Timestamp != "" {
+		if _, err := time.Parse(time.RFC3339, e.Timestamp); err != nil {
+			checks = append(checks, errors.New("the timestamp must be an ISO 8601 time such as 2022-06-01T12:00:00Z"))
+		}
+	}
+
+	if e.Footer != nil {
+		checks = append(checks, checkLength("the footer", e.Footer.Text, MaxEmbedFooter), checkURL("the footer icon", e.Footer.IconURL, true))
+	}
+
+	if e.Image != nil {
+		checks = append(checks, checkURL("the image", e.Image.URL, true))
+	}
+
+	if e.Thumbnail != nil {
+		checks = append(checks, checkURL("the thumbnail", e.Thumbnail.URL, true))
+	}
+
+	if e.Author != nil {
+		checks = append(checks,
+			checkLength("the author", e.Author.Name, MaxEmbedAuthor),
+			checkURL("the author URL", e.Author.URL, false),
+			checkURL("the author icon", e.Author.IconURL, true),
+		)
+	}
+
+	if len(e.Fields) > MaxEmbedFields {
+		checks = append(checks, fmt.Errorf("embeds can have at most %d fields", MaxEmbedFields))
+	}
+
+	for i, field := range e.Fields {
+		if field.Name == "" || field.Value == "" {
+			checks = append(checks, fmt.Errorf("field %d needs a name and a value", i+1))
+		}
+
+		checks = append(checks,
+			checkLength(fmt.Sprintf("the name of field %d", i+1), field.Name, MaxEmbedFieldName),
+			checkLength(fmt.Sprintf("the value of field %d", i+1), field.Value, MaxEmbedFieldValue),
+		)
+	}
+
+	if n := e.Length(); n > MaxEmbedTotal {
+		checks = append(checks, fmt.Errorf("embeds can have at most %d characters in total, this one has %d", MaxEmbedTotal, n))
+	}
+
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateEmbeds checks the embeds of a message, which share Discord's total limit
+func ValidateEmbeds(embeds []Embed) error {
+	if len(embeds) > MaxEmbeds {
+		return fmt.Errorf("messages can have at most %d embeds", MaxEmbeds)
+	}
+
+	total := 0
+
+	for i, embed := range embeds {
+		if err := embed.Validate(); err != nil {
+			if len(embeds) == 1 {
+				return err
+			}
+
+			return fmt.Errorf("embed %d: %w", i+1, err)
+		}
+
+		total += embed.Length()
+	}
+
+	if total > MaxEmbedTotal {
+		return fmt.Errorf("the embeds of a message can have at most %d characters in total", MaxEmbedTotal)
+	}
+
+	return nil
+}
+
+// EmbedPreview is an embed as Discord would receive it, with what an editor needs to show alongside it
+type EmbedPreview struct {
+	Embed Embed `json:"embed"`
+	// the characters that count towards the total limit
+	Length int    `json:"length"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Preview renders an embed for an editor, reporting the first problem instead of failing
+func (e Embed) Preview() EmbedPreview {
+	preview := EmbedPreview{Embed: e, Length: e.Length(), Valid: true}
+
+	if err := e.Validate(); err != nil {
+		preview.Valid, preview.Error = false, err.Error()
+	}
+
+	return preview
+}
+
+type EmbedFormField struct {
+	Name   string `json:"name" form:"name"`
+	Value  string `json:"value" form:"value"`
+	Inline bool   `json:"inline" form:"inline"`
+}
+
+// EmbedForm is a flat version of an embed that HTML forms can post, fields are posted as fields.0.name and so on
+type EmbedForm struct {
+	Title       string `json:"title" form:"title"`
+	Description string `json:"description" form:"description"`
+	URL         string `json:"url" form:"url"`
+	// a hex color such as #5865f2, or a number
+	Color      string           `json:"color" form:"color"`
+	Timestamp  string           `json:"timestamp" form:"timestamp"`
+	AuthorName string           `json:"authorName" form:"authorName"`
+	AuthorURL  string           `json:"authorUrl" form:"authorUrl"`
+	AuthorIcon string           `json:"authorIcon" form:"authorIcon"`
+	FooterText string           `json:"footerText" form:"footerText"`
+	FooterIcon string           `json:"footerIcon" form:"footerIcon"`
+	Image      string           `json:"image" form:"image"`
+	Thumbnail  string           `json:"thumbnail" form:"thumbnail"`
+	Fields     []EmbedFormField `json:"fields" form:"fields"`
+}
+
+// ParseColor reads a hex color such as #5865f2 or a decimal number
+func ParseColor(color string) (int, error) {
+	color = strings.TrimSpace(color)
+
+	if color == "" {
+		return 0, nil
+	}
+
+	base := 10
+
+	if strings.HasPrefix(color, "#") {
+		color, base = color[1:], 16
+	} else if strings.HasPrefix(strings.ToLower(color), "0x") {
+		color, base = color[2:], 16
+	}
+
+	n, err := strconv.ParseInt(color, base, 32)
+
+	if err != nil || n < 0 || n > 0xffffff {
+		return 0, errors.New("the color must be a hex color such as #5865f2 or a number up to 16777215")
+	}
+
+	return int(n), nil
+}
+
+// Embed converts the form into Discord's shape. Fields left completely blank are dropped, forms usually have a few
+// spare rows.
+func (f EmbedForm) Embed() (Embed, error) {
+	color, err := ParseColor(f.Color)
+
+	if err != nil {
+		return Embed{}, err
+	}
+
+	e := Embed{
+		Title:       strings.TrimSpace(f.Title),
+		Description: strings.TrimSpace(f.Description),
+		URL:         strings.TrimSpace(f.URL),
+		Timestamp:   strings.TrimSpace(f.Timestamp),
+		Color:       color,
+	}
+
+	if f.FooterText != "" || f.FooterIcon != "" {
+		e.Footer = &EmbedFooter{Text: f.FooterText, IconURL: strings.TrimSpace(f.FooterIcon)}
+	}
+
+	if image := strings.TrimSpace(f.Image); image != "" {
+		e.Image = &EmbedMedia{URL: image}
+	}
+
+	if thumbnail := strings.TrimSpace(f.Thumbnail); thumbnail != "" {
+		e.Thumbnail = &EmbedMedia{URL: thumbnail}
+	}
+
+	if f.AuthorName != "" || f.AuthorURL != "" || f.AuthorIcon != "" {
+		e.Author = &EmbedAuthor{Name: f.AuthorName, URL: strings.TrimSpace(f.AuthorURL), IconURL: strings.TrimSpace(f.AuthorIcon)}
+	}
+
+	for _, field := range f.Fields {
+		if strings.TrimSpace(field.Name) == "" && strings.TrimSpace(field.Value) == "" {
+			continue
+		}
+
+		e.Fields = append(e.Fields, EmbedField{Name: field.Name, Value: field.Value, Inline: field.Inline})
+	}
+
+	return e, nil
+}
+
+// Form converts an embed into the flat form, for filling in an edit form
+func (e Embed) Form() EmbedForm {
+	f := EmbedForm{
+		Title:       e.Title,
+		Description: e.Description,
+		URL:         e.URL,
+		Timestamp:   e.Timestamp,
+		Fields:      []EmbedFormField{},
+	}
+
+	if e.Color != 0 {
+		f.Color = fmt.Sprintf("#%06x", e.Color)
+	}
+
+	if e.Footer != nil {
+		f.FooterText, f.FooterIcon = e.Footer.Text, e.Footer.IconURL
+	}
+
+	if e.Image != nil {
+		f.Image = e.Image.URL
+	}
+
+	if e.Thumbnail != nil {
+		f.Thumbnail = e.Thumbnail.URL
+	}
+
+	if e.Author != nil {
+		f.AuthorName, f.AuthorURL, f.AuthorIcon = e.Author.Name, e.Author.URL, e.Author.IconURL
+	}
+
+	for _, field := range e.Fields {
+		f.Fields = append(f.Fields, EmbedFormField(field))
+	}
+
+	return f
+}
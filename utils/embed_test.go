@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmbedValidate(t *testing.T) {
+	fields := func(n int) []EmbedField {
+		list := []EmbedField{}
+
+		for i := 0; i < n; i++ {
+			list = append(list, EmbedField{Name: "name", Value: "value"})
+		}
+
+		return list
+	}
+
+	tests := []struct {
+		name  string
+		embed Embed
+		// a part of the error, empty when the embed is valid
+		err string
+	}{
+		{
+			name:  "title only",
+			embed: Embed{Title: "Hello"},
+		},
+		{
+			name:  "image only",
+			embed: Embed{Image: &EmbedMedia{URL: "attachment://image.png"}},
+		},
+		{
+			name: "everything",
+			embed: Embed{
+				Title:       "Hello",
+				Description: "World",
+				URL:         "https://astralapp.io",
+				Timestamp:   "2022-06-01T12:00:00Z",
+				Color:       0x5865f2,
+				Footer:      &EmbedFooter{Text: "footer", IconURL: "https://astralapp.io/icon.png"},
+				Thumbnail:   &EmbedMedia{URL: "https://astralapp.io/thumbnail.png"},
+				Author:      &EmbedAuthor{Name: "author", URL: "https://astralapp.io", IconURL: "attachment://author.png"},
+				Fields:      fields(MaxEmbedFields),
+			},
+		},
+		{
+			name:  "empty",
+			embed: Embed{Footer: &EmbedFooter{}},
+			err:   "embeds need",
+		},
+		{
+			name:  "title too long",
+			embed: Embed{Title: strings.Repeat("a", MaxEmbedTitle+1)},
+			err:   "the title must be at most 256 characters",
+		},
+		{
+			name:  "limits count characters rather than bytes",
+			embed: Embed{Title: strings.Repeat("é", MaxEmbedTitle)},
+		},
+		{
+			name:  "URL that is not http",
+			embed: Embed{Title: "Hello", URL: "javascript:alert(1)"},
+			err:   "the URL must be an http or https URL",
+		},
+		{
+			name:  "attachment outside media",
+			embed: Embed{Title: "Hello", URL: "attachment://image.png"},
+			err:   "the URL must be",
+		},
+		{
+			name:  "bare attachment",
+			embed: Embed{Image: &EmbedMedia{URL: "attachment://"}},
+			err:   "the image must be",
+		},
+		{
+			name:  "color out of range",
+			embed: Embed{Title: "Hello", Color: 0x1000000},
+			err:   "the color",
+		},
+		{
+			name:  "timestamp that is not ISO 8601",
+			embed: Embed{Title: "Hello", Timestamp: "June 1st"},
+			err:   "the timestamp",
+		},
+		{
+			name:  "too many fields",
+			embed: Embed{Fields: fields(MaxEmbedFields + 1)},
+			err:   "at most 25 fields",
+		},
+		{
+			name:  "field without a value",
+			embed: Embed{Fields: []EmbedField{{Name: "name", Value: "value"}, {Name: "name"}}},
+			err:   "field 2 needs a name and a value",
+		},
+		{
+			name: "total too long",
+			embed: Embed{
+				Description: strings.Repeat("a", MaxEmbedDescription),
+				Footer:      &EmbedFooter{Text: strings.Repeat("a", MaxEmbedFooter)},
+			},
+			err: "at most 6000 characters in total",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.embed.Validate()
+
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error = %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
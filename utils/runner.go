@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	postgrest "github.com/nedpals/postgrest-go/pkg"
+	"github.com/nedpals/supabase-go"
+)
+
+const (
+	RunnerCommandPending   = "pending"
+	RunnerCommandDelivered = "delivered"
+	RunnerCommandSucceeded = "succeeded"
+	RunnerCommandFailed    = "failed"
+	// handed out RunnerCommandMaxAttempts times without ever being acknowledged
+	RunnerCommandDead = "dead"
+)
+
+// delivered commands that are not acknowledged within this window are handed out again
+const RunnerCommandVisibilityTimeout = time.Minute
+
+// the most times a command is handed out before it is dead-lettered
+const RunnerCommandMaxAttempts = 5
+
+// QueueRunnerCommand queues a command for a bot's runner. Commands are delivered at least once, and queueing a
+// command whose ID already exists does nothing, so callers should derive the ID from what the command is for.
+func QueueRunnerCommand(database *supabase.Client, bot string, id string, commandType string, payload any) error {
+	err := database.DB.From("runner_commands").Insert(IRunnerCommand{
+		ID:      id,
+		Bot:     bot,
+		Type:    commandType,
+		Payload: payload,
+		Status:  RunnerCommandPending,
+	}).Execute(nil)
+
+	// the command has already been queued
+	if IsUniqueViolation(err) {
+		return nil
+	}
+
+	return err
+}
+
+// IsUniqueViolation reports whether an insert failed because the row already exists
+func IsUniqueViolation(err error) bool {
+	var requestErr *postgrest.RequestError
+
+	return errors.As(err, &requestErr) && requestErr.Code == "23505"
+}
@@ -33,6 +33,8 @@ type IProfile struct {
 	Verified         bool          `json:"verified"`
 	Public           bool          `json:"public"`
 	Workspaces       []IWorkspace  `json:"workspaces"`
+	// tokens issued before this are no longer accepted
+	SessionsRevokedAt *time.Time `json:"sessions_revoked_at,omitempty"`
 }
 
 type IIdentityData struct {
@@ -96,7 +98,7 @@ type IProvider struct {
 }
 
 type IBlacklist struct {
-	ID             int8        `json:"id"`
+	ID             int64       `json:"id"`
 	CreatedAt      time.Time   `json:"created_at"`
 	Moderator      string      `json:"moderator"`
 	User           string      `json:"user"`
@@ -107,6 +109,93 @@ type IBlacklist struct {
 	Flags          interface{} `json:"flags"`
 	FactorMatching []string    `json:"factor_matching"`
 	Notes          string      `json:"notes"`
+	Lifted         bool        `json:"lifted"`
+	LiftedAt       *time.Time  `json:"lifted_at,omitempty"`
+	LiftedBy       *string     `json:"lifted_by,omitempty"`
+}
+
+// Active reports whether the entry still blocks the user
+func (b IBlacklist) Active(now time.Time) bool {
+	return !b.Lifted && (!b.Expires || b.Expiry.After(now))
+}
+
+type IBlacklistAppeal struct {
+	ID         *string                   `json:"id,omitempty"`
+	CreatedAt  *time.Time                `json:"created_at,omitempty"`
+	Blacklist  int64                     `json:"blacklist"`
+	User       string                    `json:"user"`
+	Statement  string                    `json:"statement"`
+	Status     string                    `json:"status"`
+	Messages   []IBlacklistAppealMessage `json:"messages"`
+	Reviewer   *string                   `json:"reviewer,omitempty"`
+	ReviewedAt *time.Time                `json:"reviewed_at,omitempty"`
+}
+
+// a message exchanged between staff and the user while an appeal is reviewed
+type IBlacklistAppealMessage struct {
+	Author    string    `json:"author"`
+	Staff     bool      `json:"staff"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// a suspected alternate account of a blacklisted user
+type IAltDetection struct {
+	ID          *string           `json:"id,omitempty"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty"`
+	User        string            `json:"user"`
+	Blacklist   int64             `json:"blacklist"`
+	MatchedUser string            `json:"matched_user"`
+	Trigger     string            `json:"trigger"`
+	Score       float64           `json:"score"`
+	Matches     []IAltFactorMatch `json:"matches"`
+	Action      string            `json:"action"`
+	Entry       *int64            `json:"entry,omitempty"`
+	Status      string            `json:"status"`
+	Reviewer    *string           `json:"reviewer,omitempty"`
+	ReviewedAt  *time.Time        `json:"reviewed_at,omitempty"`
+}
+
+type IAltFactorMatch struct {
+	Factor      string  `json:"factor"`
+	Weight      float64 `json:"weight"`
+	Similarity  float64 `json:"similarity"`
+	Explanation string  `json:"explanation"`
+}
+
+// the fingerprint of a card saved to a user's Stripe customer
+type IPaymentFingerprint struct {
+	PaymentMethod string     `json:"payment_method"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	User          string     `json:"user"`
+	Fingerprint   string     `json:"fingerprint"`
+}
+
+type IAuditLog struct {
+	ID         *int64     `json:"id,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	Actor      string     `json:"actor"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"target_type"`
+	Target     string     `json:"target"`
+	Data       any        `json:"data"`
+	IP         string     `json:"ip"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+}
+
+type IImpersonationSession struct {
+	ID        *string    `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Staff     string     `json:"staff"`
+	User      string     `json:"user"`
+	Reason    string     `json:"reason"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+func (s IImpersonationSession) Active(now time.Time) bool {
+	return s.EndedAt == nil && s.ExpiresAt.After(now)
 }
 
 type IStatistic struct {
@@ -154,6 +243,10 @@ type IPlan struct {
 	PriceYearly  string `json:"priceYearly"`
 	Limit        string `json:"limit"`
 	Enabled      bool   `json:"enabled"`
+	// days of analytics history the plan keeps, 0 keeps everything
+	AnalyticsRetention int `json:"analyticsRetention"`
+	// bot config versions the plan keeps, 0 keeps every version
+	VersionRetention int `json:"versionRetention"`
 }
 
 type IBot struct {
@@ -179,6 +272,21 @@ type IBotCommand struct {
 	ID      string      `json:"id"`
 	Options interface{} `json:"options"`
 	Enabled bool        `json:"enabled"`
+	// seconds a user has to wait between uses, 0 disables the cooldown
+	Cooldown     int                     `json:"cooldown"`
+	Restrictions IBotCommandRestrictions `json:"restrictions"`
+	// custom commands are defined by the workspace and reply with their templated response
+	Custom      bool   `json:"custom"`
+	Description string `json:"description,omitempty"`
+	Response    string `json:"response,omitempty"`
+}
+
+// empty allow lists allow everything, deny lists win over allow lists
+type IBotCommandRestrictions struct {
+	AllowedChannels []string `json:"allowedChannels"`
+	DeniedChannels  []string `json:"deniedChannels"`
+	AllowedRoles    []string `json:"allowedRoles"`
+	DeniedRoles     []string `json:"deniedRoles"`
 }
 
 type IBotSettings struct {
@@ -189,7 +297,12 @@ type IBotSettings struct {
 	RandomizeActivities bool           `json:"randomizeActivities" form:"randomizeActivities"`
 	ActivityInterval    int            `json:"activityInterval" form:"activityInterval"`
 	CurrentActivity     int            `json:"currentActivity"`
-	Modules             IBotModules    `json:"modules" form:"modules"`
+	// schedules replace the status and activities while they are active, the first active one wins
+	ActivitySchedules []IBotActivitySchedule `json:"activitySchedules" form:"activitySchedules"`
+	// the schedule the current activity was picked from, empty for the default activities
+	ActivitySchedule  string      `json:"activitySchedule,omitempty"`
+	ActivityUpdatedAt *time.Time  `json:"activityUpdatedAt,omitempty"`
+	Modules           IBotModules `json:"modules" form:"modules"`
 }
 
 type IBotActivity struct {
@@ -197,21 +310,54 @@ type IBotActivity struct {
 	Type string `json:"type" form:"type"`
 }
 
-type IBotModules struct {
-	Fun        IBotModule[any] `json:"fun"`
-	Moderation IBotModule[struct {
-		Logging struct {
-			Enabled bool   `json:"enabled"`
-			Channel string `json:"channel"`
-		} `json:"logging"`
-	}] `json:"moderation"`
+type IBotActivitySchedule struct {
+	Name string `json:"name"`
+	// days of the week the schedule is active on, 0 is Sunday, empty for every day
+	Days []int `json:"days"`
+	// times of day as HH:MM, a window that ends before it starts runs past midnight
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// an IANA time zone, UTC if empty
+	Timezone   string         `json:"timezone"`
+	Status     string         `json:"status"`
+	Activities []IBotActivity `json:"activities"`
 }
 
+// the bot's modules by module ID, the modules package describes the options of each
+type IBotModules map[string]IBotModule[map[string]any]
+
 type IBotModule[T any] struct {
 	Enabled bool `json:"enabled"`
 	Options T    `json:"options"`
 }
 
+type IBotVersion struct {
+	ID        *int64     `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Bot       string     `json:"bot"`
+	Version   int        `json:"version"`
+	// the profile that made the change, empty for the config recorded before version history existed
+	Author  string `json:"author"`
+	Summary string `json:"summary"`
+	// the bot's config after the change
+	Config IBotVersionConfig `json:"config"`
+	// the changes from the previous version
+	Diff []IBotVersionChange `json:"diff"`
+}
+
+type IBotVersionChange struct {
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+type IBotVersionConfig struct {
+	Region      string          `json:"region"`
+	Settings    IBotSettings    `json:"settings"`
+	Permissions IBotPermissions `json:"permissions"`
+	Commands    []IBotCommand   `json:"commands"`
+}
+
 type IBotAnalytics struct {
 	ID        *int        `json:"id,omitempty"`
 	Commands  interface{} `json:"commands"`
@@ -329,16 +475,95 @@ type IRobloxSchemaWallFilter struct {
 }
 
 type IBotModerationAction struct {
-	ID        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Bot       string    `json:"bot"`
-	Guild     string    `json:"guild"`
-	Action    string    `json:"action"`
-	Moderator string    `json:"moderator"`
-	Reason    string    `json:"reason"`
-	Expires   bool      `json:"expires"`
-	Expiry    time.Time `json:"expiry"`
-	User      string    `json:"user"`
+	ID           string     `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Bot          string     `json:"bot"`
+	Guild        string     `json:"guild"`
+	Action       string     `json:"action"`
+	Moderator    string     `json:"moderator"`
+	Reason       string     `json:"reason"`
+	Expires      bool       `json:"expires"`
+	Expiry       time.Time  `json:"expiry"`
+	User         string     `json:"user"`
+	Expired      bool       `json:"expired"`
+	Revoked      bool       `json:"revoked"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *string    `json:"revoked_by,omitempty"`
+	RevokeReason *string    `json:"revoke_reason,omitempty"`
+}
+
+type IModlogDelivery struct {
+	ID        *int64     `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Action    string     `json:"action"`
+	Bot       string     `json:"bot"`
+	// what happened to the action, created or expired
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	// pending, delivered or failed
+	Status   string  `json:"status"`
+	Attempts int     `json:"attempts"`
+	Message  *string `json:"message,omitempty"`
+	Error    *string `json:"error,omitempty"`
+	// set once the log has been posted
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+type IBotAnnouncement struct {
+	ID        *string    `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Bot       string     `json:"bot"`
+	Name      string     `json:"name"`
+	Enabled   bool       `json:"enabled"`
+	Channel   string     `json:"channel"`
+	Content   string     `json:"content"`
+	Embed     *Embed     `json:"embed"`
+	// cron or rrule
+	ScheduleType string `json:"schedule_type"`
+	Schedule     string `json:"schedule"`
+	Timezone     string `json:"timezone"`
+	// where RRULE schedules repeat from
+	StartAt *time.Time `json:"start_at,omitempty"`
+	// empty once the schedule has no more runs
+	NextRunAt *time.Time `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	CreatedBy string     `json:"created_by"`
+}
+
+type IBotAnnouncementRun struct {
+	// the ID of the runner command that sends the announcement
+	ID           string     `json:"id"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	Announcement string     `json:"announcement"`
+	Bot          string     `json:"bot"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	// pending, succeeded or failed
+	Status      string     `json:"status"`
+	Error       *string    `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// the message a runner posted a reaction role menu as
+type IReactionRoleMessage struct {
+	Bot       string     `json:"bot"`
+	Menu      string     `json:"menu"`
+	Channel   string     `json:"channel"`
+	Message   string     `json:"message"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type IRunnerCommand struct {
+	// the ID doubles as the dedupe key, queueing a command with an existing ID is a no-op
+	ID          string     `json:"id"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Bot         string     `json:"bot"`
+	Type        string     `json:"type"`
+	Payload     any        `json:"payload"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Error       *string    `json:"error,omitempty"`
 }
 
 type IDiscordApiUser struct {
@@ -367,3 +592,65 @@ type IIntegrationData struct {
 	User                 string  `json:"user"`
 	Data                 any     `json:"data"`
 }
+
+type IBotAnalyticsBatch struct {
+	Samples []IBotAnalytics `json:"samples"`
+}
+
+type IBotAnalyticsRollup struct {
+	Bot       string         `json:"bot"`
+	Bucket    time.Time      `json:"bucket"`
+	Members   int            `json:"members"`
+	MembersAt time.Time      `json:"members_at"`
+	Messages  int            `json:"messages"`
+	Commands  map[string]int `json:"commands"`
+	Samples   int            `json:"samples"`
+}
+
+type IModerationCase struct {
+	ID        *string                   `json:"id,omitempty"`
+	CreatedAt *time.Time                `json:"created_at,omitempty"`
+	Bot       string                    `json:"bot"`
+	User      string                    `json:"user"`
+	Title     string                    `json:"title"`
+	Status    string                    `json:"status"`
+	Assignee  *string                   `json:"assignee,omitempty"`
+	Actions   []string                  `json:"actions"`
+	Notes     []IModerationCaseNote     `json:"notes"`
+	Evidence  []IModerationCaseEvidence `json:"evidence"`
+}
+
+type IModerationCaseNote struct {
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IModerationCaseEvidence struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	UploadedBy  string    `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type IModerationCaseEvent struct {
+	ID        *int       `json:"id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Case      string     `json:"case"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor"`
+	Data      any        `json:"data"`
+}
+
+type IModerationAppeal struct {
+	ID         *string    `json:"id,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	Case       string     `json:"case"`
+	User       string     `json:"user"`
+	Statement  string     `json:"statement"`
+	Status     string     `json:"status"`
+	Reviewer   *string    `json:"reviewer,omitempty"`
+	Response   *string    `json:"response,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
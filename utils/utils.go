@@ -18,6 +18,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/handlers"
+	"github.com/nedpals/supabase-go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -74,11 +75,221 @@ func AuthMiddleware(ctx *fiber.Ctx) error {
 		})
 	}
 
+	revoked, err := sessionsRevokedAt(*claims.UserInfo.ID)
+
+	if err != nil {
+		return ErrorResponse(ctx, 500, err, false)
+	}
+
+	if revoked != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(revoked.Truncate(time.Second))) {
+		return ctx.Status(http.StatusUnauthorized).JSON(Response[struct {
+			Message string `json:"message"`
+		}]{
+			Result: struct {
+				Message string "json:\"message\""
+			}{Message: "Your session has been signed out. Please log in again."},
+			Code:  http.StatusUnauthorized,
+			Error: "",
+		})
+	}
+
 	ctx.Locals("user", claims.UserInfo)
 
+	if claims.Act != nil {
+		session, err := GetImpersonationSession(db.New(), claims.Act.Session)
+
+		if err != nil {
+			return ErrorResponse(ctx, 500, err, false)
+		}
+
+		if session == nil || !session.Active(time.Now()) {
+			return ctx.Status(http.StatusUnauthorized).JSON(Response[struct {
+				Message string `json:"message"`
+			}]{
+				Result: struct {
+					Message string "json:\"message\""
+				}{Message: "This impersonation session has ended."},
+				Code:  http.StatusUnauthorized,
+				Error: "",
+			})
+		}
+
+		ctx.Locals("impersonation", *session)
+
+		AuditRequest(ctx, db.New(), session.Staff, "impersonation.request", "user", session.User, map[string]any{"session": *session.ID})
+	}
+
+	if ctx.Method() != fiber.MethodGet && ctx.Method() != fiber.MethodHead && ctx.Method() != fiber.MethodOptions && !isBlacklistExempt(ctx.Path()) {
+		blacklist, err := GetActiveBlacklist(db.New(), *claims.UserInfo.ID)
+
+		if err != nil {
+			return ErrorResponse(ctx, 500, err, false)
+		}
+
+		if blacklist != nil {
+			return ctx.Status(http.StatusForbidden).JSON(Response[*IBlacklist]{
+				Result: blacklist,
+				Code:   http.StatusForbidden,
+				Error:  "You have been blacklisted from Astral and cannot make changes.",
+			})
+		}
+	}
+
+	return ctx.Next()
+}
+
+// Bars impersonated sessions from destructive, billing and account routes, must run after AuthMiddleware
+func NoImpersonationMiddleware(ctx *fiber.Ctx) error {
+	if _, ok := ctx.Locals("impersonation").(IImpersonationSession); ok {
+		return ctx.Status(http.StatusForbidden).JSON(Response[struct {
+			Message string `json:"message"`
+		}]{
+			Result: struct {
+				Message string "json:\"message\""
+			}{Message: "This action is not available while impersonating a user."},
+			Code:  http.StatusForbidden,
+			Error: "",
+		})
+	}
+
 	return ctx.Next()
 }
 
+func GetImpersonationSession(database *supabase.Client, id string) (*IImpersonationSession, error) {
+	var sessions []IImpersonationSession
+
+	err := database.DB.From("impersonation_sessions").Select("*").Eq("id", id).Execute(&sessions)
+
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+
+	return &sessions[0], nil
+}
+
+// EndImpersonationSession ends a session before it expires, its tokens stop working immediately
+func EndImpersonationSession(ctx *fiber.Ctx, database *supabase.Client, session IImpersonationSession, by string) (IImpersonationSession, error) {
+	var sessions []IImpersonationSession
+
+	err := database.DB.From("impersonation_sessions").Update(map[string]interface{}{
+		"ended_at": time.Now().UTC(),
+	}).Eq("id", *session.ID).Execute(&sessions)
+
+	if err != nil {
+		return session, err
+	}
+
+	if len(sessions) > 0 {
+		session = sessions[0]
+	}
+
+	AuditRequest(ctx, database, by, "impersonation.stop", "user", session.User, map[string]any{"session": *session.ID, "staff": session.Staff})
+
+	return session, nil
+}
+
+// sessionsRevokedAt returns when staff last signed a user out everywhere, if ever
+func sessionsRevokedAt(user string) (*time.Time, error) {
+	var profiles []struct {
+		SessionsRevokedAt *time.Time `json:"sessions_revoked_at"`
+	}
+
+	err := db.New().DB.From("profiles").Select("sessions_revoked_at").Eq("id", user).Execute(&profiles)
+
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+
+	return profiles[0].SessionsRevokedAt, nil
+}
+
+// routes blacklisted users can still use to leave or contest the platform
+var blacklistExemptPaths = []string{
+	"/api/v1/auth/delete",
+	"/api/v1/auth/status/appeal",
+	// staff must be able to end a session as a blacklisted user
+	"/api/v1/auth/impersonation/stop",
+}
+
+func isBlacklistExempt(path string) bool {
+	for _, exempt := range blacklistExemptPaths {
+		if strings.TrimSuffix(path, "/") == exempt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetActiveBlacklist returns the blacklist entry that currently blocks a user, if any
+func GetActiveBlacklist(database *supabase.Client, user string) (*IBlacklist, error) {
+	var entries []IBlacklist
+
+	err := database.DB.From("blacklist").Select("*").Eq("user", user).Eq("lifted", "false").Execute(&entries)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, entry := range entries {
+		if entry.Active(now) {
+			return &entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// access levels of staff profiles, each level includes the ones below it
+var AccessLevels = map[string]int{
+	"user":      0,
+	"support":   1,
+	"moderator": 2,
+	"admin":     3,
+}
+
+// HasAccess reports whether a profile has at least the given access level. Levels that are not in AccessLevels, on
+// either side, never grant access.
+func HasAccess(profile IProfile, level string) bool {
+	have, ok := AccessLevels[profile.Access]
+
+	if !ok {
+		return false
+	}
+
+	need, ok := AccessLevels[level]
+
+	return ok && have >= need
+}
+
+// Requires the authenticated profile to have at least the given access level, must run after ProfileMiddleware
+func AccessMiddleware(level string) fiber.Handler {
+	// a misspelt level would otherwise only show up as every request being denied
+	if _, ok := AccessLevels[level]; !ok {
+		panic(fmt.Sprintf("unknown access level %q", level))
+	}
+
+	return func(ctx *fiber.Ctx) error {
+		profile := ctx.Locals("profile").(IProfile)
+
+		if !HasAccess(profile, level) {
+			return ctx.Status(http.StatusForbidden).JSON(Response[struct {
+				Message string `json:"message"`
+			}]{
+				Result: struct {
+					Message string "json:\"message\""
+				}{Message: "You do not have access to this page!"},
+				Code:  http.StatusForbidden,
+				Error: "",
+			})
+		}
+
+		return ctx.Next()
+	}
+}
+
 // Injects user if the user exists
 func AuthInjectorMiddleware(ctx *fiber.Ctx) error {
 	auth_header := ctx.GetReqHeaders()["Authorization"]
@@ -233,6 +444,48 @@ func BotMiddleware(ctx *fiber.Ctx) error {
 	return ctx.Next()
 }
 
+// Authenticates bot runners using the shared runner secret
+func RunnerMiddleware(ctx *fiber.Ctx) error {
+	secret := os.Getenv("RUNNER_SECRET")
+	auth_header := ctx.GetReqHeaders()["Authorization"]
+
+	if secret == "" || auth_header != "Bearer "+secret {
+		return ctx.Status(http.StatusUnauthorized).JSON(Response[struct {
+			Message string `json:"message"`
+		}]{
+			Result: struct {
+				Message string "json:\"message\""
+			}{Message: "You must be an authenticated runner to access this page!"},
+			Code:  http.StatusUnauthorized,
+			Error: "",
+		})
+	}
+
+	return ctx.Next()
+}
+
+func RunnerBotMiddleware(ctx *fiber.Ctx) error {
+	botId := ctx.Params("bot_id")
+
+	var bots []IBot
+
+	database := db.New()
+
+	err := database.DB.From("bots").Select("id, created_at, owner, region, settings, token, commands, permissions").Eq("id", botId).Execute(&bots)
+
+	if err != nil {
+		return ErrorResponse(ctx, 500, err, false)
+	}
+
+	if len(bots) == 0 {
+		return ErrorResponse(ctx, 404, errors.New("Bot not found"), true)
+	}
+
+	ctx.Locals("bot", bots[0])
+
+	return ctx.Next()
+}
+
 func WorkspaceIntegrationMiddleware(ctx *fiber.Ctx) error {
 	integrationId := ctx.Params("integrationId")
 	workspace := ctx.Locals("workspace").(IWorkspace)
@@ -278,10 +531,64 @@ func ErrorResponse(ctx *fiber.Ctx, code int, err error, isManual bool) error {
 	})
 }
 
+// maps plan IDs to the numeric plan stored on workspaces
+var Plans = map[string]int64{
+	"free":    1,
+	"starter": 2,
+	"pro":     3,
+}
+
+func GetWorkspacePlan(workspace IWorkspace) (IPlan, error) {
+	var plan IPlan
+
+	for id, number := range Plans {
+		if number == workspace.Plan {
+			database := db.New()
+
+			err := database.DB.From("plans").Select("*").Single().Eq("id", id).Execute(&plan)
+
+			return plan, err
+		}
+	}
+
+	return plan, fmt.Errorf("workspace has an unknown plan %d", workspace.Plan)
+}
+
+// UploadWorkspaceFile stores a file next to the workspace's logo and returns its public URL
+func UploadWorkspaceFile(workspaceId string, name string, contentType string, data []byte) (string, error) {
+	path := os.Getenv("SUPABASE_URL") + "/storage/v1/object/workspaces-data/workspaces/" + workspaceId + "/" + name
+	publicPath := os.Getenv("SUPABASE_URL") + "/storage/v1/object/public/workspaces-data/workspaces/" + workspaceId + "/" + name
+
+	client := fiber.AcquireClient()
+	defer fiber.ReleaseClient(client)
+
+	agent := client.Post(path)
+
+	agent.Add("Content-Type", contentType)
+	agent.Add("Authorization", "Bearer "+os.Getenv("SUPABASE_KEY"))
+
+	agent.Body(data)
+
+	status, body, errs := agent.Bytes()
+
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
+
+	if status != http.StatusOK {
+		return "", fmt.Errorf("uploading %s failed with status %d: %s", name, status, body)
+	}
+
+	return publicPath, nil
+}
+
 type String string
 
 func (s String) Format(data map[string]interface{}) (out string, err error) {
-	t := template.Must(template.New("").Parse(string(s)))
+	t, err := template.New("").Parse(string(s))
+	if err != nil {
+		return
+	}
 	builder := &strings.Builder{}
 	if err = t.Execute(builder, data); err != nil {
 		return
@@ -503,9 +810,37 @@ func RandInt(min int, max int) int {
 
 type UserClaims struct {
 	UserInfo IProvider
+	// set when a member of staff is acting as the user, see RFC 8693
+	Act *ActorClaim `json:"act,omitempty"`
 	*jwt.RegisteredClaims
 }
 
+type ActorClaim struct {
+	// the profile ID of the member of staff
+	Subject string `json:"sub"`
+	// the impersonation session the token belongs to
+	Session string `json:"session"`
+}
+
+// impersonated sessions are kept short so they cannot outlive the support case they were started for
+const ImpersonationDuration = 30 * time.Minute
+
+// CreateImpersonationToken issues a short-lived token for userInfo marked as being used by a member of staff
+func CreateImpersonationToken(userInfo IProvider, session IImpersonationSession) (string, error) {
+	token := jwt.New(jwt.GetSigningMethod("HS256"))
+	token.Claims = &UserClaims{
+		userInfo,
+		&ActorClaim{Subject: session.Staff, Session: *session.ID},
+		&jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   session.User,
+		},
+	}
+
+	return token.SignedString(secret)
+}
+
 var secret = []byte(os.Getenv("SECRET"))
 
 func CreateToken(sub string, userInfo IProvider) (string, error) {
@@ -513,8 +848,10 @@ func CreateToken(sub string, userInfo IProvider) (string, error) {
 	exp := time.Now().Add(time.Hour * 24)
 	token.Claims = &UserClaims{
 		userInfo,
+		nil,
 		&jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   sub,
 		},
 	}
@@ -529,7 +866,7 @@ func CreateToken(sub string, userInfo IProvider) (string, error) {
 func GetClaimsFromToken(tokenString string) (UserClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Printf("unexpected signing method ", token.Header["alg"])
+			log.Printf("unexpected signing method %v", token.Header["alg"])
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return secret, nil
@@ -0,0 +1,196 @@
+package welcome
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/astralservices/api/discordapi"
+	"github.com/astralservices/api/templates"
+	"github.com/astralservices/api/utils"
+)
+
+const (
+	EventJoin  = "join"
+	EventLeave = "leave"
+)
+
+// Discord's limit for message content, embeds are limited by utils.Embed
+const MaxContentLength = 2000
+
+// SampleVariables are the variables welcome and leave messages can use, with the values previews render with
+var SampleVariables = map[string]any{
+	"user": map[string]any{
+		"id":      "123456789012345678",
+		"name":    "Astral",
+		"tag":     "Astral#0001",
+		"mention": "<@123456789012345678>",
+		"avatar":  "https://cdn.discordapp.com/embed/avatars/0.png",
+	},
+	"server": map[string]any{
+		"id":   "876543210987654321",
+		"name": "Astral",
+	},
+	"memberCount": 1234,
+}
+
+// Variables returns the values a message is rendered with for a member
+func Variables(userID string, name string, tag string, avatar string, serverID string, server string, memberCount int) map[string]any {
+	return map[string]any{
+		"user": map[string]any{
+			"id":      userID,
+			"name":    name,
+			"tag":     tag,
+			"mention": "<@" + userID + ">",
+			"avatar":  avatar,
+		},
+		"server": map[string]any{
+			"id":   serverID,
+			"name": server,
+		},
+		"memberCount": memberCount,
+	}
+}
+
+type Embed struct {
+	Enabled     bool   `json:"enabled"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+	Image       string `json:"image"`
+}
+
+type Message struct {
+	Enabled bool   `json:"enabled"`
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+	Embed   Embed  `json:"embed"`
+	// send the message to the member instead of the channel, only for join messages
+	DM bool `json:"dm"`
+}
+
+// Config is the options of the welcome module
+type Config struct {
+	Join  Message `json:"join"`
+	Leave Message `json:"leave"`
+	// roles given to members when they join
+	AutoRoles []string `json:"autoRoles"`
+}
+
+// ParseConfig reads the welcome module's options, which have already been checked against its schema
+func ParseConfig(options map[string]any) (Config, error) {
+	var config Config
+
+	data, err := json.Marshal(options)
+
+	if err != nil {
+		return config, err
+	}
+
+	return config, json.Unmarshal(data, &config)
+}
+
+// Message returns the message of an event
+func (c Config) Message(event string) (Message, error) {
+	switch event {
+	case EventJoin:
+		return c.Join, nil
+	case EventLeave:
+		return c.Leave, nil
+	}
+
+	return Message{}, errors.New("event must be one of join or leave")
+}
+
+// TemplateError is a template that cannot be rendered, Field names the part of the message it is in
+type TemplateError struct {
+	Field   string `json:"field"`
+	Pos     int    `json:"pos"`
+	Message string `json:"message"`
+}
+
+func (e TemplateError) Error() string {
+	return fmt.Sprintf("%s at %d: %s", e.Field, e.Pos, e.Message)
+}
+
+// Render builds the Discord message of an event, returning every template that could not be rendered
+func Render(message Message, variables map[string]any) (discordapi.MessageCreate, []TemplateError) {
+	out := discordapi.MessageCreate{AllowedMentions: &discordapi.AllowedMentions{Parse: []string{"users"}}}
+	errs := []TemplateError{}
+
+	render := func(field string, template string, maxLength int) string {
+		text, err := templates.Render(template, variables, maxLength)
+
+		var templateErr *templates.Error
+
+		if errors.As(err, &templateErr) {
+			errs = append(errs, TemplateError{Field: field, Pos: templateErr.Pos, Message: templateErr.Message})
+		}
+
+		return text
+	}
+
+	out.Content = render("message", message.Message, MaxContentLength)
+
+	if message.Embed.Enabled {
+		embed := utils.Embed{
+			Title:       render("embed.title", message.Embed.Title, utils.MaxEmbedTitle),
+			Description: render("embed.description", message.Embed.Description, utils.MaxEmbedDescription),
+			Color:       message.Embed.Color,
+		}
+
+		if message.Embed.Image != "" {
+			embed.Image = &utils.EmbedMedia{URL: render("embed.image", message.Embed.Image, 2048)}
+		}
+
+		out.Embeds = []utils.Embed{embed}
+	}
+
+	return out, errs
+}
+
+func check(event string, message Message) error {
+	if !message.Enabled {
+		return nil
+	}
+
+	if message.DM && event != EventJoin {
+		return fmt.Errorf("%s: only join messages can be sent as a DM", event)
+	}
+
+	if message.Channel == "" && !message.DM {
+		return fmt.Errorf("%s: a channel is required", event)
+	}
+
+	if message.Message == "" && !message.Embed.Enabled {
+		return fmt.Errorf("%s: a message or an embed is required", event)
+	}
+
+	out, errs := Render(message, SampleVariables)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s.%w", event, errs[0])
+	}
+
+	// the sample values stand in for the member, an avatar template renders to a URL like a real one would
+	if err := utils.ValidateEmbeds(out.Embeds); err != nil {
+		return fmt.Errorf("%s.embed: %w", event, err)
+	}
+
+	return nil
+}
+
+// Check validates the templates and channels of the welcome module's options
+func Check(options map[string]any) error {
+	config, err := ParseConfig(options)
+
+	if err != nil {
+		return err
+	}
+
+	if err = check(EventJoin, config.Join); err != nil {
+		return err
+	}
+
+	return check(EventLeave, config.Leave)
+}